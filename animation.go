@@ -189,6 +189,7 @@ type Animation struct {
 	Channels map[string]*AnimationChannel
 	Length   float64  // Length of the animation in seconds
 	Markers  []Marker // Markers as specified in the Animation from the modeler
+	revision int      // Incremented when the Animation is reloaded, so AnimationPlayers know to reassign its channels
 }
 
 // NewAnimation creates a new Animation of the name specified.
@@ -223,6 +224,7 @@ type AnimationPlayer struct {
 	RootNode               INode
 	ChannelsToNodes        map[*AnimationChannel]INode
 	ChannelsUpdated        bool
+	channelsRevision       int // The revision of the Animation that the channels were assigned for
 	Animation              *Animation
 	Playhead               float64    // Playhead of the animation. Setting this to 0 restarts the animation.
 	PlaySpeed              float64    // Playback speed in percentage - defaults to 1 (100%)
@@ -264,6 +266,7 @@ func (ap *AnimationPlayer) Clone() *AnimationPlayer {
 		newAP.ChannelsToNodes[channel] = node
 	}
 	newAP.ChannelsUpdated = ap.ChannelsUpdated
+	newAP.channelsRevision = ap.channelsRevision
 
 	newAP.Animation = ap.Animation
 	newAP.Playhead = ap.Playhead
@@ -315,6 +318,8 @@ func (ap *AnimationPlayer) assignChannels() {
 
 	if ap.Animation != nil {
 
+		ap.channelsRevision = ap.Animation.revision
+
 		ap.AnimatedProperties = map[INode]*AnimationValues{}
 
 		ap.ChannelsToNodes = map[*AnimationChannel]INode{}
@@ -363,7 +368,11 @@ func (ap *AnimationPlayer) updateValues(dt float64) {
 
 		if ap.Animation != nil {
 
-			if !ap.ChannelsUpdated {
+			if ap.channelsRevision != ap.Animation.revision && ap.Playhead > ap.Animation.Length {
+				ap.Playhead = ap.Animation.Length // The Animation was reloaded and got shorter
+			}
+
+			if !ap.ChannelsUpdated || ap.channelsRevision != ap.Animation.revision {
 				ap.assignChannels()
 			}
 
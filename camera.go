@@ -4,7 +4,10 @@ import (
 	"fmt"
 	"image/color"
 	"math"
+	"math/bits"
 	"sort"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/hajimehoshi/ebiten/v2"
@@ -40,6 +43,16 @@ const (
 	AccumlateColorModeSingleLastFrame        // Accumulation buffer is on and renders just the previous frame's ColorTexture result
 )
 
+const (
+	// TransparencySortModeModels sorts transparent MeshParts by the distance of their Models from the Camera; triangles are then sorted within each
+	// MeshPart according to its Material's TriangleSortMode. This is the fastest mode, but intersecting transparent Models can render in the wrong order.
+	TransparencySortModeModels = iota
+	// TransparencySortModeTriangles sorts all transparent triangles together back to front, regardless of which Model they belong to, so that
+	// intersecting transparent Models render correctly. This is slower, as each run of triangles from the same MeshPart is its own draw call.
+	// Note that dynamically batched and instanced Models are still sorted as a whole.
+	TransparencySortModeTriangles
+)
+
 // Camera represents a camera (where you look from) in Tetra3D.
 type Camera struct {
 	*Node
@@ -52,11 +65,26 @@ type Camera struct {
 	depthIntermediate     *ebiten.Image
 	clipAlphaIntermediate *ebiten.Image
 
+	// Intermediate textures used for Materials with LightingModePixel; these are only created once they're needed.
+	normalIntermediate      *ebiten.Image
+	tangentIntermediate     *ebiten.Image
+	bitangentIntermediate   *ebiten.Image
+	normalMapIntermediate   *ebiten.Image
+	viewDepthIntermediate   *ebiten.Image
+	specularIntermediate    *ebiten.Image
+	vertexLightIntermediate *ebiten.Image
+	emissiveIntermediate    *ebiten.Image
+	litIntermediate         *ebiten.Image
+
 	resultAccumulatedColorTexture *ebiten.Image // ResultAccumulatedColorTexture holds the previous frame's render result of rendering any models.
 	accumulatedBackBuffer         *ebiten.Image
 	AccumulateColorMode           int                      // The mode to use when rendering previous frames to the accumulation buffer. Defaults to AccumulateColorModeNone.
 	AccumulateDrawOptions         *ebiten.DrawImageOptions // Draw image options to use when rendering frames to the accumulation buffer; use this to fade out or color previous frames.
 
+	// PostProcessEffects is the stack of post-processing effects that are applied, in order, to the color texture when Camera.PostProcess() is called.
+	PostProcessEffects []IPostProcessEffect
+	postProcessBuffers [2]*ebiten.Image
+
 	Near, Far   float64 // The near and far clipping plane.
 	Perspective bool    // If the Camera has a perspective projection. If not, it would be orthographic
 	FieldOfView float64 // Vertical field of view in degrees for a perspective projection camera
@@ -64,11 +92,47 @@ type Camera struct {
 
 	DebugInfo DebugInfo
 
+	// Workers is the number of goroutines used to transform, skin, and light the vertices of each MeshPart when rendering. Larger MeshParts
+	// have their triangles split between the workers, while smaller ones are processed on the rendering goroutine. The render results
+	// are the same regardless of the number of workers. Models with a VertexTransformFunction are always transformed on the rendering
+	// goroutine. Defaults to 1, meaning all vertices are processed on the rendering goroutine.
+	Workers int
+
+	// PortalCulling is whether the Camera culls Models using the Scene's Rooms and Portals when rendering. If the Camera is inside of a Room,
+	// only Models in Rooms visible through Portals from the Camera's Room (as well as Models outside of all Rooms) are rendered.
+	// Defaults to true; this has no effect on Scenes without Rooms.
+	PortalCulling bool
+
+	// TransparencySortMode is how transparent triangles are sorted when rendering (TransparencySortModeModels or TransparencySortModeTriangles).
+	// Defaults to TransparencySortModeModels.
+	TransparencySortMode int
+
+	// CullingMask is a bitmask of the render layers the Camera renders; Models are only rendered if they're on at least one of these
+	// layers (see Model.RenderLayers and RenderLayer()). Defaults to RenderLayerAll.
+	CullingMask uint32
+
+	// If ClearDepthBetweenLayers is true, Models are rendered in order of the lowest render layer they're on (of the layers in the
+	// CullingMask), with depth cleared before each layer. This way, Models on later layers (like a first-person weapon, or 3D UI elements)
+	// are always drawn on top of, and never clip into, Models on earlier layers. Defaults to false.
+	ClearDepthBetweenLayers bool
+
+	renderedTriangles []int
+	triangleFilter    []bool
+
 	backfacePool             *VectorPool
 	depthShader              *ebiten.Shader
 	clipAlphaCompositeShader *ebiten.Shader
 	clipAlphaRenderShader    *ebiten.Shader
 	colorShader              *ebiten.Shader
+	viewDepthShader          *ebiten.Shader
+	lightingShader           *ebiten.Shader
+	normalMapShader          *ebiten.Shader
+	lightingCompositeShader  *ebiten.Shader
+	skyShader                *ebiten.Shader
+
+	// Whether the Camera's shaders and textures are yet to be created (see newCamera()), and the size to create its textures with
+	resourcesPending            bool
+	pendingWidth, pendingHeight int
 
 	// Visibility check variables
 	cameraForward          vector.Vector
@@ -82,6 +146,15 @@ type Camera struct {
 
 // NewCamera creates a new Camera with the specified width and height.
 func NewCamera(w, h int) *Camera {
+	cam := newCamera(w, h)
+	cam.createResources()
+	return cam
+}
+
+// newCamera creates a new Camera with the specified width and height, without creating its shaders and textures; createResources()
+// must be called to create them before the Camera can be used to render. This allows Cameras to be created off of the main thread
+// (i.e. when loading asynchronously).
+func newCamera(w, h int) *Camera {
 
 	cam := &Camera{
 		Node:        NewNode("Camera"),
@@ -91,8 +164,26 @@ func NewCamera(w, h int) *Camera {
 
 		backfacePool:          NewVectorPool(3, true),
 		AccumulateDrawOptions: &ebiten.DrawImageOptions{},
+		Workers:               1,
+		PortalCulling:         true,
+		CullingMask:           RenderLayerAll,
+
+		resourcesPending: true,
+		pendingWidth:     w,
+		pendingHeight:    h,
 	}
 
+	cam.SetPerspective(60)
+
+	return cam
+
+}
+
+// createResources compiles the Camera's shaders and creates its textures, using the size the Camera was created with.
+func (cam *Camera) createResources() {
+
+	cam.resourcesPending = false
+
 	depthShaderText := []byte(
 		`package main
 
@@ -195,6 +286,14 @@ func NewCamera(w, h int) *Camera {
 
 		var Fog vec4
 		var FogRange [2]float
+		var FogCurve float
+		var FogDensity float
+		var FogHeight vec2
+		var DepthToDistance vec2
+		var Perspective float
+		var ProjectionFactor vec2
+		var CameraHeight float
+		var ViewToWorldY vec3
 
 		func decodeDepth(rgba vec4) float {
 			return rgba.r + (rgba.g / 255) + (rgba.b / 65025)
@@ -206,8 +305,36 @@ func NewCamera(w, h int) *Camera {
 			
 			if depth.a > 0 {
 				colorTex := imageSrc0At(texCoord)
-				
-				d := smoothstep(FogRange[0], FogRange[1], decodeDepth(depth))
+
+				depthValue := decodeDepth(depth)
+				dist := depthValue*DepthToDistance.x + DepthToDistance.y
+
+				d := 0.0
+
+				if FogCurve == 0 {
+					d = smoothstep(FogRange[0], FogRange[1], depthValue)
+				} else if FogCurve == 1 {
+					d = 1 - exp(-FogDensity*dist)
+				} else {
+					d = 1 - exp(-pow(FogDensity*dist, 2))
+				}
+
+				// Height fog thins out above FogHeight; the fragment's world-space height is reconstructed from its
+				// screen position and distance from the camera.
+				if FogHeight.y > 0 {
+
+					origin, size := imageSrcRegionOnTexture()
+					screen := (texCoord - origin) / size
+					screen = vec2(screen.x-0.5, 0.5-screen.y) * ProjectionFactor
+
+					if Perspective > 0 {
+						screen *= dist
+					}
+
+					height := CameraHeight + dot(vec3(screen, -dist), ViewToWorldY)
+					d *= exp(-max(height-FogHeight.x, 0) * FogHeight.y)
+
+				}
 
 				if Fog.a == 1 {
 					colorTex.rgb += Fog.rgb * d * colorTex.a
@@ -237,19 +364,332 @@ func NewCamera(w, h int) *Camera {
 		panic(err)
 	}
 
-	if w != 0 && h != 0 {
-		cam.Resize(w, h)
+	viewDepthShaderText := []byte(
+		`package main
+
+		func encodeDepth(depth float) vec4 {
+			r := floor(depth * 255) / 255
+			g := floor(fract(depth * 255) * 255) / 255
+			b := fract(depth * 255*255)
+			return vec4(r, g, b, 1);
+		}
+
+		func Fragment(position vec4, texCoord vec2, color vec4) vec4 {
+			return encodeDepth(color.r)
+		}
+
+		`,
+	)
+
+	cam.viewDepthShader, err = ebiten.NewShader(viewDepthShaderText)
+
+	if err != nil {
+		panic(err)
 	}
 
-	cam.SetPerspective(60)
+	// The normal map shader perturbs the view-space normals (imageSrc0) of a MeshPart using the view-space tangents (imageSrc1)
+	// and bitangents (imageSrc2) of its triangles, and the colors of its normal map (imageSrc3).
+	normalMapShaderText := []byte(
+		`package main
+
+		func Fragment(position vec4, texCoord vec2, color vec4) vec4 {
+
+			normalTex := imageSrc0At(texCoord)
+
+			if normalTex.a > 0 {
+
+				normal := normalize(normalTex.rgb*2 - 1)
+				tangent := imageSrc1At(texCoord).rgb*2 - 1
+				bitangent := imageSrc2At(texCoord).rgb*2 - 1
+				mapped := imageSrc3At(texCoord).rgb*2 - 1
+
+				// Triangles with degenerate UVs have no tangents, so they just keep their normals.
+				if length(tangent) > 0.01 && length(bitangent) > 0.01 {
+					normal = normalize(normalize(tangent)*mapped.x + normalize(bitangent)*mapped.y + normal*mapped.z)
+				}
+
+				return vec4(normal*0.5+0.5, 1)
+
+			}
+
+			discard()
+
+		}
+
+		`,
+	)
+
+	cam.normalMapShader, err = ebiten.NewShader(normalMapShaderText)
+
+	if err != nil {
+		panic(err)
+	}
+
+	// The lighting shader lights the unlit color render of a MeshPart (imageSrc0) using the view-space normals (imageSrc1) and
+	// view-space distance (imageSrc2) of its fragments, as well as the roughness (green channel) and metalness (blue channel)
+	// of its specular texture (imageSrc3) if SpecularOn is set. Lights are in view space.
+	lightingShaderText := []byte(
+		`package main
+
+		var LightCount float
+		var LightPositions [8]vec3
+		var LightDirections [8]vec3
+		var LightColors [8]vec3
+		var LightSettings [8]vec4
+		var AmbientLight vec3
+		var Perspective float
+		var ProjectionFactor vec2
+		var Far float
+		var SpecularOn float
+
+		func decodeDepth(rgba vec4) float {
+			return rgba.r + (rgba.g / 255) + (rgba.b / 65025)
+		}
+
+		func Fragment(position vec4, texCoord vec2, color vec4) vec4 {
+
+			colorTex := imageSrc0At(texCoord)
+
+			if colorTex.a > 0 {
+
+				normal := normalize(imageSrc1At(texCoord).rgb*2 - 1)
+				dist := decodeDepth(imageSrc2At(texCoord)) * Far
+
+				origin, size := imageSrcRegionOnTexture()
+				screen := (texCoord - origin) / size
+				screen = vec2(screen.x-0.5, 0.5-screen.y) * ProjectionFactor
+
+				viewDir := vec3(0, 0, 1)
+
+				if Perspective > 0 {
+					screen *= dist
+				}
+
+				fragPos := vec3(screen, -dist)
+
+				if Perspective > 0 {
+					viewDir = normalize(-fragPos)
+				}
+
+				roughness := 1.0
+				metallic := 0.0
+
+				if SpecularOn > 0 {
+					specularTex := imageSrc3At(texCoord)
+					roughness = specularTex.g
+					metallic = specularTex.b
+				}
+
+				shininess := clamp(2/(pow(roughness, 4)+0.0001)-2, 1, 512)
+				specularStrength := mix(0.25, 1, metallic) * (1 - roughness)
+
+				light := AmbientLight
+				specular := vec3(0)
+
+				for i := 0; i < 8; i++ {
+
+					if float(i) >= LightCount {
+						break
+					}
+
+					settings := LightSettings[i]
+
+					lightVec := LightDirections[i]
+					attenuation := 1.0
+
+					// Point and spot lights; directional lights just shine in their direction
+					if settings.x != 1 {
+
+						lightVec = LightPositions[i] - fragPos
+						distSquared := dot(lightVec, lightVec)
+						lightVec = normalize(lightVec)
+
+						if settings.y == 0 {
+							attenuation = (1.0 / (1.0 + (0.1 * distSquared))) * 2
+						} else {
+							attenuation = clamp(1.0-pow(distSquared/(settings.y*settings.y), 4), 0, 1)
+						}
+
+						// Spot light
+						if settings.x == 2 {
+							cosAngle := dot(lightVec, LightDirections[i])
+							if settings.z > settings.w {
+								attenuation *= smoothstep(settings.w, settings.z, cosAngle)
+							} else {
+								attenuation *= step(settings.w, cosAngle)
+							}
+						}
+
+					}
+
+					diffuse := dot(normal, lightVec)
+
+					if diffuse > 0 {
+						light += LightColors[i] * diffuse * attenuation
+						if specularStrength > 0 {
+							specular += LightColors[i] * pow(max(dot(normal, normalize(lightVec+viewDir)), 0), shininess) * specularStrength * attenuation
+						}
+					}
+
+				}
+
+				// Metallic highlights are tinted by the (un-premultiplied) color of the surface.
+				specular *= mix(vec3(1), colorTex.rgb/colorTex.a, metallic)
+
+				return vec4(colorTex.rgb*light+specular*colorTex.a, colorTex.a)
+
+			}
+
+			discard()
+
+		}
+
+		`,
+	)
+
+	cam.lightingShader, err = ebiten.NewShader(lightingShaderText)
+
+	if err != nil {
+		panic(err)
+	}
+
+	// The lighting composite shader adds the color of a MeshPart lit by any lights calculated per-vertex (imageSrc1) and
+	// its emission (imageSrc2) to its per-pixel lit render (imageSrc0).
+	lightingCompositeShaderText := []byte(
+		`package main
+
+		func Fragment(position vec4, texCoord vec2, color vec4) vec4 {
+
+			litTex := imageSrc0At(texCoord)
+
+			if litTex.a > 0 {
+				litTex.rgb += imageSrc1At(texCoord).rgb + imageSrc2At(texCoord).rgb*litTex.a
+				return litTex
+			}
+
+			discard()
+
+		}
+
+		`,
+	)
+
+	cam.lightingCompositeShader, err = ebiten.NewShader(lightingCompositeShaderText)
+
+	if err != nil {
+		panic(err)
+	}
+
+	// The sky shader draws the World's sky in the world-space view direction of each pixel, which is interpolated from the
+	// directions of the screen's corners stored in the vertex colors. For SkyEquirectangular and SkyCubemap, imageSrc0 is the sky texture.
+	skyShaderText := []byte(
+		`package main
+
+		var SkyMode float
+		var TopColor vec3
+		var HorizonColor vec3
+		var BottomColor vec3
+		var SunDirection vec3
+		var SunColor vec3
+		var SunSize vec2
+
+		func Fragment(position vec4, texCoord vec2, color vec4) vec4 {
+
+			dir := normalize(color.rgb)
+			pi := 3.14159265
+
+			// Gradient
+			if SkyMode == 1 {
+
+				elevation := asin(clamp(dir.y, -1, 1)) / (pi / 2)
+
+				sky := mix(HorizonColor, TopColor, clamp(elevation, 0, 1))
+				if elevation < 0 {
+					sky = mix(HorizonColor, BottomColor, clamp(-elevation, 0, 1))
+				}
+
+				if SunSize.x < 1 {
+					sky = mix(sky, SunColor, smoothstep(SunSize.x, SunSize.y, dot(dir, SunDirection)))
+				}
+
+				return vec4(sky, 1)
+
+			}
+
+			origin, size := imageSrcRegionOnTexture()
+			texel := 1 / imageSrcTextureSize()
+
+			// Equirectangular
+			if SkyMode == 2 {
+				uv := vec2(0.5+atan2(dir.z, dir.x)/(2*pi), 0.5-asin(clamp(dir.y, -1, 1))/pi)
+				pos := origin + uv*size
+				return imageSrc0UnsafeAt(clamp(pos, origin+texel/2, origin+size-texel/2))
+			}
+
+			// Cubemap
+			a := abs(dir)
+			face := 0.0
+			uv := vec2(0)
+
+			if a.x >= a.y && a.x >= a.z {
+				if dir.x > 0 {
+					uv = vec2(-dir.z, -dir.y) / a.x
+				} else {
+					face = 1
+					uv = vec2(dir.z, -dir.y) / a.x
+				}
+			} else if a.y >= a.z {
+				if dir.y > 0 {
+					face = 2
+					uv = vec2(dir.x, dir.z) / a.y
+				} else {
+					face = 3
+					uv = vec2(dir.x, -dir.z) / a.y
+				}
+			} else {
+				if dir.z > 0 {
+					face = 4
+					uv = vec2(dir.x, -dir.y) / a.z
+				} else {
+					face = 5
+					uv = vec2(-dir.x, -dir.y) / a.z
+				}
+			}
+
+			faceOrigin := origin + vec2(face*size.x/6, 0)
+			faceSize := vec2(size.x/6, size.y)
+
+			// Samples are kept half a texel inside of the face to avoid bleeding into neighboring faces.
+			pos := faceOrigin + (uv*0.5+0.5)*faceSize
+			return imageSrc0UnsafeAt(clamp(pos, faceOrigin+texel/2, faceOrigin+faceSize-texel/2))
+
+		}
+
+		`,
+	)
+
+	cam.skyShader, err = ebiten.NewShader(skyShaderText)
+
+	if err != nil {
+		panic(err)
+	}
+
+	if cam.pendingWidth != 0 && cam.pendingHeight != 0 {
+		cam.Resize(cam.pendingWidth, cam.pendingHeight)
+	}
 
-	return cam
 }
 
 func (camera *Camera) Clone() INode {
 
-	w, h := camera.resultColorTexture.Size()
-	clone := NewCamera(w, h)
+	var clone *Camera
+
+	if camera.resourcesPending {
+		clone = newCamera(camera.pendingWidth, camera.pendingHeight)
+	} else {
+		w, h := camera.resultColorTexture.Size()
+		clone = NewCamera(w, h)
+	}
 
 	clone.RenderDepth = camera.RenderDepth
 	clone.Near = camera.Near
@@ -257,10 +697,20 @@ func (camera *Camera) Clone() INode {
 	clone.Perspective = camera.Perspective
 	clone.FieldOfView = camera.FieldOfView
 	clone.OrthoScale = camera.OrthoScale
+	clone.Workers = camera.Workers
+	clone.PortalCulling = camera.PortalCulling
+	clone.CullingMask = camera.CullingMask
+	clone.TransparencySortMode = camera.TransparencySortMode
+	clone.ClearDepthBetweenLayers = camera.ClearDepthBetweenLayers
 
 	clone.AccumulateColorMode = camera.AccumulateColorMode
 	clone.AccumulateDrawOptions = camera.AccumulateDrawOptions
 
+	clone.PostProcessEffects = make([]IPostProcessEffect, 0, len(camera.PostProcessEffects))
+	for _, effect := range camera.PostProcessEffects {
+		clone.PostProcessEffects = append(clone.PostProcessEffects, effect.Clone())
+	}
+
 	clone.Node = camera.Node.Clone().(*Node)
 	for _, child := range camera.children {
 		child.setParent(camera)
@@ -286,6 +736,21 @@ func (camera *Camera) Resize(w, h int) {
 		camera.colorIntermediate.Dispose()
 		camera.depthIntermediate.Dispose()
 		camera.clipAlphaIntermediate.Dispose()
+
+		if camera.litIntermediate != nil {
+			for _, img := range camera.pixelLightingTextures() {
+				(*img).Dispose()
+				*img = nil
+			}
+		}
+
+		if camera.postProcessBuffers[0] != nil {
+			camera.postProcessBuffers[0].Dispose()
+			camera.postProcessBuffers[1].Dispose()
+			camera.postProcessBuffers[0] = nil
+			camera.postProcessBuffers[1] = nil
+		}
+
 	}
 
 	camera.resultAccumulatedColorTexture = ebiten.NewImage(w, h)
@@ -395,64 +860,180 @@ func (camera *Camera) WorldToClip(vert vector.Vector) vector.Vector {
 	return v.MultVecW(vector.Vector{0, 0, 0})
 }
 
-// PointInFrustum returns true if the point is visible through the camera frustum.
-func (camera *Camera) PointInFrustum(point vector.Vector) bool {
+// screenToView returns the position in view space (relative to the Camera, looking down -Z) under the given position on screen
+// at the given depth (the distance from the Camera's plane).
+func (camera *Camera) screenToView(x, y, depth float64) vector.Vector {
 
-	diff := fastVectorSub(point, camera.WorldPosition())
-	pcZ := diff.Dot(camera.cameraForward)
-	aspectRatio := camera.AspectRatio()
+	w, h := camera.resultColorTexture.Size()
+	projection := camera.Projection()
 
-	if pcZ > camera.Far || pcZ < camera.Near {
-		return false
-	}
+	// These are the inverse of the remapping clipToScreen() does.
+	sx := (x - float64(w)/2) / float64(w)
+	sy := (float64(h)/2 - y) / float64(h)
 
 	if camera.Perspective {
+		k := 2 * camera.Far * camera.Near / (camera.Far - camera.Near)
+		return vector.Vector{sx * k * depth / projection[0][0], sy * k * depth / projection[1][1], -depth}
+	}
 
-		h := pcZ * math.Tan(camera.FieldOfView/2)
-
-		pcY := diff.Dot(camera.cameraUp)
+	return vector.Vector{sx / projection[0][0], sy / projection[1][1], -depth}
 
-		if -h > pcY || pcY > h {
-			return false
-		}
+}
 
-		w := h * aspectRatio
+// ScreenToWorld transforms a position on screen (in pixels, relative to the Camera's color texture) to a 3D position in the world,
+// with depth being the distance from the Camera's plane (so a depth of camera.Near is on the near plane, and camera.Far is on the far plane).
+// This is the inverse of WorldToScreen().
+func (camera *Camera) ScreenToWorld(x, y, depth float64) vector.Vector {
+	return camera.WorldRotation().MultVec(camera.screenToView(x, y, depth)).Add(camera.WorldPosition())
+}
 
-		pcX := diff.Dot(camera.cameraRight)
+// ScreenRay returns the origin and (normalized) direction of a ray cast from the Camera into the world through the given position on screen
+// (in pixels, relative to the Camera's color texture). For perspective Cameras, the origin is the Camera's position, and the direction varies
+// across the screen; for orthographic Cameras, the origin varies across the Camera's plane, and the direction is always the Camera's forward vector.
+// This is useful for casting rays from the mouse cursor into the scene.
+func (camera *Camera) ScreenRay(x, y float64) (vector.Vector, vector.Vector) {
 
-		if -w > pcX || pcX > w {
-			return false
-		}
+	rotation := camera.WorldRotation()
 
-	} else {
+	if camera.Perspective {
+		return camera.WorldPosition(), rotation.MultVec(camera.screenToView(x, y, 1)).Unit()
+	}
 
-		width := camera.OrthoScale / 2
-		height := width / camera.AspectRatio()
+	return camera.ScreenToWorld(x, y, 0), rotation.MultVec(vector.Vector{0, 0, -1}).Unit()
 
-		pcY := diff.Dot(camera.cameraUp)
+}
 
-		if -height > pcY || pcY > height {
-			return false
-		}
+// DepthAt returns the depth (the distance from the Camera's plane) of whatever was last rendered at the given pixel of the Camera's depth texture,
+// and a boolean indicating whether anything was rendered there at all. If Camera.RenderDepth is false, DepthAt will always return false.
+// Note that this reads pixels back from the GPU, which is slow, and is only possible after the game has started running (i.e. within Update() or Draw()).
+func (camera *Camera) DepthAt(x, y int) (float64, bool) {
 
-		pcX := diff.Dot(camera.cameraRight)
+	if !camera.RenderDepth {
+		return 0, false
+	}
 
-		if -width > pcX || pcX > width {
-			return false
-		}
+	r, g, b, a := camera.resultDepthTexture.At(x, y).RGBA()
 
+	if a == 0 {
+		return 0, false
 	}
 
-	return true
+	scale, offset := camera.depthToDistance()
+	return decodeDepthColor(uint8(r>>8), uint8(g>>8), uint8(b>>8))*scale + offset, true
 
 }
 
-// SphereInFrustum returns true if the sphere would be visible through the camera frustum.
-func (camera *Camera) SphereInFrustum(sphere *BoundingSphere) bool {
+// decodeDepthColor decodes a depth value from the color of a pixel of a depth texture; this mirrors decodeDepth() from the depth shader.
+func decodeDepthColor(r, g, b uint8) float64 {
+	return float64(r)/255 + float64(g)/255/255 + float64(b)/255/65025
+}
 
-	radius := sphere.WorldRadius()
+// depthDivisor returns the value that vertices' clip-space Z is divided by to be written to the depth texture (see encodeDepth()).
+// This is the far distance used for rendering, plus a margin of 1; orthographic Cameras render with a far distance of 2.
+func (camera *Camera) depthDivisor() float64 {
+	if camera.Perspective {
+		return camera.Far + 1
+	}
+	return 2 + 1
+}
+
+// encodeDepth returns the depth value written to the depth texture for a vertex with the given clip-space Z, from 0 to 1.
+func (camera *Camera) encodeDepth(clipZ float64) float64 {
+	depth := clipZ / camera.depthDivisor()
+	if depth < 0 {
+		depth = 0
+	} else if depth > 1 {
+		depth = 1
+	}
+	return depth
+}
+
+// depthToDistance returns the scale and offset that convert a depth value decoded from the depth texture to the distance from the
+// Camera's plane (i.e. distance = depth * scale + offset). The depth texture holds the clip-space Z divided by depthDivisor();
+// in perspective, the clip-space Z is a * distance - 1 (with a being (Far + Near) / (Far - Near)), while in orthographic mode, it's
+// distance * 2 / (Far - Near).
+func (camera *Camera) depthToDistance() (scale, offset float64) {
+
+	if camera.Perspective {
+		a := (camera.Far + camera.Near) / (camera.Far - camera.Near)
+		return camera.depthDivisor() / a, 1 / a
+	}
+
+	return camera.depthDivisor() * (camera.Far - camera.Near) / 2, 0
+
+}
+
+// ScreenToWorldDepth returns the position in the world of whatever was last rendered at the given pixel of the Camera's color texture by reading
+// the depth texture, and a boolean indicating whether anything was rendered there at all. See Camera.DepthAt() for caveats.
+func (camera *Camera) ScreenToWorldDepth(x, y int) (vector.Vector, bool) {
+	depth, ok := camera.DepthAt(x, y)
+	if !ok {
+		return nil, false
+	}
+	return camera.ScreenToWorld(float64(x)+0.5, float64(y)+0.5, depth), true
+}
+
+// PointInFrustum returns true if the point is visible through the camera frustum.
+func (camera *Camera) PointInFrustum(point vector.Vector) bool {
+
+	diff := fastVectorSub(point, camera.WorldPosition())
+	pcZ := diff.Dot(camera.cameraForward)
+	aspectRatio := camera.AspectRatio()
 
-	diff := fastVectorSub(sphere.WorldPosition(), camera.WorldPosition())
+	if pcZ > camera.Far || pcZ < camera.Near {
+		return false
+	}
+
+	if camera.Perspective {
+
+		h := pcZ * math.Tan(camera.FieldOfView/2)
+
+		pcY := diff.Dot(camera.cameraUp)
+
+		if -h > pcY || pcY > h {
+			return false
+		}
+
+		w := h * aspectRatio
+
+		pcX := diff.Dot(camera.cameraRight)
+
+		if -w > pcX || pcX > w {
+			return false
+		}
+
+	} else {
+
+		width := camera.OrthoScale / 2
+		height := width / camera.AspectRatio()
+
+		pcY := diff.Dot(camera.cameraUp)
+
+		if -height > pcY || pcY > height {
+			return false
+		}
+
+		pcX := diff.Dot(camera.cameraRight)
+
+		if -width > pcX || pcX > width {
+			return false
+		}
+
+	}
+
+	return true
+
+}
+
+// SphereInFrustum returns true if the sphere would be visible through the camera frustum.
+func (camera *Camera) SphereInFrustum(sphere *BoundingSphere) bool {
+	return camera.sphereInFrustum(sphere.WorldPosition(), sphere.WorldRadius())
+}
+
+// sphereInFrustum returns true if a sphere with the given world position and radius would be visible through the camera frustum.
+func (camera *Camera) sphereInFrustum(position vector.Vector, radius float64) bool {
+
+	diff := fastVectorSub(position, camera.WorldPosition())
 	pcZ := dot(diff, camera.cameraForward)
 
 	if pcZ > camera.Far+radius || pcZ < camera.Near-radius {
@@ -599,12 +1180,25 @@ type renderPair struct {
 // Render renders all of the models passed using the provided Scene's properties (fog, for example). Note that if Camera.RenderDepth
 // is false, scenes rendered one after another in multiple Render() calls will be rendered on top of each other in the Camera's texture buffers.
 // Note that for Models, each MeshPart of a Model has a maximum renderable triangle count of 21845.
+// Only Models on the render layers in the Camera's CullingMask are rendered (see Model.RenderLayers).
 func (camera *Camera) Render(scene *Scene, models ...*Model) {
 
+	if camera.CullingMask != RenderLayerAll {
+		culled := make([]*Model, 0, len(models))
+		for _, model := range models {
+			if model.RenderLayers&camera.CullingMask != 0 {
+				culled = append(culled, model)
+			}
+		}
+		models = culled
+	}
+
+	// Any RenderTargets displayed on the Models' Materials need to be rendered first.
+	renderTargets(scene, models)
+
 	frametimeStart := time.Now()
 
 	sceneLights := []ILight{}
-	lights := sceneLights
 
 	if scene.World == nil || scene.World.LightingOn {
 
@@ -626,6 +1220,72 @@ func (camera *Camera) Render(scene *Scene, models ...*Model) {
 
 	}
 
+	var portalVis *portalVisibility
+	if camera.PortalCulling {
+		portalVis = camera.portalCulling(scene)
+	}
+
+	// Everything above is done once per frame; only drawing the Models themselves is done for each render layer.
+	modelGroups := [][]*Model{models}
+
+	if camera.ClearDepthBetweenLayers {
+
+		layers := [32][]*Model{}
+		layerCount := 0
+
+		for _, model := range models {
+			mask := model.RenderLayers & camera.CullingMask
+			if mask == 0 {
+				continue
+			}
+			layer := bits.TrailingZeros32(mask)
+			if len(layers[layer]) == 0 {
+				layerCount++
+			}
+			layers[layer] = append(layers[layer], model)
+		}
+
+		if layerCount > 1 {
+
+			modelGroups = modelGroups[:0]
+
+			for _, layerModels := range layers {
+				if len(layerModels) > 0 {
+					modelGroups = append(modelGroups, layerModels)
+				}
+			}
+
+		}
+
+	}
+
+	for i, groupModels := range modelGroups {
+
+		if i > 0 && camera.RenderDepth {
+			camera.resultDepthTexture.Clear()
+		}
+
+		camera.renderModels(scene, groupModels, sceneLights, portalVis)
+
+	}
+
+	// The sky is drawn last, but behind everything that's been rendered.
+	if scene.World != nil && scene.World.SkyMode != SkyOff {
+		camera.drawSky(scene.World)
+	}
+
+	camera.DebugInfo.frameTime += time.Since(frametimeStart)
+
+	camera.DebugInfo.frameCount++
+
+}
+
+// renderModels renders the given Models using the provided Scene's properties, lit by the given lights. If portalVis isn't nil,
+// Models that can't be seen through the Scene's portals are skipped.
+func (camera *Camera) renderModels(scene *Scene, models []*Model, sceneLights []ILight, portalVis *portalVisibility) {
+
+	lights := sceneLights
+
 	// By multiplying the camera's position against the view matrix (which contains the negated camera position), we're left with just the rotation
 	// matrix, which we feed into model.TransformedVertices() to draw vertices in order of distance.
 	vpMatrix := camera.ViewMatrix().Mult(camera.Projection())
@@ -634,22 +1294,63 @@ func (camera *Camera) Render(scene *Scene, models ...*Model) {
 	rectShaderOptions.Images[0] = camera.colorIntermediate
 	rectShaderOptions.Images[1] = camera.depthIntermediate
 
+	fog := []float32{0, 0, 0, 0}
+	noFog := fog
+
 	if scene != nil && scene.World != nil {
 
+		fog = scene.World.fogAsFloatSlice()
+
 		rectShaderOptions.Uniforms = map[string]interface{}{
-			"Fog":      scene.World.fogAsFloatSlice(),
-			"FogRange": scene.World.FogRange,
+			"Fog":        fog,
+			"FogRange":   scene.World.FogRange,
+			"FogCurve":   float32(scene.World.FogCurve),
+			"FogDensity": scene.World.FogDensity,
+			"FogHeight":  []float32{scene.World.FogHeight, scene.World.FogHeightFalloff},
 		}
 
 	} else {
 
 		rectShaderOptions.Uniforms = map[string]interface{}{
-			"Fog":      []float32{0, 0, 0, 0},
+			"Fog":      fog,
 			"FogRange": []float32{0, 1},
 		}
 
 	}
 
+	// The lighting shader for Materials using LightingModePixel reconstructs each fragment's view-space position from its screen position
+	// and its distance from the camera; the lights are set for each Model in camera.setPixelLights().
+	projection := camera.Projection()
+	pixelLightUniforms := map[string]interface{}{
+		"Far": float32(camera.Far),
+	}
+
+	if camera.Perspective {
+		k := 2 * camera.Far * camera.Near / (camera.Far - camera.Near)
+		pixelLightUniforms["Perspective"] = float32(1)
+		pixelLightUniforms["ProjectionFactor"] = []float32{float32(k / projection[0][0]), float32(k / projection[1][1])}
+	} else {
+		pixelLightUniforms["Perspective"] = float32(0)
+		pixelLightUniforms["ProjectionFactor"] = []float32{float32(1 / projection[0][0]), float32(1 / projection[1][1])}
+	}
+
+	viewRotation := camera.WorldRotation().Transposed()
+
+	// Exponential and height fog need the distance of each fragment from the camera (which is recovered from the depth texture),
+	// and height fog needs its world-space height as well.
+	rectShaderOptions.Uniforms["Perspective"] = pixelLightUniforms["Perspective"]
+	rectShaderOptions.Uniforms["ProjectionFactor"] = pixelLightUniforms["ProjectionFactor"]
+	rectShaderOptions.Uniforms["CameraHeight"] = float32(camera.WorldPosition()[1])
+
+	worldRotation := camera.WorldRotation()
+	rectShaderOptions.Uniforms["ViewToWorldY"] = []float32{float32(worldRotation[0][1]), float32(worldRotation[1][1]), float32(worldRotation[2][1])}
+
+	depthScale, depthOffset := camera.depthToDistance()
+	rectShaderOptions.Uniforms["DepthToDistance"] = []float32{float32(depthScale), float32(depthOffset)}
+
+	pixelLighting := false
+	pixelVertexLighting := false
+
 	// Reusing vectors rather than reallocating for all triangles for all models
 	p0 := vector.Vector{0, 0, 0, 0}
 	p1 := vector.Vector{0, 0, 0, 0}
@@ -666,6 +1367,12 @@ func (camera *Camera) Render(scene *Scene, models ...*Model) {
 			continue
 		}
 
+		if portalVis != nil && len(model.DynamicBatchModels) == 0 && len(model.Instances) == 0 && !portalVis.modelVisible(model) {
+			continue
+		}
+
+		camera.updateLOD(model)
+
 		if len(model.DynamicBatchModels) > 0 {
 
 			dynamicDepths := map[*Model]float64{}
@@ -676,10 +1383,12 @@ func (camera *Camera) Render(scene *Scene, models ...*Model) {
 
 				for _, child := range modelSlice {
 
-					if !child.visible {
+					if !child.visible || (portalVis != nil && !portalVis.modelVisible(child)) {
 						continue
 					}
 
+					camera.updateLOD(child)
+
 					dynamicDepths[child] = camera.WorldToScreen(child.WorldPosition())[2]
 
 					if !transparent {
@@ -752,6 +1461,13 @@ func (camera *Camera) Render(scene *Scene, models ...*Model) {
 		near = 0
 	}
 
+	// If triangleFilter is set, render() only renders the triangles of the MeshPart's Mesh whose IDs are set to true in it; the other
+	// triangles are left as ProcessVertices() marked them, so they can be rendered later on without transforming the vertices again.
+	var triangleFilter []bool
+
+	// If reuseVertices is set, render() uses the vertices as they were last transformed, rather than transforming them again.
+	reuseVertices := false
+
 	render := func(rp renderPair) {
 
 		startingVertexListIndex := vertexListIndex
@@ -775,11 +1491,15 @@ func (camera *Camera) Render(scene *Scene, models ...*Model) {
 			}
 		}
 
+		pixelLit := lighting && mat != nil && mat.LightingMode == LightingModePixel
+		pixelLighting = pixelLit
+
 		camera.DebugInfo.TotalParts++
 		camera.DebugInfo.TotalTris += meshPart.TriangleCount()
 
 		model.Transform()
 
+		// Instanced Models are culled for each Instance instead (and are rendered through proxies that have FrustumCulling off).
 		if model.FrustumCulling {
 
 			if !camera.SphereInFrustum(model.BoundingSphere) {
@@ -792,7 +1512,9 @@ func (camera *Camera) Render(scene *Scene, models ...*Model) {
 			camera.DebugInfo.BatchedParts++
 		}
 
-		model.ProcessVertices(vpMatrix, camera, meshPart, scene)
+		if !reuseVertices {
+			model.ProcessVertices(vpMatrix, camera, meshPart, scene)
+		}
 
 		backfaceCulling := true
 		if mat != nil {
@@ -832,6 +1554,16 @@ func (camera *Camera) Render(scene *Scene, models ...*Model) {
 		maxSpan := model.Mesh.Dimensions.MaxSpan()
 		modelPos := model.WorldPosition()
 
+		// For pixel lighting, the vertex lights are just the ones that the lighting shader can't handle.
+		vertexLights := lights
+		var normalMatrix Matrix4
+
+		if pixelLit {
+			vertexLights = camera.setPixelLights(lights, model, viewRotation, pixelLightUniforms)
+			pixelVertexLighting = len(vertexLights) > 0
+			normalMatrix = model.WorldRotation().Mult(viewRotation)
+		}
+
 		// Here we do all vertex transforms first because of data locality (it's faster to access all vertex transformations, then go back and do all UV values, etc)
 
 		for t := range meshPart.sortingTriangles {
@@ -840,6 +1572,10 @@ func (camera *Camera) Render(scene *Scene, models ...*Model) {
 				continue
 			}
 
+			if triangleFilter != nil && !triangleFilter[meshPart.sortingTriangles[t].ID] {
+				continue
+			}
+
 			meshPart.sortingTriangles[t].rendered = false
 
 			vertIndex := meshPart.sortingTriangles[t].ID * 3
@@ -911,141 +1647,246 @@ func (camera *Camera) Render(scene *Scene, models ...*Model) {
 			return
 		}
 
-		vertexListIndex = startingVertexListIndex
-
 		mpColor := model.Color.Clone()
 
 		if meshPart.Material != nil {
 			mpColor.MultiplyRGBA(meshPart.Material.Color.ToFloat32s())
 		}
 
-		for _, tri := range meshPart.sortingTriangles {
+		// Each rendered triangle's vertices have a known place in the vertex lists, so the triangles can be processed across
+		// multiple goroutines.
+		renderedTris := camera.renderedTriangles[:0]
 
-			if !tri.rendered {
-				continue
+		for t, tri := range meshPart.sortingTriangles {
+			if tri.rendered && (triangleFilter == nil || triangleFilter[tri.ID]) {
+				renderedTris = append(renderedTris, t)
 			}
+		}
 
-			for i := 0; i < 3; i++ {
+		camera.renderedTriangles = renderedTris
 
-				vertIndex := tri.ID*3 + i
+		processTriangles := func(start, end int) {
 
-				// We set the UVs back here because we might need to use them if the material has clip alpha enabled.
-				u := float32(mesh.VertexUVs[vertIndex][0] * srcW)
-				// We do 1 - v here (aka Y in texture coordinates) because 1.0 is the top of the texture while 0 is the bottom in UV coordinates,
-				// but when drawing textures 0 is the top, and the sourceHeight is the bottom.
-				v := float32((1 - mesh.VertexUVs[vertIndex][1]) * srcH)
+			lightTime := time.Duration(0)
 
-				colorVertexList[vertexListIndex+i].SrcX = u
-				colorVertexList[vertexListIndex+i].SrcY = v
+			for r := start; r < end; r++ {
 
-				// Vertex colors
+				tri := meshPart.sortingTriangles[renderedTris[r]]
+				vertexListIndex := startingVertexListIndex + r*3
 
-				if activeChannel := mesh.VertexActiveColorChannel[vertIndex]; activeChannel >= 0 {
-					colorVertexList[vertexListIndex+i].ColorR = mesh.VertexColors[vertIndex][activeChannel].R * mpColor.R
-					colorVertexList[vertexListIndex+i].ColorG = mesh.VertexColors[vertIndex][activeChannel].G * mpColor.G
-					colorVertexList[vertexListIndex+i].ColorB = mesh.VertexColors[vertIndex][activeChannel].B * mpColor.B
-					colorVertexList[vertexListIndex+i].ColorA = mesh.VertexColors[vertIndex][activeChannel].A * mpColor.A
-				} else {
-					colorVertexList[vertexListIndex+i].ColorR = mpColor.R
-					colorVertexList[vertexListIndex+i].ColorG = mpColor.G
-					colorVertexList[vertexListIndex+i].ColorB = mpColor.B
-					colorVertexList[vertexListIndex+i].ColorA = mpColor.A
-				}
+				for i := 0; i < 3; i++ {
 
-				if camera.RenderDepth {
+					vertIndex := tri.ID*3 + i
 
-					// We're adding 0.03 for a margin because for whatever reason, at close range / wide FOV,
-					// depth can be negative but still be in front of the camera and not behind it.
-					margin := 1.0
-					depth := mesh.vertexTransforms[vertIndex][2] / (far + margin)
-					if depth < 0 {
-						depth = 0
-					} else if depth > 1 {
-						depth = 1
+					// We set the UVs back here because we might need to use them if the material has clip alpha enabled.
+					u := float32(mesh.VertexUVs[vertIndex][0] * srcW)
+					// We do 1 - v here (aka Y in texture coordinates) because 1.0 is the top of the texture while 0 is the bottom in UV coordinates,
+					// but when drawing textures 0 is the top, and the sourceHeight is the bottom.
+					v := float32((1 - mesh.VertexUVs[vertIndex][1]) * srcH)
+
+					colorVertexList[vertexListIndex+i].SrcX = u
+					colorVertexList[vertexListIndex+i].SrcY = v
+
+					// Vertex colors
+
+					if activeChannel := mesh.VertexActiveColorChannel[vertIndex]; activeChannel >= 0 {
+						colorVertexList[vertexListIndex+i].ColorR = mesh.VertexColors[vertIndex][activeChannel].R * mpColor.R
+						colorVertexList[vertexListIndex+i].ColorG = mesh.VertexColors[vertIndex][activeChannel].G * mpColor.G
+						colorVertexList[vertexListIndex+i].ColorB = mesh.VertexColors[vertIndex][activeChannel].B * mpColor.B
+						colorVertexList[vertexListIndex+i].ColorA = mesh.VertexColors[vertIndex][activeChannel].A * mpColor.A
+					} else {
+						colorVertexList[vertexListIndex+i].ColorR = mpColor.R
+						colorVertexList[vertexListIndex+i].ColorG = mpColor.G
+						colorVertexList[vertexListIndex+i].ColorB = mpColor.B
+						colorVertexList[vertexListIndex+i].ColorA = mpColor.A
 					}
 
-					depthVertexList[vertexListIndex+i].ColorR = float32(depth)
-					depthVertexList[vertexListIndex+i].ColorG = float32(depth)
-					depthVertexList[vertexListIndex+i].ColorB = float32(depth)
-					depthVertexList[vertexListIndex+i].ColorA = 1
+					if camera.RenderDepth {
 
-					// We set the UVs back here because we might need to use them if the material has clip alpha enabled.
-					depthVertexList[vertexListIndex+i].SrcX = u
+						depth := camera.encodeDepth(mesh.vertexTransforms[vertIndex][2])
 
-					// We do 1 - v here (aka Y in texture coordinates) because 1.0 is the top of the texture while 0 is the bottom in UV coordinates,
-					// but when drawing textures 0 is the top, and the sourceHeight is the bottom.
-					depthVertexList[vertexListIndex+i].SrcY = v
+						depthVertexList[vertexListIndex+i].ColorR = float32(depth)
+						depthVertexList[vertexListIndex+i].ColorG = float32(depth)
+						depthVertexList[vertexListIndex+i].ColorB = float32(depth)
+						depthVertexList[vertexListIndex+i].ColorA = 1
 
-				} else if scene.World != nil && scene.World.FogMode != FogOff {
+						// We set the UVs back here because we might need to use them if the material has clip alpha enabled.
+						depthVertexList[vertexListIndex+i].SrcX = u
+
+						// We do 1 - v here (aka Y in texture coordinates) because 1.0 is the top of the texture while 0 is the bottom in UV coordinates,
+						// but when drawing textures 0 is the top, and the sourceHeight is the bottom.
+						depthVertexList[vertexListIndex+i].SrcY = v
+
+					} else if scene.World != nil && scene.World.FogMode != FogOff && (mat == nil || !mat.Fogless) {
+
+						// We're adding 0.03 for a margin because for whatever reason, at close range / wide FOV,
+						// depth can be negative but still be in front of the camera and not behind it.
+						depth := float32((mesh.vertexTransforms[vertIndex][2]+near)/far + 0.03)
+						if depth < 0 {
+							depth = 0
+						} else if depth > 1 {
+							depth = 1
+						}
+
+						// depth = 1 - depth
+
+						depth = scene.World.FogRange[0] + ((scene.World.FogRange[1]-scene.World.FogRange[0])*1 - depth)
+
+						if scene.World.FogMode == FogAdd {
+							colorVertexList[vertexListIndex+i].ColorR += scene.World.FogColor.R * depth
+							colorVertexList[vertexListIndex+i].ColorG += scene.World.FogColor.G * depth
+							colorVertexList[vertexListIndex+i].ColorB += scene.World.FogColor.B * depth
+						} else if scene.World.FogMode == FogMultiply {
+							colorVertexList[vertexListIndex+i].ColorR *= scene.World.FogColor.R * depth
+							colorVertexList[vertexListIndex+i].ColorG *= scene.World.FogColor.G * depth
+							colorVertexList[vertexListIndex+i].ColorB *= scene.World.FogColor.B * depth
+						}
 
-					// We're adding 0.03 for a margin because for whatever reason, at close range / wide FOV,
-					// depth can be negative but still be in front of the camera and not behind it.
-					depth := float32((mesh.vertexTransforms[vertIndex][2]+near)/far + 0.03)
-					if depth < 0 {
-						depth = 0
-					} else if depth > 1 {
-						depth = 1
 					}
 
-					// depth = 1 - depth
+					if pixelLit {
+
+						// View-space normals are packed into the 0-1 range of the vertex colors.
+						var nx, ny, nz float64
+						if model.Skinned {
+							nx, ny, nz = fastMatrixMultVec(viewRotation, mesh.vertexSkinnedNormals[vertIndex])
+						} else {
+							nx, ny, nz = fastMatrixMultVec(normalMatrix, mesh.VertexNormals[vertIndex])
+						}
+
+						normalVertexList[vertexListIndex+i].DstX = colorVertexList[vertexListIndex+i].DstX
+						normalVertexList[vertexListIndex+i].DstY = colorVertexList[vertexListIndex+i].DstY
+						normalVertexList[vertexListIndex+i].ColorR = float32(nx*0.5 + 0.5)
+						normalVertexList[vertexListIndex+i].ColorG = float32(ny*0.5 + 0.5)
+						normalVertexList[vertexListIndex+i].ColorB = float32(nz*0.5 + 0.5)
+						normalVertexList[vertexListIndex+i].ColorA = 1
+
+						// The distance from the camera plane can be recovered from the clip-space coordinates directly.
+						var viewDist float64
+						if camera.Perspective {
+							viewDist = mesh.vertexTransforms[vertIndex][3] * (camera.Far - camera.Near) / (2 * camera.Far * camera.Near)
+						} else {
+							viewDist = mesh.vertexTransforms[vertIndex][2] * (camera.Far - camera.Near) / 2
+						}
+
+						viewDepth := viewDist / camera.Far
+						if viewDepth < 0 {
+							viewDepth = 0
+						} else if viewDepth > 1 {
+							viewDepth = 1
+						}
+
+						viewDepthVertexList[vertexListIndex+i].DstX = colorVertexList[vertexListIndex+i].DstX
+						viewDepthVertexList[vertexListIndex+i].DstY = colorVertexList[vertexListIndex+i].DstY
+						viewDepthVertexList[vertexListIndex+i].ColorR = float32(viewDepth)
+						viewDepthVertexList[vertexListIndex+i].ColorA = 1
 
-					depth = scene.World.FogRange[0] + ((scene.World.FogRange[1]-scene.World.FogRange[0])*1 - depth)
+						// UV values are stored unscaled, as the normal, specular, and emissive textures may all be different sizes.
+						uvVertexList[vertexListIndex+i].SrcX = float32(mesh.VertexUVs[vertIndex][0])
+						uvVertexList[vertexListIndex+i].SrcY = float32(1 - mesh.VertexUVs[vertIndex][1])
 
-					if scene.World.FogMode == FogAdd {
-						colorVertexList[vertexListIndex+i].ColorR += scene.World.FogColor.R * depth
-						colorVertexList[vertexListIndex+i].ColorG += scene.World.FogColor.G * depth
-						colorVertexList[vertexListIndex+i].ColorB += scene.World.FogColor.B * depth
-					} else if scene.World.FogMode == FogMultiply {
-						colorVertexList[vertexListIndex+i].ColorR *= scene.World.FogColor.R * depth
-						colorVertexList[vertexListIndex+i].ColorG *= scene.World.FogColor.G * depth
-						colorVertexList[vertexListIndex+i].ColorB *= scene.World.FogColor.B * depth
 					}
 
 				}
 
-			}
+				if pixelLit && mat.NormalTexture != nil {
 
-			if lighting {
+					var tangent, bitangent vector.Vector
 
-				t := time.Now()
+					if model.Skinned {
+						tangent, bitangent = triangleTangents(mesh, tri.ID, mesh.vertexSkinnedPositions)
+						tangent = viewRotation.MultVec(tangent)
+						bitangent = viewRotation.MultVec(bitangent)
+					} else {
+						tangent, bitangent = triangleTangents(mesh, tri.ID, mesh.VertexPositions)
+						tangent = normalMatrix.MultVec(tangent)
+						bitangent = normalMatrix.MultVec(bitangent)
+					}
 
-				addLightResults := [9]float32{}
+					for i := 0; i < 3; i++ {
+						tangentVertexList[vertexListIndex+i].DstX = colorVertexList[vertexListIndex+i].DstX
+						tangentVertexList[vertexListIndex+i].DstY = colorVertexList[vertexListIndex+i].DstY
+						tangentVertexList[vertexListIndex+i].ColorR = float32(tangent[0]*0.5 + 0.5)
+						tangentVertexList[vertexListIndex+i].ColorG = float32(tangent[1]*0.5 + 0.5)
+						tangentVertexList[vertexListIndex+i].ColorB = float32(tangent[2]*0.5 + 0.5)
+						tangentVertexList[vertexListIndex+i].ColorA = 1
+
+						bitangentVertexList[vertexListIndex+i].DstX = colorVertexList[vertexListIndex+i].DstX
+						bitangentVertexList[vertexListIndex+i].DstY = colorVertexList[vertexListIndex+i].DstY
+						bitangentVertexList[vertexListIndex+i].ColorR = float32(bitangent[0]*0.5 + 0.5)
+						bitangentVertexList[vertexListIndex+i].ColorG = float32(bitangent[1]*0.5 + 0.5)
+						bitangentVertexList[vertexListIndex+i].ColorB = float32(bitangent[2]*0.5 + 0.5)
+						bitangentVertexList[vertexListIndex+i].ColorA = 1
+					}
 
-				for _, light := range lights {
+				}
 
-					if point, ok := light.(*PointLight); ok && point.Distance > 0 {
-						dist := maxSpan + point.Distance
-						if fastVectorDistanceSquared(modelPos, point.WorldPosition()) > dist*dist {
-							continue
+				if lighting {
+
+					t := time.Now()
+
+					addLightResults := [9]float32{}
+
+					for _, light := range vertexLights {
+
+						if point, ok := light.(*PointLight); ok && point.Distance > 0 {
+							dist := maxSpan + point.Distance
+							if fastVectorDistanceSquared(modelPos, point.WorldPosition()) > dist*dist {
+								continue
+							}
+						} else if spot, ok := light.(*SpotLight); ok && spot.Distance > 0 {
+							dist := maxSpan + spot.Distance
+							if fastVectorDistanceSquared(modelPos, spot.WorldPosition()) > dist*dist {
+								continue
+							}
+						} else if cube, ok := light.(*CubeLight); ok && cube.Distance > 0 {
+							dist := maxSpan + cube.Distance
+							if fastVectorDistanceSquared(modelPos, cube.WorldPosition()) > dist*dist {
+								continue
+							}
 						}
-					} else if cube, ok := light.(*CubeLight); ok && cube.Distance > 0 {
-						dist := maxSpan + cube.Distance
-						if fastVectorDistanceSquared(modelPos, cube.WorldPosition()) > dist*dist {
-							continue
+
+						lightResults := light.Light(tri.ID, model)
+						for i := 0; i < 9; i++ {
+							addLightResults[i] += lightResults[i]
 						}
 					}
 
-					lightResults := light.Light(tri.ID, model)
-					for i := 0; i < 9; i++ {
-						addLightResults[i] += lightResults[i]
+					if pixelLit {
+
+						// Lights that are calculated per-vertex are rendered separately and added on top of the pixel lighting.
+						for i := 0; i < 3; i++ {
+							vertexLightVertexList[vertexListIndex+i] = colorVertexList[vertexListIndex+i]
+							vertexLightVertexList[vertexListIndex+i].ColorR *= addLightResults[i*3]
+							vertexLightVertexList[vertexListIndex+i].ColorG *= addLightResults[i*3+1]
+							vertexLightVertexList[vertexListIndex+i].ColorB *= addLightResults[i*3+2]
+						}
+
+					} else {
+
+						for i := 0; i < 3; i++ {
+							colorVertexList[vertexListIndex+i].ColorR *= addLightResults[i*3]
+							colorVertexList[vertexListIndex+i].ColorG *= addLightResults[i*3+1]
+							colorVertexList[vertexListIndex+i].ColorB *= addLightResults[i*3+2]
+						}
+
 					}
-				}
 
-				for i := 0; i < 3; i++ {
-					colorVertexList[vertexListIndex+i].ColorR *= addLightResults[i*3]
-					colorVertexList[vertexListIndex+i].ColorG *= addLightResults[i*3+1]
-					colorVertexList[vertexListIndex+i].ColorB *= addLightResults[i*3+2]
-				}
+					lightTime += time.Since(t)
 
-				camera.DebugInfo.lightTime += time.Since(t)
+				}
 
 			}
 
-			vertexListIndex += 3
+			atomic.AddInt64((*int64)(&camera.DebugInfo.lightTime), int64(lightTime))
 
 		}
 
-		for i := 0; i < vertexListIndex; i++ {
+		camera.processInParallel(len(renderedTris), processTriangles)
+
+		vertexListIndex = startingVertexListIndex + len(renderedTris)*3
+
+		for i := startingVertexListIndex; i < vertexListIndex; i++ {
 			indexList[i] = uint16(i)
 		}
 
@@ -1136,6 +1977,12 @@ func (camera *Camera) Render(scene *Scene, models ...*Model) {
 		hasFragShader := mat != nil && mat.fragmentShader != nil && mat.FragmentShaderOn
 		w, h := camera.resultColorTexture.Size()
 
+		if pixelLighting && camera.litIntermediate == nil {
+			for _, img := range camera.pixelLightingTextures() {
+				*img = ebiten.NewImage(w, h)
+			}
+		}
+
 		// If rendering depth, and rendering through a custom fragment shader, we'll need to render the tris to the ColorIntermediate buffer using the custom shader.
 		// If we're not rendering through a custom shader, we can render to ColorIntermediate and then composite that onto the finished ColorTexture.
 		// If we're not rendering depth, but still rendering through the shader, we can render to the intermediate texture, and then from there composite.
@@ -1144,12 +1991,20 @@ func (camera *Camera) Render(scene *Scene, models ...*Model) {
 		t.CompositeMode = ebiten.CompositeModeSourceOver
 		rectShaderOptions.CompositeMode = ebiten.CompositeModeSourceOver
 
+		// Pixel-lit MeshParts are rendered unlit to ColorIntermediate, and then lit by the lighting shader; the result is then
+		// composited like any other render.
+
 		if camera.RenderDepth {
 
 			camera.colorIntermediate.Clear()
 
+			rectShaderOptions.Uniforms["Fog"] = fog
+
 			if mat != nil {
 				rectShaderOptions.CompositeMode = mat.CompositeMode
+				if mat.Fogless {
+					rectShaderOptions.Uniforms["Fog"] = noFog
+				}
 			}
 
 			if hasFragShader {
@@ -1158,8 +2013,27 @@ func (camera *Camera) Render(scene *Scene, models ...*Model) {
 				camera.colorIntermediate.DrawTriangles(colorVertexList[:vertexListIndex], indexList[:vertexListIndex], img, t)
 			}
 
+			if pixelLighting {
+				rectShaderOptions.Images[0] = camera.renderPixelLighting(mat, img, t, pixelVertexLighting, pixelLightUniforms)
+			} else {
+				rectShaderOptions.Images[0] = camera.colorIntermediate
+			}
+
 			camera.resultColorTexture.DrawRectShader(w, h, camera.colorShader, rectShaderOptions)
 
+		} else if pixelLighting {
+
+			camera.colorIntermediate.Clear()
+
+			if hasFragShader {
+				camera.colorIntermediate.DrawTrianglesShader(colorVertexList[:vertexListIndex], indexList[:vertexListIndex], mat.fragmentShader, mat.FragmentShaderOptions)
+			} else {
+				camera.colorIntermediate.DrawTriangles(colorVertexList[:vertexListIndex], indexList[:vertexListIndex], img, t)
+			}
+
+			lit := camera.renderPixelLighting(mat, img, t, pixelVertexLighting, pixelLightUniforms)
+			camera.resultColorTexture.DrawImage(lit, &ebiten.DrawImageOptions{CompositeMode: mat.CompositeMode})
+
 		} else {
 
 			if mat != nil {
@@ -1181,12 +2055,62 @@ func (camera *Camera) Render(scene *Scene, models ...*Model) {
 
 	}
 
-	for _, pair := range solids {
+	// Instanced Models render their Mesh once for each visible Instance, only flushing when the vertex lists can't hold another Instance.
+	renderInstances := func(pair renderPair) {
+
+		ir := newInstanceRenderer(pair.Model)
+
+		instances, transforms := ir.visibleInstances(camera)
+
+		if pair.Model.isTransparent(pair.MeshPart) {
+
+			camPos := camera.WorldPosition()
+			order := make([]int, len(instances))
+			distances := make([]float64, len(instances))
+			for i := range instances {
+				order[i] = i
+				distances[i] = fastVectorDistanceSquared(transforms[i].Row(3)[:3], camPos)
+			}
+
+			sort.SliceStable(order, func(i, j int) bool { return distances[order[i]] > distances[order[j]] })
+
+			sortedInstances := make([]*Instance, len(instances))
+			sortedTransforms := make([]Matrix4, len(instances))
+			for i, o := range order {
+				sortedInstances[i] = instances[o]
+				sortedTransforms[i] = transforms[o]
+			}
+			instances, transforms = sortedInstances, sortedTransforms
+
+		}
+
+		triCount := pair.MeshPart.TriangleCount()
+
+		for i, instance := range instances {
+
+			if vertexListIndex+triCount*3 > ebiten.MaxIndicesNum {
+				flush(renderPair{Model: ir.proxy, MeshPart: pair.MeshPart})
+			}
+
+			proxyPair := renderPair{Model: ir.begin(instance, transforms[i]), MeshPart: pair.MeshPart}
+			render(proxyPair)
+
+			// Pixel lighting's uniforms are set for the Model being rendered (as lights are culled by their distance from it), so
+			// pixel-lit Instances can't be batched together.
+			if pixelLighting {
+				flush(proxyPair)
+			}
 
-		if !pair.Model.visible {
-			continue
 		}
 
+		flush(renderPair{Model: ir.proxy, MeshPart: pair.MeshPart})
+
+	}
+
+	// renderWhole renders all of the triangles of the given renderPair, including those of any Models dynamically batched into it,
+	// or of any of its Instances.
+	renderWhole := func(pair renderPair) {
+
 		// Internally, the idea behind dynamic batching is that we simply hold off on flushing until the
 		// end - this saves a lot of time if we're rendering singular low-poly objects, at the cost of each
 		// object sharing the same material / object-level properties (color / material blending mode, for
@@ -1207,6 +2131,8 @@ func (camera *Camera) Render(scene *Scene, models ...*Model) {
 			}
 
 			flush(pair)
+		} else if len(pair.Model.Instances) > 0 {
+			renderInstances(pair)
 		} else {
 			render(pair)
 			flush(pair)
@@ -1214,47 +2140,575 @@ func (camera *Camera) Render(scene *Scene, models ...*Model) {
 
 	}
 
+	for _, pair := range solids {
+
+		if !pair.Model.visible {
+			continue
+		}
+
+		renderWhole(pair)
+
+	}
+
 	if len(transparents) > 0 {
 
-		sort.SliceStable(transparents, func(i, j int) bool {
-			return depths[transparents[i].Model] > depths[transparents[j].Model]
-		})
+		if camera.TransparencySortMode == TransparencySortModeTriangles {
+
+			// Transparent triangles are sorted together, regardless of which Model they belong to; consecutive triangles belonging to
+			// the same Model and MeshPart are then rendered together, and consecutive runs of triangles using the same Material are
+			// drawn together where possible. Dynamically batched and instanced Models are sorted as a whole.
+			type transparentTriangle struct {
+				pair  renderPair
+				id    int // The ID of the triangle in the MeshPart's Mesh, or -1 if the whole renderPair is rendered at once.
+				depth float64
+			}
 
-		for _, pair := range transparents {
+			triangles := []transparentTriangle{}
 
-			if !pair.Model.visible {
-				continue
+			// The Model whose vertices each Mesh's vertex transforms currently hold; Models sharing a Mesh overwrite each other's.
+			meshOwners := map[*Mesh]*Model{}
+
+			viewDepth := func(clip vector.Vector) float64 {
+				if camera.Perspective {
+					return clip[3]
+				}
+				return clip[2]
 			}
 
-			if dyn := pair.Model.DynamicBatchModels; len(dyn) > 0 {
+			for _, pair := range transparents {
 
-				modelSlice := dyn[pair.MeshPart]
+				model := pair.Model
 
-				for _, merged := range modelSlice {
+				if !model.visible || model.Mesh == nil {
+					continue
+				}
 
-					if !merged.visible {
+				if len(model.DynamicBatchModels) > 0 || len(model.Instances) > 0 {
+					triangles = append(triangles, transparentTriangle{pair: pair, id: -1, depth: viewDepth(camera.WorldToClip(model.WorldPosition()))})
+					continue
+				}
+
+				model.Transform()
+
+				if model.FrustumCulling && !camera.SphereInFrustum(model.BoundingSphere) {
+					continue
+				}
+
+				model.ProcessVertices(vpMatrix, camera, pair.MeshPart, scene)
+				meshOwners[model.Mesh] = model
+
+				for _, tri := range pair.MeshPart.sortingTriangles {
+
+					if !tri.rendered {
 						continue
 					}
 
-					for _, part := range merged.Mesh.MeshParts {
-						render(renderPair{Model: merged, MeshPart: part})
+					depth := 0.0
+					for i := 0; i < 3; i++ {
+						depth += viewDepth(model.Mesh.vertexTransforms[tri.ID*3+i])
 					}
+
+					triangles = append(triangles, transparentTriangle{pair: pair, id: tri.ID, depth: depth / 3})
+
 				}
 
-				flush(pair)
-			} else {
+			}
+
+			sort.SliceStable(triangles, func(i, j int) bool {
+				return triangles[i].depth > triangles[j].depth
+			})
+
+			// Runs of triangles are drawn together with a single flush as long as they use the same Material, and nothing specific
+			// to the Model is needed to flush them (i.e. a ColorBlendingFunc, or the lights set for pixel lighting).
+			var pending *renderPair
+
+			flushPending := func() {
+				if pending != nil {
+					flush(*pending)
+					pending = nil
+				}
+			}
+
+			batchable := func(pair renderPair) bool {
+				mat := pair.MeshPart.Material
+				return pair.Model.ColorBlendingFunc == nil && (mat == nil || mat.LightingMode != LightingModePixel)
+			}
+
+			for start := 0; start < len(triangles); {
+
+				pair := triangles[start].pair
+
+				if triangles[start].id < 0 {
+					flushPending()
+					renderWhole(pair)
+					// Batched and instanced Models may transform the vertices of any Mesh.
+					meshOwners = map[*Mesh]*Model{}
+					start++
+					continue
+				}
+
+				end := start + 1
+				for end < len(triangles) && triangles[end].pair == pair && triangles[end].id >= 0 {
+					end++
+				}
+
+				if pending != nil && (pending.MeshPart.Material != pair.MeshPart.Material || !batchable(*pending) || !batchable(pair) || vertexListIndex+(end-start)*3 > ebiten.MaxIndicesNum) {
+					flushPending()
+				}
+
+				if len(camera.triangleFilter) < len(pair.Model.Mesh.Triangles) {
+					camera.triangleFilter = make([]bool, len(pair.Model.Mesh.Triangles))
+				}
+
+				for i := start; i < end; i++ {
+					camera.triangleFilter[triangles[i].id] = true
+				}
+
+				// The vertices were transformed when the triangles were gathered, so they only need to be transformed again if another
+				// Model using the same Mesh has been transformed since.
+				reuseVertices = meshOwners[pair.Model.Mesh] == pair.Model
+				triangleFilter = camera.triangleFilter
 				render(pair)
-				flush(pair)
+				triangleFilter = nil
+				reuseVertices = false
+				meshOwners[pair.Model.Mesh] = pair.Model
+
+				for i := start; i < end; i++ {
+					camera.triangleFilter[triangles[i].id] = false
+				}
+
+				pending = &renderPair{Model: pair.Model, MeshPart: pair.MeshPart}
+				start = end
+
+			}
+
+			flushPending()
+
+		} else {
+
+			sort.SliceStable(transparents, func(i, j int) bool {
+				return depths[transparents[i].Model] > depths[transparents[j].Model]
+			})
+
+			for _, pair := range transparents {
+
+				if !pair.Model.visible {
+					continue
+				}
+
+				renderWhole(pair)
+
 			}
 
 		}
 
 	}
 
-	camera.DebugInfo.frameTime += time.Since(frametimeStart)
+}
 
-	camera.DebugInfo.frameCount++
+// drawSky draws the World's sky behind anything already rendered to the Camera's color texture.
+func (camera *Camera) drawSky(world *World) {
+
+	var img *ebiten.Image
+
+	if world.SkyMode == SkyEquirectangular || world.SkyMode == SkyCubemap {
+		if world.SkyTexture == nil {
+			return
+		}
+		img = world.SkyTexture
+	}
+
+	w, h := camera.resultColorTexture.Size()
+	srcW, srcH := 1, 1
+	if img != nil {
+		srcW, srcH = img.Size()
+	}
+
+	projection := camera.Projection()
+	rotation := camera.WorldRotation()
+
+	// View directions vary linearly across the screen, so the shader can interpolate them from the directions of the corners.
+	vertices := make([]ebiten.Vertex, 4)
+
+	for i := range vertices {
+
+		x := float64(i % 2)
+		y := float64(i / 2)
+
+		dir := rotation.Forward().Invert()
+
+		if camera.Perspective {
+			k := 2 * camera.Far * camera.Near / (camera.Far - camera.Near)
+			dir = rotation.MultVec(vector.Vector{(x - 0.5) * k / projection[0][0], (0.5 - y) * k / projection[1][1], -1})
+		}
+
+		vertices[i] = ebiten.Vertex{
+			DstX:   float32(x * float64(w)),
+			DstY:   float32(y * float64(h)),
+			SrcX:   float32(x * float64(srcW)),
+			SrcY:   float32(y * float64(srcH)),
+			ColorR: float32(dir[0]),
+			ColorG: float32(dir[1]),
+			ColorB: float32(dir[2]),
+			ColorA: 1,
+		}
+
+	}
+
+	sunSize := []float32{1, 1}
+	if world.SunSize > 0 {
+		radius := world.SunSize / 2 * math.Pi / 180
+		sunSize = []float32{float32(math.Cos(radius)), float32(math.Cos(radius * 0.85))}
+	}
+
+	sunDir := world.SunDirection.Unit()
+
+	options := &ebiten.DrawTrianglesShaderOptions{
+		CompositeMode: ebiten.CompositeModeDestinationOver,
+		Images:        [4]*ebiten.Image{img},
+		Uniforms: map[string]interface{}{
+			"SkyMode":      float32(world.SkyMode),
+			"TopColor":     []float32{world.SkyTopColor.R, world.SkyTopColor.G, world.SkyTopColor.B},
+			"HorizonColor": []float32{world.SkyHorizonColor.R, world.SkyHorizonColor.G, world.SkyHorizonColor.B},
+			"BottomColor":  []float32{world.SkyBottomColor.R, world.SkyBottomColor.G, world.SkyBottomColor.B},
+			"SunDirection": []float32{float32(sunDir[0]), float32(sunDir[1]), float32(sunDir[2])},
+			"SunColor":     []float32{world.SunColor.R, world.SunColor.G, world.SunColor.B},
+			"SunSize":      sunSize,
+		},
+	}
+
+	camera.resultColorTexture.DrawTrianglesShader(vertices, []uint16{0, 1, 2, 1, 3, 2}, camera.skyShader, options)
+
+}
+
+// minWorkerTriangleCount is the minimum number of triangles a worker processes; splitting MeshParts further isn't worth
+// the overhead of starting goroutines.
+const minWorkerTriangleCount = 64
+
+// processInParallel calls process on consecutive ranges of count triangles, split between the Camera's Workers, and returns once
+// all of them have been processed.
+func (camera *Camera) processInParallel(count int, process func(start, end int)) {
+
+	workers := camera.Workers
+
+	if maxWorkers := count / minWorkerTriangleCount; workers > maxWorkers {
+		workers = maxWorkers
+	}
+
+	if workers <= 1 {
+		process(0, count)
+		return
+	}
+
+	chunkSize := (count + workers - 1) / workers
+
+	wg := sync.WaitGroup{}
+
+	for start := 0; start < count; start += chunkSize {
+
+		end := start + chunkSize
+		if end > count {
+			end = count
+		}
+
+		wg.Add(1)
+
+		go func(start, end int) {
+			process(start, end)
+			wg.Done()
+		}(start, end)
+
+	}
+
+	wg.Wait()
+
+}
+
+// setPixelLights sets the uniforms for the lighting shader to light the given Model using the lights provided. Lights that the
+// lighting shader can't handle (CubeLights, as well as any lights past the maximum of 8) are returned so they can be calculated per-vertex instead.
+func (camera *Camera) setPixelLights(lights []ILight, model *Model, viewRotation Matrix4, uniforms map[string]interface{}) []ILight {
+
+	vertexLights := []ILight{}
+
+	viewMatrix := camera.ViewMatrix()
+	maxSpan := model.Mesh.Dimensions.MaxSpan()
+	modelPos := model.WorldPosition()
+
+	positions := make([]float32, 8*3)
+	directions := make([]float32, 8*3)
+	colors := make([]float32, 8*3)
+	settings := make([]float32, 8*4)
+	ambient := []float32{0, 0, 0}
+
+	lightCount := 0
+
+	addLight := func(lightType int, color *Color, energy float32, position, direction vector.Vector, distance, cosInner, cosOuter float64) {
+
+		if position != nil {
+			position = viewMatrix.MultVec(position)
+			positions[lightCount*3] = float32(position[0])
+			positions[lightCount*3+1] = float32(position[1])
+			positions[lightCount*3+2] = float32(position[2])
+		}
+
+		if direction != nil {
+			direction = viewRotation.MultVec(direction).Unit()
+			directions[lightCount*3] = float32(direction[0])
+			directions[lightCount*3+1] = float32(direction[1])
+			directions[lightCount*3+2] = float32(direction[2])
+		}
+
+		colors[lightCount*3] = color.R * energy
+		colors[lightCount*3+1] = color.G * energy
+		colors[lightCount*3+2] = color.B * energy
 
+		settings[lightCount*4] = float32(lightType)
+		settings[lightCount*4+1] = float32(distance)
+		settings[lightCount*4+2] = float32(cosInner)
+		settings[lightCount*4+3] = float32(cosOuter)
+
+		lightCount++
+
+	}
+
+	for _, light := range lights {
+
+		switch l := light.(type) {
+
+		case *AmbientLight:
+			ambient[0] += l.Color.R * l.Energy
+			ambient[1] += l.Color.G * l.Energy
+			ambient[2] += l.Color.B * l.Energy
+			continue
+
+		case *PointLight:
+			if l.Distance > 0 {
+				dist := maxSpan + l.Distance
+				if fastVectorDistanceSquared(modelPos, l.WorldPosition()) > dist*dist {
+					continue
+				}
+			}
+			if lightCount < 8 {
+				addLight(0, l.Color, l.Energy, l.WorldPosition(), nil, l.Distance, 0, 0)
+				continue
+			}
+
+		case *DirectionalLight:
+			if lightCount < 8 {
+				addLight(1, l.Color, l.Energy, nil, l.WorldRotation().Forward(), 0, 0, 0)
+				continue
+			}
+
+		case *SpotLight:
+			if l.Distance > 0 {
+				dist := maxSpan + l.Distance
+				if fastVectorDistanceSquared(modelPos, l.WorldPosition()) > dist*dist {
+					continue
+				}
+			}
+			if lightCount < 8 {
+				addLight(2, l.Color, l.Energy, l.WorldPosition(), l.WorldRotation().Forward(), l.Distance, l.cosInner, l.cosOuter)
+				continue
+			}
+
+		}
+
+		vertexLights = append(vertexLights, light)
+
+	}
+
+	uniforms["LightCount"] = float32(lightCount)
+	uniforms["LightPositions"] = positions
+	uniforms["LightDirections"] = directions
+	uniforms["LightColors"] = colors
+	uniforms["LightSettings"] = settings
+	uniforms["AmbientLight"] = ambient
+
+	return vertexLights
+
+}
+
+// pixelLightingTextures returns pointers to the intermediate textures used to render Materials with LightingModePixel.
+func (camera *Camera) pixelLightingTextures() []**ebiten.Image {
+	return []**ebiten.Image{
+		&camera.normalIntermediate,
+		&camera.tangentIntermediate,
+		&camera.bitangentIntermediate,
+		&camera.normalMapIntermediate,
+		&camera.viewDepthIntermediate,
+		&camera.specularIntermediate,
+		&camera.vertexLightIntermediate,
+		&camera.emissiveIntermediate,
+		&camera.litIntermediate,
+	}
+}
+
+// drawPixelLightingTexture draws the triangles in the vertex lists to the destination image using the texture given (which is
+// sampled using the triangles' UV values) multiplied by the color provided. If the texture is nil, just the color is drawn.
+func (camera *Camera) drawPixelLightingTexture(dst, texture *ebiten.Image, color *Color, options *ebiten.DrawTrianglesOptions) {
+
+	srcW, srcH := float32(0), float32(0)
+
+	if texture != nil {
+		w, h := texture.Size()
+		srcW, srcH = float32(w), float32(h)
+	} else {
+		texture = defaultImg
+	}
+
+	for i := 0; i < vertexListIndex; i++ {
+		texturedVertexList[i].DstX = colorVertexList[i].DstX
+		texturedVertexList[i].DstY = colorVertexList[i].DstY
+		texturedVertexList[i].SrcX = uvVertexList[i].SrcX * srcW
+		texturedVertexList[i].SrcY = uvVertexList[i].SrcY * srcH
+		texturedVertexList[i].ColorR = color.R
+		texturedVertexList[i].ColorG = color.G
+		texturedVertexList[i].ColorB = color.B
+		texturedVertexList[i].ColorA = color.A
+	}
+
+	dst.Clear()
+	dst.DrawTriangles(texturedVertexList[:vertexListIndex], indexList[:vertexListIndex], texture, options)
+
+}
+
+// renderPixelLighting lights the unlit render of the triangles in ColorIntermediate using the Material's normal, specular, and emissive
+// textures, along with the normals and depth of the triangles in the vertex lists. It returns the intermediate texture holding the result.
+//
+// Note that the normal, depth, and other intermediate textures aren't depth-tested; like ColorIntermediate, they're drawn in the
+// MeshPart's triangle sorting order, so each pixel holds the data of the same triangle as ColorIntermediate does (and so shares any
+// sorting artifacts the unlit render has). Depth testing against the rest of the scene happens afterwards, when the returned texture
+// is composited using the depth texture (if Camera.RenderDepth is on).
+func (camera *Camera) renderPixelLighting(mat *Material, img *ebiten.Image, options *ebiten.DrawTrianglesOptions, vertexLighting bool, uniforms map[string]interface{}) *ebiten.Image {
+
+	w, h := camera.resultColorTexture.Size()
+
+	textureOptions := &ebiten.DrawTrianglesOptions{
+		Filter:  mat.TextureFilterMode,
+		Address: mat.TextureWrapMode,
+	}
+
+	camera.normalIntermediate.Clear()
+	camera.normalIntermediate.DrawTriangles(normalVertexList[:vertexListIndex], indexList[:vertexListIndex], defaultImg, nil)
+
+	if mat.NormalTexture != nil {
+
+		camera.tangentIntermediate.Clear()
+		camera.tangentIntermediate.DrawTriangles(tangentVertexList[:vertexListIndex], indexList[:vertexListIndex], defaultImg, nil)
+
+		camera.bitangentIntermediate.Clear()
+		camera.bitangentIntermediate.DrawTriangles(bitangentVertexList[:vertexListIndex], indexList[:vertexListIndex], defaultImg, nil)
+
+		camera.drawPixelLightingTexture(camera.normalMapIntermediate, mat.NormalTexture, NewColor(1, 1, 1, 1), textureOptions)
+
+		// The lit intermediate isn't in use yet, so the mapped normals are rendered to it and then copied back.
+		camera.litIntermediate.Clear()
+		camera.litIntermediate.DrawRectShader(w, h, camera.normalMapShader, &ebiten.DrawRectShaderOptions{
+			Images: [4]*ebiten.Image{camera.normalIntermediate, camera.tangentIntermediate, camera.bitangentIntermediate, camera.normalMapIntermediate},
+		})
+
+		camera.normalIntermediate.Clear()
+		camera.normalIntermediate.DrawImage(camera.litIntermediate, nil)
+
+	}
+
+	camera.viewDepthIntermediate.Clear()
+	camera.viewDepthIntermediate.DrawTrianglesShader(viewDepthVertexList[:vertexListIndex], indexList[:vertexListIndex], camera.viewDepthShader, nil)
+
+	specularOn := mat.Roughness < 1 || mat.SpecularTexture != nil
+
+	if specularOn {
+		camera.drawPixelLightingTexture(camera.specularIntermediate, mat.SpecularTexture, NewColor(1, mat.Roughness, mat.Metallic, 1), textureOptions)
+		uniforms["SpecularOn"] = float32(1)
+	} else {
+		uniforms["SpecularOn"] = float32(0)
+	}
+
+	camera.litIntermediate.Clear()
+	camera.litIntermediate.DrawRectShader(w, h, camera.lightingShader, &ebiten.DrawRectShaderOptions{
+		Uniforms: uniforms,
+		Images:   [4]*ebiten.Image{camera.colorIntermediate, camera.normalIntermediate, camera.viewDepthIntermediate, camera.specularIntermediate},
+	})
+
+	emissive := mat.EmissiveColor.R > 0 || mat.EmissiveColor.G > 0 || mat.EmissiveColor.B > 0
+
+	if !vertexLighting && !emissive {
+		return camera.litIntermediate
+	}
+
+	// Lights calculated per-vertex and emission are added on top in a final pass; the color intermediate has already been lit, so we can render to it.
+
+	camera.vertexLightIntermediate.Clear()
+	if vertexLighting {
+		camera.vertexLightIntermediate.DrawTriangles(vertexLightVertexList[:vertexListIndex], indexList[:vertexListIndex], img, options)
+	}
+
+	camera.emissiveIntermediate.Clear()
+	if emissive {
+		camera.drawPixelLightingTexture(camera.emissiveIntermediate, mat.EmissiveTexture, mat.EmissiveColor, textureOptions)
+	}
+
+	camera.colorIntermediate.Clear()
+	camera.colorIntermediate.DrawRectShader(w, h, camera.lightingCompositeShader, &ebiten.DrawRectShaderOptions{
+		Images: [4]*ebiten.Image{camera.litIntermediate, camera.vertexLightIntermediate, camera.emissiveIntermediate},
+	})
+
+	return camera.colorIntermediate
+
+}
+
+// PostProcess applies the Camera's active post-processing effects, in the order they're found in Camera.PostProcessEffects, to the
+// Camera's color texture. Call it after rendering everything for the frame, and before drawing the color texture.
+func (camera *Camera) PostProcess() {
+
+	active := false
+	for _, effect := range camera.PostProcessEffects {
+		if effect.IsActive() {
+			active = true
+			break
+		}
+	}
+
+	if !active {
+		return
+	}
+
+	if camera.postProcessBuffers[0] == nil {
+		w, h := camera.resultColorTexture.Size()
+		camera.postProcessBuffers[0] = ebiten.NewImage(w, h)
+		camera.postProcessBuffers[1] = ebiten.NewImage(w, h)
+	}
+
+	src, dst := camera.postProcessBuffers[0], camera.postProcessBuffers[1]
+
+	src.Clear()
+	src.DrawImage(camera.resultColorTexture, nil)
+
+	for _, effect := range camera.PostProcessEffects {
+
+		if !effect.IsActive() {
+			continue
+		}
+
+		dst.Clear()
+		effect.Apply(camera, src, dst)
+		src, dst = dst, src
+
+	}
+
+	camera.resultColorTexture.Clear()
+	camera.resultColorTexture.DrawImage(src, nil)
+
+}
+
+// PostProcessEffect returns the first post-processing effect in the Camera's PostProcessEffects stack with the given name, or nil if there isn't one.
+func (camera *Camera) PostProcessEffect(name string) IPostProcessEffect {
+	for _, effect := range camera.PostProcessEffects {
+		if effect.Name() == name {
+			return effect
+		}
+	}
+	return nil
 }
 
 func (camera *Camera) drawCircle(screen *ebiten.Image, position vector.Vector, radius float64, drawColor color.Color) {
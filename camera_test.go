@@ -0,0 +1,220 @@
+package tetra3d
+
+import (
+	"math"
+	"testing"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/kvartborg/vector"
+)
+
+func TestCameraDepthToDistance(t *testing.T) {
+
+	for _, perspective := range []bool{true, false} {
+
+		camera := NewCamera(320, 180)
+		if !perspective {
+			camera.SetOrthographic(20)
+		}
+		camera.Move(1, 2, 3)
+
+		vpMatrix := camera.ViewMatrix().Mult(camera.Projection())
+		scale, offset := camera.depthToDistance()
+
+		// In perspective, points closer than about 1 unit have a negative clip-space Z, and so are clamped to a depth of 0.
+		for _, distance := range []float64{1.5, 10, 50, 99} {
+
+			point := camera.WorldPosition().Add(vector.Vector{0.25, -0.25, -distance})
+			_, _, clipZ, _ := fastMatrixMultVecW(vpMatrix, point)
+
+			// This is the value Camera.render() writes to the depth texture.
+			depth := camera.encodeDepth(clipZ)
+
+			if result := depth*scale + offset; math.Abs(result-distance) > 1e-6 {
+				t.Errorf("perspective: %t; depth of a point %f units away converted to a distance of %f", perspective, distance, result)
+			}
+
+		}
+
+	}
+
+}
+
+func TestCameraScreenToWorld(t *testing.T) {
+
+	for _, perspective := range []bool{true, false} {
+
+		camera := NewCamera(320, 180)
+		if !perspective {
+			camera.SetOrthographic(20)
+		}
+		camera.Move(1, 2, 3)
+		camera.Rotate(0, 1, 0, 0.5)
+		camera.Rotate(1, 0, 0, -0.25)
+
+		forward := camera.WorldRotation().MultVec(vector.Vector{0, 0, -1})
+
+		for _, screen := range [][2]float64{{160, 90}, {0, 0}, {320, 180}, {40.5, 150.25}} {
+
+			for _, depth := range []float64{1, 10, 50} {
+
+				world := camera.ScreenToWorld(screen[0], screen[1], depth)
+
+				if d := world.Sub(camera.WorldPosition()).Dot(forward); math.Abs(d-depth) > 1e-6 {
+					t.Errorf("perspective: %t; ScreenToWorld(%v, %f) is %f units in front of the camera", perspective, screen, depth, d)
+				}
+
+				if result := camera.WorldToScreen(world); math.Abs(result[0]-screen[0]) > 1e-6 || math.Abs(result[1]-screen[1]) > 1e-6 {
+					t.Errorf("perspective: %t; WorldToScreen(ScreenToWorld(%v, %f)) returned %v", perspective, screen, depth, result[:2])
+				}
+
+			}
+
+		}
+
+		for _, world := range []vector.Vector{{0, 0, 0}, {1.5, 2.5, -4}, {-3, 1, -10}} {
+
+			depth := world.Sub(camera.WorldPosition()).Dot(forward)
+			screen := camera.WorldToScreen(world)
+
+			if result := camera.ScreenToWorld(screen[0], screen[1], depth); !vectorsEqual(result, world) {
+				t.Errorf("perspective: %t; ScreenToWorld(WorldToScreen(%v)) returned %v", perspective, world, result)
+			}
+
+		}
+
+	}
+
+}
+
+// encodeDepthColor encodes a depth value as the color written to the depth texture, as encodeDepth() in the depth shader does.
+func encodeDepthColor(depth float64) (uint8, uint8, uint8) {
+	r := math.Floor(depth * 255)
+	g := math.Floor(math.Mod(depth*255, 1) * 255)
+	b := math.Round(math.Mod(depth*255*255, 1) * 255)
+	return uint8(r), uint8(g), uint8(b)
+}
+
+func TestCameraDepthRoundTrip(t *testing.T) {
+
+	for _, perspective := range []bool{true, false} {
+
+		camera := NewCamera(320, 180)
+		if !perspective {
+			camera.SetOrthographic(20)
+		}
+		camera.Move(1, 2, 3)
+		camera.Rotate(0, 1, 0, 0.5)
+
+		forward := camera.WorldRotation().MultVec(vector.Vector{0, 0, -1})
+		scale, offset := camera.depthToDistance()
+
+		for _, world := range []vector.Vector{{0, 0, -4}, {1.5, 2.5, -10}, {-3, 1, -40}, {-20, 0, -60}} {
+
+			// Encode the point's depth as Camera.render() and the depth shader do, and then decode it as DepthAt() and
+			// ScreenToWorldDepth() do.
+			clip := camera.WorldToClip(world)
+			depth := decodeDepthColor(encodeDepthColor(camera.encodeDepth(clip[2])))
+			distance := depth*scale + offset
+
+			if expected := world.Sub(camera.WorldPosition()).Dot(forward); math.Abs(distance-expected) > 1e-3 {
+				t.Errorf("perspective: %t; point %v decoded to a distance of %f, expected %f", perspective, world, distance, expected)
+			}
+
+			screen := camera.WorldToScreen(world)
+
+			if result := camera.ScreenToWorld(screen[0], screen[1], distance); !vectorsEqual(result, world) {
+				t.Errorf("perspective: %t; point %v decoded to %v", perspective, world, result)
+			}
+
+		}
+
+	}
+
+}
+
+func TestCameraWorkersMatchSingleWorker(t *testing.T) {
+
+	library, err := LoadGLTFFile("./examples/animations/animations.gltf", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scene := library.Scenes[0]
+	scene.World.LightingOn = true
+
+	point := NewPointLight("Point", 1, 0.5, 0.25, 2)
+	point.Move(2, 3, 4)
+	sun := NewDirectionalLight("Sun", 0.5, 0.5, 1, 1)
+	sun.Rotate(1, 0, 0, -math.Pi/4)
+	scene.Root.AddChildren(point, sun)
+
+	// Pose the armature so the skinned Model's bones aren't in their rest positions.
+	player := NewAnimationPlayer(scene.Root.Get("Armature"))
+	player.Play(library.Animations["ArmatureAction"])
+	player.Update(0.3)
+	player.Update(0.3)
+
+	skinned := scene.Root.Get("Armature/SkinnedMesh").(*Model)
+	sphere := scene.Root.Get("Table/Sphere").(*Model)
+
+	lists := []*[]ebiten.Vertex{&colorVertexList, &depthVertexList, &normalVertexList, &viewDepthVertexList,
+		&vertexLightVertexList, &tangentVertexList, &bitangentVertexList, &uvVertexList}
+
+	// render renders the Model alone (so its vertices are flushed once) with the given number of Workers, returning copies of the vertex lists.
+	render := func(model *Model, workers int) [][]ebiten.Vertex {
+
+		for _, list := range lists {
+			for i := range *list {
+				(*list)[i] = ebiten.Vertex{}
+			}
+		}
+
+		camera := NewCamera(320, 180)
+		camera.Workers = workers
+		camera.Move(model.WorldPosition()[0], 2, 8)
+		scene.Root.AddChildren(camera)
+		defer scene.Root.RemoveChildren(camera)
+
+		camera.Clear()
+		camera.Render(scene, model)
+
+		results := make([][]ebiten.Vertex, len(lists))
+		for i, list := range lists {
+			results[i] = append([]ebiten.Vertex{}, (*list)[:model.Mesh.VertexCount]...)
+		}
+		return results
+
+	}
+
+	for _, model := range []*Model{skinned, sphere} {
+
+		if triangles := model.Mesh.VertexCount / 3; triangles < minWorkerTriangleCount*4 {
+			t.Fatalf("Model %s has %d triangles, which is too few to be split between 4 workers", model.Name(), triangles)
+		}
+
+		expected := render(model, 1)
+
+		if expected[0][0] == (ebiten.Vertex{}) {
+			t.Fatalf("Model %s wasn't rendered", model.Name())
+		}
+
+		for _, workers := range []int{2, 4} {
+
+			results := render(model, workers)
+
+			for l := range lists {
+				for i := range expected[l] {
+					if results[l][i] != expected[l][i] {
+						t.Errorf("vertex %d of vertex list %d for Model %s differs with %d workers: %+v, expected %+v",
+							i, l, model.Name(), workers, results[l][i], expected[l][i])
+						break
+					}
+				}
+			}
+
+		}
+
+	}
+
+}
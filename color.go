@@ -139,6 +139,30 @@ func (color *Color) ConvertTosRGB() {
 
 }
 
+// ConvertToLinear() converts the color's R, G, and B components from the sRGB color space to linear color space; this is the inverse
+// of ConvertTosRGB(), and is used to convert colors back to their values in GLTF when exporting.
+func (color *Color) ConvertToLinear() {
+
+	if color.R <= 0.04045 {
+		color.R /= 12.92
+	} else {
+		color.R = float32(math.Pow((float64(color.R)+0.055)/1.055, 2.4))
+	}
+
+	if color.G <= 0.04045 {
+		color.G /= 12.92
+	} else {
+		color.G = float32(math.Pow((float64(color.G)+0.055)/1.055, 2.4))
+	}
+
+	if color.B <= 0.04045 {
+		color.B /= 12.92
+	} else {
+		color.B = float32(math.Pow((float64(color.B)+0.055)/1.055, 2.4))
+	}
+
+}
+
 // NewColorFromHSV returns a new color, using hue, saturation, and value numbers, each ranging from 0 to 1. A hue of
 // 0 is red, while 1 is also red, but on the other end of the spectrum.
 // Cribbed from: https://github.com/lucasb-eyer/go-colorful/blob/master/colors.go
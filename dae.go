@@ -3,77 +3,307 @@ package tetra3d
 import (
 	"encoding/xml"
 	"fmt"
+	"log"
 	"math"
-	"os"
-	"sort"
+	"path/filepath"
 	"strconv"
 	"strings"
+
+	"github.com/kvartborg/vector"
 )
 
+type daeAccessor struct {
+	Stride int `xml:"stride,attr"`
+}
+
 type daeSource struct {
-	ID          string `xml:"id,attr"`
-	StringArray string `xml:"float_array"`
+	ID          string      `xml:"id,attr"`
+	Name        string      `xml:"name,attr"`
+	StringArray string      `xml:"float_array"`
+	NameArray   string      `xml:"Name_array"`
+	Accessor    daeAccessor `xml:"technique_common>accessor"`
 }
 
 func (source daeSource) Parse() []float64 {
-	split := strings.Split(strings.ReplaceAll(source.StringArray, "\n", " "), " ")
-	data := []float64{}
-	for _, v := range split {
-		f, _ := strconv.ParseFloat(v, 64)
-		data = append(data, f)
+	return parseDAEFloats(source.StringArray)
+}
+
+// Stride returns how many values each element of the source consists of (i.e. 3 for positions, 16 for matrices).
+func (source daeSource) Stride() int {
+	if source.Accessor.Stride > 0 {
+		return source.Accessor.Stride
 	}
-	return data
+	return 1
 }
 
-type daeTrianglesInput struct {
-	Source string `xml:"source,attr"`
-	Offset int    `xml:"offset,attr"`
+type daeInput struct {
+	Semantic string `xml:"semantic,attr"`
+	Source   string `xml:"source,attr"`
+	Offset   int    `xml:"offset,attr"`
+	Set      int    `xml:"set,attr"`
+}
+
+// daePrimitive is a <triangles>, <polylist>, or <polygons> element in a mesh, consisting of polygons rendered with a single material.
+type daePrimitive struct {
+	XMLName      xml.Name
+	MaterialName string     `xml:"material,attr"`
+	Inputs       []daeInput `xml:"input"`
+	VertexCounts string     `xml:"vcount"`
+	Indices      []string   `xml:"p"` // <polygons> elements have a <p> element for each polygon
+}
+
+type daeVertices struct {
+	ID     string     `xml:"id,attr"`
+	Inputs []daeInput `xml:"input"`
 }
 
-type daeTriangles struct {
-	String       string              `xml:"p"`
-	MaterialName string              `xml:"material,attr"`
-	Inputs       []daeTrianglesInput `xml:"input"`
+type daeMesh struct {
+	Sources    []daeSource    `xml:"source"`
+	Vertices   daeVertices    `xml:"vertices"`
+	Primitives []daePrimitive `xml:",any"`
 }
 
 type daeGeometry struct {
-	Name      string       `xml:"name,attr"`
-	URL       string       `xml:"id,attr"`
-	Sources   []daeSource  `xml:"mesh>source"`
-	Triangles daeTriangles `xml:"mesh>triangles"`
+	Name string  `xml:"name,attr"`
+	URL  string  `xml:"id,attr"`
+	Mesh daeMesh `xml:"mesh"`
 }
 
-type daeLibraryGeometries struct {
-	Geometries []daeGeometry `xml:"library_geometries>geometry"`
+type daeInstance struct {
+	URL string `xml:"url,attr"`
 }
 
 type daeLibraryMaterial struct {
-	ID   string `xml:"id,attr"`
-	Name string `xml:"name,attr"`
+	ID     string      `xml:"id,attr"`
+	Name   string      `xml:"name,attr"`
+	Effect daeInstance `xml:"instance_effect"`
+}
+
+type daeImage struct {
+	ID       string `xml:"id,attr"`
+	InitFrom struct {
+		Path string `xml:",chardata"` // COLLADA 1.4
+		Ref  string `xml:"ref"`       // COLLADA 1.5
+	} `xml:"init_from"`
+}
+
+type daeEffectParam struct {
+	SID           string      `xml:"sid,attr"`
+	SurfaceImage  string      `xml:"surface>init_from"`
+	SamplerSource string      `xml:"sampler2D>source"`
+	SamplerImage  daeInstance `xml:"sampler2D>instance_image"`
+}
+
+type daeColorOrTexture struct {
+	Color   string `xml:"color"`
+	Texture struct {
+		Texture string `xml:"texture,attr"`
+	} `xml:"texture"`
+}
+
+// daeShader is a <constant>, <lambert>, <phong>, or <blinn> element in an effect.
+type daeShader struct {
+	XMLName  xml.Name
+	Emission daeColorOrTexture `xml:"emission"`
+	Diffuse  daeColorOrTexture `xml:"diffuse"`
+}
+
+type daeEffect struct {
+	ID        string           `xml:"id,attr"`
+	Params    []daeEffectParam `xml:"profile_COMMON>newparam"`
+	Technique struct {
+		Shaders []daeShader `xml:",any"`
+	} `xml:"profile_COMMON>technique"`
+}
+
+type daeCamera struct {
+	ID          string `xml:"id,attr"`
+	Perspective *struct {
+		XFov        float64 `xml:"xfov"`
+		YFov        float64 `xml:"yfov"`
+		AspectRatio float64 `xml:"aspect_ratio"`
+		ZNear       float64 `xml:"znear"`
+		ZFar        float64 `xml:"zfar"`
+	} `xml:"optics>technique_common>perspective"`
+	Orthographic *struct {
+		XMag  float64 `xml:"xmag"`
+		YMag  float64 `xml:"ymag"`
+		ZNear float64 `xml:"znear"`
+		ZFar  float64 `xml:"zfar"`
+	} `xml:"optics>technique_common>orthographic"`
 }
 
-type daeLibraryMaterials struct {
-	MaterialNames []daeLibraryMaterial `xml:"library_materials>material"`
+type daeLightType struct {
+	Color        string  `xml:"color"`
+	FalloffAngle float64 `xml:"falloff_angle"`
+}
+
+type daeLight struct {
+	ID          string        `xml:"id,attr"`
+	Ambient     *daeLightType `xml:"technique_common>ambient"`
+	Directional *daeLightType `xml:"technique_common>directional"`
+	Point       *daeLightType `xml:"technique_common>point"`
+	Spot        *daeLightType `xml:"technique_common>spot"`
+	Extras      []struct {
+		Profile   string  `xml:"profile,attr"`
+		Energy    float64 `xml:"energy"`
+		SpotBlend float64 `xml:"spot_blend"`
+	} `xml:"extra>technique"`
+}
+
+type daeSkin struct {
+	Source          string      `xml:"source,attr"`
+	BindShapeMatrix string      `xml:"bind_shape_matrix"`
+	Sources         []daeSource `xml:"source"`
+	Joints          []daeInput  `xml:"joints>input"`
+	WeightInputs    []daeInput  `xml:"vertex_weights>input"`
+	VertexCounts    string      `xml:"vertex_weights>vcount"`
+	Indices         string      `xml:"vertex_weights>v"`
+}
+
+type daeController struct {
+	ID   string   `xml:"id,attr"`
+	Skin *daeSkin `xml:"skin"`
+}
+
+type daeSampler struct {
+	ID     string     `xml:"id,attr"`
+	Inputs []daeInput `xml:"input"`
+}
+
+type daeChannel struct {
+	Source string `xml:"source,attr"`
+	Target string `xml:"target,attr"`
+}
+
+type daeAnimation struct {
+	ID       string         `xml:"id,attr"`
+	Name     string         `xml:"name,attr"`
+	Sources  []daeSource    `xml:"source"`
+	Samplers []daeSampler   `xml:"sampler"`
+	Channels []daeChannel   `xml:"channel"`
+	Children []daeAnimation `xml:"animation"`
+}
+
+type daeAnimationClip struct {
+	ID         string        `xml:"id,attr"`
+	Name       string        `xml:"name,attr"`
+	Start      float64       `xml:"start,attr"`
+	End        float64       `xml:"end,attr"`
+	Animations []daeInstance `xml:"instance_animation"`
+}
+
+type daeInstanceMaterial struct {
+	Symbol string `xml:"symbol,attr"`
+	Target string `xml:"target,attr"`
 }
 
 type daeInstanceGeometry struct {
-	URL string `xml:"url,attr"`
+	URL       string                `xml:"url,attr"`
+	Skeletons []string              `xml:"skeleton"`
+	Materials []daeInstanceMaterial `xml:"bind_material>technique_common>instance_material"`
+}
+
+// daeTransformElement is a <matrix>, <translate>, <rotate>, or <scale> element in a node.
+type daeTransformElement struct {
+	XMLName xml.Name
+	Value   string `xml:",chardata"`
 }
 
 type daeNode struct {
-	Name      string              `xml:"name,attr"`
-	Mesh      daeInstanceGeometry `xml:"instance_geometry"`
-	Transform string              `xml:"matrix"`
-	Children  []daeNode           `xml:"node"`
+	ID         string                `xml:"id,attr"`
+	Name       string                `xml:"name,attr"`
+	SID        string                `xml:"sid,attr"`
+	Type       string                `xml:"type,attr"`
+	Mesh       daeInstanceGeometry   `xml:"instance_geometry"`
+	Controller daeInstanceGeometry   `xml:"instance_controller"`
+	Camera     daeInstance           `xml:"instance_camera"`
+	Light      daeInstance           `xml:"instance_light"`
+	Children   []daeNode             `xml:"node"`
+	Transforms []daeTransformElement `xml:",any"`
 }
 
+// ParseTransform returns the node's transform (in the DAE file's coordinate space), combining its <matrix>, <translate>, <rotate>,
+// and <scale> elements.
 func (daeNode daeNode) ParseTransform() Matrix4 {
-	split := strings.Split(strings.ReplaceAll(daeNode.Transform, "\n", " "), " ")
-	data := []float64{}
-	for _, v := range split {
+
+	transform := NewMatrix4()
+
+	// Transformation elements are applied from last to first
+	for i := len(daeNode.Transforms) - 1; i >= 0; i-- {
+
+		element := daeNode.Transforms[i]
+		data := parseDAEFloats(element.Value)
+
+		switch element.XMLName.Local {
+		case "matrix":
+			if len(data) >= 16 {
+				transform = transform.Mult(parseDAEMatrix(data))
+			}
+		case "translate":
+			if len(data) >= 3 {
+				transform = transform.Mult(NewMatrix4Translate(data[0], data[1], data[2]))
+			}
+		case "rotate":
+			if len(data) >= 4 {
+				transform = transform.Mult(NewMatrix4Rotate(data[0], data[1], data[2], data[3]*math.Pi/180))
+			}
+		case "scale":
+			if len(data) >= 3 {
+				transform = transform.Mult(NewMatrix4Scale(data[0], data[1], data[2]))
+			}
+		}
+
+	}
+
+	return transform
+}
+
+type daeVisualScene struct {
+	ID    string    `xml:"id,attr"`
+	Name  string    `xml:"name,attr"`
+	Nodes []daeNode `xml:"node"`
+}
+
+type daeDocument struct {
+	Images         []daeImage           `xml:"library_images>image"`
+	Effects        []daeEffect          `xml:"library_effects>effect"`
+	Materials      []daeLibraryMaterial `xml:"library_materials>material"`
+	Geometries     []daeGeometry        `xml:"library_geometries>geometry"`
+	Controllers    []daeController      `xml:"library_controllers>controller"`
+	Cameras        []daeCamera          `xml:"library_cameras>camera"`
+	Lights         []daeLight           `xml:"library_lights>light"`
+	Animations     []daeAnimation       `xml:"library_animations>animation"`
+	AnimationClips []daeAnimationClip   `xml:"library_animation_clips>animation_clip"`
+	VisualScenes   []daeVisualScene     `xml:"library_visual_scenes>visual_scene"`
+	Scene          daeInstance          `xml:"scene>instance_visual_scene"`
+}
+
+func parseDAEFloats(text string) []float64 {
+	fields := strings.Fields(text)
+	data := make([]float64, 0, len(fields))
+	for _, v := range fields {
 		f, _ := strconv.ParseFloat(v, 64)
 		data = append(data, f)
 	}
+	return data
+}
+
+func parseDAEInts(text string) ([]int, error) {
+	fields := strings.Fields(text)
+	data := make([]int, 0, len(fields))
+	for _, v := range fields {
+		i, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, i)
+	}
+	return data, nil
+}
+
+// parseDAEMatrix parses a matrix from the 16 values given (which are in row-major order, for transforming column vectors).
+func parseDAEMatrix(data []float64) Matrix4 {
 
 	// Matrices are column-major in Blender, so we need to adjust for that
 
@@ -86,337 +316,1086 @@ func (daeNode daeNode) ParseTransform() Matrix4 {
 	}
 
 	return mat
-}
 
-type daeVisualScene struct {
-	Name  string    `xml:"name,attr"`
-	Nodes []daeNode `xml:"node"`
 }
 
-type daeVisualLibraryScene struct {
-	LibraryScene daeVisualScene `xml:"library_visual_scenes>visual_scene"`
-}
+// daeToYUp converts a transform matrix from Blender's Z-up space to Tetra3D's Y-up space.
+func daeToYUp(mat Matrix4) Matrix4 {
 
-func parseDAESourceName(sourceName string) string {
+	// Tetra's +Y is Blender's +Z
+	by := mat.Column(1)
+	bz := mat.Column(2)
 
-	idPieces := strings.Split(sourceName, "-")
+	mat.SetColumn(1, bz)
+	mat.SetColumn(2, by.Invert())
 
-	for endingIndex := len(idPieces) - 1; endingIndex >= 0; endingIndex-- {
+	by = mat.Row(1)
+	bz = mat.Row(2)
 
-		ending := strings.ToLower(idPieces[endingIndex])
+	mat.SetRow(1, bz)
+	mat.SetRow(2, by.Invert())
 
-		if strings.Contains(ending, "color") {
-			return "color"
-		} else if strings.Contains(ending, "position") || strings.Contains(ending, "vert") {
-			return "vertex"
-		} else if strings.Contains(ending, "normal") {
-			return "normal"
-		} else if strings.Contains(ending, "map") {
-			return "uv"
-		}
+	return mat
 
-	}
+}
 
-	return ""
+// daeID returns the ID referenced by the given URL (i.e. "#Cube-mesh" -> "Cube-mesh").
+func daeID(url string) string {
+	return strings.TrimPrefix(url, "#")
+}
 
+func daeColor(text string) *Color {
+	values := parseDAEFloats(text)
+	color := NewColor(1, 1, 1, 1)
+	for i := 0; i < len(values) && i < 4; i++ {
+		switch i {
+		case 0:
+			color.R = float32(values[i])
+		case 1:
+			color.G = float32(values[i])
+		case 2:
+			color.B = float32(values[i])
+		case 3:
+			color.A = float32(values[i])
+		}
+	}
+	return color
 }
 
 // DaeLoadOptions represents options one can use to tweak how .dae files are loaded into Tetra3D.
 type DaeLoadOptions struct {
-	CorrectYUp bool // Whether to correct Z being up for Blender importing.
+	CorrectYUp                bool // Whether to correct Z being up for Blender importing.
+	CameraWidth, CameraHeight int  // Width and height of loaded Cameras. Defaults to 1920x1080.
+	CameraDepth               bool // If cameras should render depth or not
+	// KeepTextureSources indicates whether the images that textures are created from by LoadDAEFileFS() should be registered as the
+	// textures' sources (see RegisterTextureSource()). Defaults to false.
+	KeepTextureSources bool
 }
 
 // DefaultDaeLoadOptions returns a default instance of DaeLoadOptions.
 func DefaultDaeLoadOptions() *DaeLoadOptions {
 	return &DaeLoadOptions{
-		CorrectYUp: true,
+		CorrectYUp:   true,
+		CameraWidth:  1920,
+		CameraHeight: 1080,
+		CameraDepth:  true,
 	}
 }
 
-// LoadDAEFile takes a filepath to a .dae model file, and returns a *Library populated with the .dae file's objects and meshes.
-// Animations will not be loaded from DAE files, as DAE exports through Blender only support one animation per object (so it's generally
-// advised to use the GLTF or GLB format instead). Cameras exported in the DAE file will be turned into simple Nodes in Tetra3D, as
-// there's not enough information to instantiate a tetra3d.Camera. If the call couldn't complete for any reason, like due to a malformed DAE file,
-// it will return an error.
+// LoadDAEFile takes a filepath to a .dae model file, and returns a *Library populated with the .dae file's objects, meshes, materials,
+// and animations (see LoadDAEData()). Textures referenced by the file's materials are loaded relative to the .dae file
+// (including from other directories, like "../textures/wall.png").
+// If the call couldn't complete for any reason, like due to a malformed DAE file, it will return an error.
 func LoadDAEFile(path string, options *DaeLoadOptions) (*Library, error) {
-
-	if fileData, err := os.ReadFile(path); err != nil {
-		return nil, err
-	} else {
-		return LoadDAEData(fileData, options)
-	}
-
+	return LoadDAEFileFS(osDirFS(filepath.Dir(path)), filepath.Base(path), options)
 }
 
 // LoadDAEData takes a []byte consisting of the contents of a DAE file, and returns a *Library populated with the .dae file's objects and meshes.
-// Animations will not be loaded from DAE files, as DAE exports through Blender only support one animation per object (so it's generally
-// advised to use the GLTF or GLB format instead). Cameras exported in the DAE file will be turned into simple Nodes in Tetra3D, as
-// there's not enough information to instantiate a tetra3d.Camera. If the call couldn't complete for any reason, like due to a malformed DAE file,
-// it will return an error.
+// Materials are loaded with their diffuse and emission colors and textures (though as the textures aren't packed into DAE files, only the
+// Materials' TexturePath and EmissiveTexturePath are set; use LoadDAEFile() or LoadDAEFileFS() to load the textures as well). Cameras, lights,
+// vertex colors, and armature-skinned meshes are supported. Animations are loaded from the file's animation clips if it has any; otherwise,
+// each animation in the file (i.e. each animated object or armature exported from Blender) is loaded as a separate Animation. Note that
+// only animations of full transforms (the default when exporting from Blender) are supported. If the call couldn't complete for any reason,
+// like due to a malformed DAE file, it will return an error.
 func LoadDAEData(data []byte, options *DaeLoadOptions) (*Library, error) {
 
 	if options == nil {
 		options = DefaultDaeLoadOptions()
 	}
 
-	daeGeo := &daeLibraryGeometries{}
-	daeScene := &daeVisualLibraryScene{}
-	daeMaterials := &daeLibraryMaterials{}
-
-	err := xml.Unmarshal(data, daeGeo)
+	doc := &daeDocument{}
 
-	if err != nil {
+	if err := xml.Unmarshal(data, doc); err != nil {
 		return nil, err
 	}
 
-	err = xml.Unmarshal(data, &daeScene)
+	scenes := NewLibrary()
 
-	if err != nil {
-		return nil, err
-	}
+	// Materials
 
-	err = xml.Unmarshal(data, &daeMaterials)
+	daeImagePaths := map[string]string{}
 
-	if err != nil {
-		return nil, err
+	for _, image := range doc.Images {
+		imagePath := strings.TrimSpace(image.InitFrom.Path)
+		if ref := strings.TrimSpace(image.InitFrom.Ref); ref != "" {
+			imagePath = ref
+		}
+		daeImagePaths[image.ID] = strings.TrimPrefix(imagePath, "file://")
 	}
 
-	scenes := NewLibrary()
-	scene := scenes.AddScene(daeScene.LibraryScene.Name)
-	scene.library = scenes
-	scenes.ExportedScene = scene
+	daeEffects := map[string]daeEffect{}
+	for _, effect := range doc.Effects {
+		daeEffects[effect.ID] = effect
+	}
 
-	daeURLsToMeshes := map[string]*Mesh{}
 	daeURLsToMaterials := map[string]*Material{}
 
-	for _, mat := range daeMaterials.MaterialNames {
-		newMat := NewMaterial(mat.Name)
+	for _, mat := range doc.Materials {
+
+		name := mat.Name
+		if name == "" {
+			name = mat.ID
+		}
+
+		newMat := NewMaterial(name)
 		newMat.library = scenes
 		daeURLsToMaterials[mat.ID] = newMat
-	}
+		scenes.Materials[name] = newMat
 
-	for _, geo := range daeGeo.Geometries {
+		effect, exists := daeEffects[daeID(mat.Effect.URL)]
+		if !exists {
+			continue
+		}
 
-		sourceData := map[string][]float64{}
+		params := map[string]daeEffectParam{}
+		for _, param := range effect.Params {
+			params[param.SID] = param
+		}
 
-		for _, source := range geo.Sources {
+		// Textures reference a sampler, which references a surface, which references an image
+		texturePath := func(texture string) string {
+			if param, exists := params[texture]; exists {
+				if param.SamplerImage.URL != "" {
+					return daeImagePaths[daeID(param.SamplerImage.URL)]
+				}
+				if surface, exists := params[param.SamplerSource]; exists {
+					return daeImagePaths[surface.SurfaceImage]
+				}
+			}
+			return daeImagePaths[texture]
+		}
 
-			if parsedName := parseDAESourceName(source.ID); parsedName != "" {
-				sourceData[parsedName] = source.Parse()
-			} else {
-				fmt.Println("Unknown geometry data, ", source.ID)
+		for _, shader := range effect.Technique.Shaders {
+
+			switch shader.XMLName.Local {
+			case "constant", "lambert", "phong", "blinn":
+			default:
+				continue
 			}
 
+			newMat.Shadeless = shader.XMLName.Local == "constant"
+
+			if shader.Diffuse.Texture.Texture != "" {
+				newMat.TexturePath = texturePath(shader.Diffuse.Texture.Texture)
+			} else if shader.Diffuse.Color != "" {
+				// Colors are exported from Blender as linear, but display as sRGB, so we'll convert them here
+				newMat.Color = daeColor(shader.Diffuse.Color)
+				newMat.Color.ConvertTosRGB()
+			}
+
+			if shader.Emission.Texture.Texture != "" {
+				newMat.EmissiveTexturePath = texturePath(shader.Emission.Texture.Texture)
+				newMat.EmissiveColor.Set(1, 1, 1, 1)
+			} else if shader.Emission.Color != "" {
+				emission := daeColor(shader.Emission.Color)
+				newMat.EmissiveColor.Set(emission.R, emission.G, emission.B, 1)
+				newMat.EmissiveColor.ConvertTosRGB()
+			}
+
+			break
+
+		}
+
+	}
+
+	// Materials are bound to the material symbols used in geometry through the nodes that instantiate the geometry
+	daeMaterialBindings := map[string]string{}
+
+	var findBindings func(nodes []daeNode)
+	findBindings = func(nodes []daeNode) {
+		for _, node := range nodes {
+			for _, binding := range append(append([]daeInstanceMaterial{}, node.Mesh.Materials...), node.Controller.Materials...) {
+				if _, exists := daeMaterialBindings[binding.Symbol]; !exists {
+					daeMaterialBindings[binding.Symbol] = daeID(binding.Target)
+				}
+			}
+			findBindings(node.Children)
 		}
+	}
 
-		// triangleOrder := append([]daeTrianglesInput{}, geo.TriangleInputs...)
-		// sort.Slice(triangleOrder, func(i, j int) bool { return triangleOrder[i].Offset < triangleOrder[j].Offset })
-		triangleOrder := []string{}
-		for _, t := range geo.Triangles.Inputs {
-			triangleOrder = append(triangleOrder, parseDAESourceName(t.Source))
+	for _, visualScene := range doc.VisualScenes {
+		findBindings(visualScene.Nodes)
+	}
+
+	materialForSymbol := func(symbol string) *Material {
+		if id, exists := daeMaterialBindings[symbol]; exists {
+			if mat, exists := daeURLsToMaterials[id]; exists {
+				return mat
+			}
+		}
+		if mat, exists := daeURLsToMaterials[symbol]; exists {
+			return mat
 		}
-		sort.Slice(triangleOrder, func(i, j int) bool { return geo.Triangles.Inputs[i].Offset < geo.Triangles.Inputs[j].Offset })
+		return scenes.Materials[symbol]
+	}
 
-		triangleIndices := []int64{}
+	// Skins; each geometry is skinned by the first controller that references it
 
-		for _, t := range strings.Split(strings.TrimSpace(geo.Triangles.String), " ") {
+	daeControllers := map[string]daeController{}
+	daeGeometrySkins := map[string]string{}
 
-			var ti int64
-			ti, err = strconv.ParseInt(t, 0, 32)
-			if err != nil {
-				return nil, err
+	for _, controller := range doc.Controllers {
+		daeControllers[controller.ID] = controller
+		if controller.Skin != nil {
+			if _, exists := daeGeometrySkins[daeID(controller.Skin.Source)]; !exists {
+				daeGeometrySkins[daeID(controller.Skin.Source)] = controller.ID
 			}
+		}
+	}
+
+	// Meshes
+
+	daeURLsToMeshes := map[string]*Mesh{}
 
-			triangleIndices = append(triangleIndices, ti)
+	for _, geo := range doc.Geometries {
 
+		var skin *daeSkin
+		if controllerID, exists := daeGeometrySkins[geo.URL]; exists {
+			skin = daeControllers[controllerID].Skin
 		}
 
-		verts := []VertexInfo{}
-		// normals := map[*Vertex]vector.Vector{}
+		mesh, err := parseDAEMesh(geo, skin, materialForSymbol, options)
+		if err != nil {
+			return nil, err
+		}
+
+		mesh.library = scenes
+		scenes.Meshes[geo.Name] = mesh
+		daeURLsToMeshes[geo.URL] = mesh
 
-		x, y, z := 0.0, 0.0, 0.0
-		u, v := 0.0, 0.0
-		r, g, b, a := float32(1.0), float32(1.0), float32(1.0), float32(1.0)
-		hasColor := false
-		nx, ny, nz := 0.0, 0.0, 0.0
+	}
 
-		for i := 0; i < len(triangleIndices); i++ {
+	daeCameras := map[string]daeCamera{}
+	for _, camera := range doc.Cameras {
+		daeCameras[camera.ID] = camera
+	}
 
-			triIndex := triangleIndices[i]
+	daeLights := map[string]daeLight{}
+	for _, light := range doc.Lights {
+		daeLights[light.ID] = light
+	}
 
-			tv := int(triIndex)
+	// Nodes
 
-			switch triangleOrder[i%len(triangleOrder)] {
+	// Cameras and lights face -Z in Blender, which becomes -Y when converted to be Y-up, so they need to be rotated to face -Z again
+	// (and their children rotated back).
+	facingCorrection := NewMatrix4Rotate(1, 0, 0, -math.Pi/2)
+	facingCorrectionInverse := facingCorrection.Transposed()
 
-			case "vertex":
-				x = sourceData["vertex"][tv*3]
-				y = sourceData["vertex"][(tv*3)+1]
-				z = sourceData["vertex"][(tv*3)+2]
+	// daeNodeTransform is what's needed to convert a node's transform (or animated transform) from the DAE file to Tetra3D.
+	type daeNodeTransform struct {
+		pre, post Matrix4
+	}
 
-			case "uv":
-				u = sourceData["uv"][tv*2]
-				v = sourceData["uv"][(tv*2)+1]
+	daeNodeTransforms := map[string]daeNodeTransform{}
+	daeNodesByID := map[string]INode{}
+	daeNodesBySID := map[string]INode{}
+	daeNodeSIDs := map[INode]string{}
+
+	convertTransform := func(mat Matrix4, transform daeNodeTransform) Matrix4 {
+		if options.CorrectYUp {
+			mat = daeToYUp(mat)
+		}
+		return transform.pre.Mult(mat).Mult(transform.post)
+	}
+
+	type daeSkinnedModel struct {
+		model      *Model
+		controller daeController
+		skeletons  []string
+	}
+
+	skinnedModels := []daeSkinnedModel{}
+
+	var parseDAENode func(node daeNode, parentCorrection Matrix4) INode
+
+	parseDAENode = func(node daeNode, parentCorrection Matrix4) INode {
+
+		var model INode
+
+		name := node.Name
+		if name == "" {
+			name = node.ID
+		}
 
-			case "color":
-				r = float32(sourceData["color"][tv*4])
-				g = float32(sourceData["color"][(tv*4)+1])
-				b = float32(sourceData["color"][(tv*4)+2])
-				a = float32(sourceData["color"][(tv*4)+3])
-				hasColor = true
+		transform := daeNodeTransform{pre: NewMatrix4(), post: parentCorrection}
+		correction := NewMatrix4()
 
-			case "normal":
+		if node.Controller.URL != "" {
 
-				nx = sourceData["normal"][tv*3]
-				ny = sourceData["normal"][(tv*3)+1]
-				nz = sourceData["normal"][(tv*3)+2]
+			controller := daeControllers[daeID(node.Controller.URL)]
+
+			if controller.Skin != nil {
+
+				if mesh := daeURLsToMeshes[daeID(controller.Skin.Source)]; mesh != nil {
+
+					newModel := NewModel(mesh, name)
+
+					if daeGeometrySkins[daeID(controller.Skin.Source)] == controller.ID {
+						skinnedModels = append(skinnedModels, daeSkinnedModel{model: newModel, controller: controller, skeletons: node.Controller.Skeletons})
+					} else {
+						log.Println("Warning: skin controller " + controller.ID + " skins geometry that's already skinned by another controller; " + name + " will be unskinned")
+					}
+
+					model = newModel
+
+				}
 
 			}
 
-			if i%len(triangleOrder) == len(triangleOrder)-1 {
+		} else if node.Mesh.URL != "" {
 
-				vert := NewVertex(x, y, z, u, v)
+			if mesh := daeURLsToMeshes[daeID(node.Mesh.URL)]; mesh != nil {
+				model = NewModel(mesh, name)
+			}
 
-				if hasColor {
-					col := NewColor(r, g, b, a)
-					vert.Colors = append(vert.Colors, col)
-					vert.ActiveColorChannel = 0
+		} else if camera, exists := daeCameras[daeID(node.Camera.URL)]; exists {
+
+			newCam := NewCamera(options.CameraWidth, options.CameraHeight)
+			newCam.name = name
+			newCam.RenderDepth = options.CameraDepth
+
+			if camera.Perspective != nil {
+				newCam.Near = camera.Perspective.ZNear
+				newCam.Far = camera.Perspective.ZFar
+				newCam.Perspective = true
+				if camera.Perspective.YFov > 0 {
+					newCam.FieldOfView = camera.Perspective.YFov
+				} else if camera.Perspective.XFov > 0 {
+					aspectRatio := camera.Perspective.AspectRatio
+					if aspectRatio <= 0 {
+						aspectRatio = float64(options.CameraWidth) / float64(options.CameraHeight)
+					}
+					xfov := camera.Perspective.XFov * math.Pi / 180
+					newCam.FieldOfView = 2 * math.Atan(math.Tan(xfov/2)/aspectRatio) * 180 / math.Pi
 				}
+			} else if camera.Orthographic != nil {
+				newCam.Near = camera.Orthographic.ZNear
+				newCam.Far = camera.Orthographic.ZFar
+				newCam.OrthoScale = camera.Orthographic.XMag
+				newCam.Perspective = false
+			}
 
-				vert.NormalX = nx
-				vert.NormalY = ny
-				vert.NormalZ = nz
+			model = newCam
 
-				verts = append(verts, vert)
+			if options.CorrectYUp {
+				transform.pre = facingCorrection
+				correction = facingCorrectionInverse
+			}
 
-				// normals[vert] = vector.Vector{nx, ny, nz}
+		} else if light, exists := daeLights[daeID(node.Light.URL)]; exists {
 
+			model = parseDAELight(light, name)
+
+			if options.CorrectYUp {
+				transform.pre = facingCorrection
+				correction = facingCorrectionInverse
 			}
 
 		}
 
-		mesh := NewMesh(geo.Name)
-		mat := daeURLsToMaterials[geo.Triangles.MaterialName]
-		mesh.AddMeshPart(mat).AddTriangles(verts...)
-		mesh.library = scenes
+		if model == nil {
+			newNode := NewNode(name)
+			newNode.isBone = node.Type == "JOINT"
+			model = newNode
+		}
+
+		model.setLibrary(scenes)
+
+		if node.ID != "" {
+			daeNodesByID[node.ID] = model
+			daeNodeTransforms[node.ID] = transform
+		}
+
+		if node.SID != "" {
+			daeNodeSIDs[model] = node.SID
+			if _, exists := daeNodesBySID[node.SID]; !exists {
+				daeNodesBySID[node.SID] = model
+			}
+		}
+
+		mat := convertTransform(node.ParseTransform(), transform)
+
+		// We parse and parent children before setting position, scale, and rotation because by doing it in this order,
+		// we get the child being in its final transform as a result of the parent, rather than parenting leaving the child
+		// at its original position. In other words, in the 3D modeler, the transform of children is the result of transforms
+		// having been set AFTER parenting.
+		for _, child := range node.Children {
+			model.AddChildren(parseDAENode(child, correction))
+		}
+
+		p, s, r := mat.Decompose()
 
-		// if len(normals) > 0 {
+		model.SetLocalPositionVec(p)
+		model.SetLocalScaleVec(s)
+		model.SetLocalRotation(r)
 
-		// 	for _, part := range mesh.MeshParts {
+		return model
 
-		// 		for _, tri := range part.Triangles {
+	}
 
-		// 			normal := vector.Vector{0, 0, 0}
-		// 			for _, vert := range tri.Vertices {
-		// 				normal = normal.Add(normals[vert])
-		// 			}
-		// 			normal = normal.Scale(1.0 / 3.0).Unit()
+	for _, visualScene := range doc.VisualScenes {
 
-		// 			normal = toYUp.MultVec(normal)
-		// 			normal[2] *= -1
-		// 			normal[1] *= -1
-		// 			tri.Normal = normal
+		name := visualScene.Name
+		if name == "" {
+			name = visualScene.ID
+		}
 
-		// 		}
+		scene := scenes.AddScene(name)
+		scene.library = scenes
 
-		// 		if options.CorrectYUp {
-		// 			part.ApplyMatrix(NewMatrix4Rotate(1, 0, 0, -math.Pi/2))
-		// 			mesh.UpdateBounds()
-		// 		}
+		if scenes.ExportedScene == nil || visualScene.ID == daeID(doc.Scene.URL) {
+			scenes.ExportedScene = scene
+		}
 
-		// 	}
+		for _, node := range visualScene.Nodes {
+			scene.Root.AddChildren(parseDAENode(node, NewMatrix4()))
+		}
 
-		// }
+	}
 
-		if options.CorrectYUp {
-			// for _, part := range mesh.MeshParts {
-			// 	part.ApplyMatrix(NewMatrix4Rotate(1, 0, 0, -math.Pi/2))
-			// 	mesh.UpdateBounds()
-			// }
+	// Skinning
 
-			rotate := NewMatrix4Rotate(1, 0, 0, -math.Pi/2)
-			for i := range mesh.VertexPositions {
-				x, y, z := fastMatrixMultVec(rotate, mesh.VertexPositions[i])
-				mesh.VertexPositions[i][0] = x
-				mesh.VertexPositions[i][1] = y
-				mesh.VertexPositions[i][2] = z
+	for _, skinned := range skinnedModels {
+
+		skin := skinned.controller.Skin
+		model := skinned.model
+
+		skinSources := map[string]daeSource{}
+		for _, source := range skin.Sources {
+			skinSources[source.ID] = source
+		}
+
+		var jointNames []string
+		var inverseBindMatrices []float64
+
+		for _, input := range skin.Joints {
+			switch input.Semantic {
+			case "JOINT":
+				jointNames = strings.Fields(skinSources[daeID(input.Source)].NameArray)
+			case "INV_BIND_MATRIX":
+				inverseBindMatrices = skinSources[daeID(input.Source)].Parse()
 			}
 		}
 
-		scenes.Meshes[geo.Name] = mesh
-		daeURLsToMeshes[geo.URL] = mesh
+		if len(inverseBindMatrices) < len(jointNames)*16 {
+			return nil, fmt.Errorf("error parsing DAE data: skin controller %s has too few inverse bind matrices", skinned.controller.ID)
+		}
+
+		// Joints are referenced by their SIDs, searched for under the skeleton root nodes first
+		findJoint := func(jointName string) INode {
+			for _, skeleton := range skinned.skeletons {
+				if root, exists := daeNodesByID[daeID(skeleton)]; exists {
+					for _, n := range append(NodeFilter{root}, root.ChildrenRecursive()...) {
+						if daeNodeSIDs[n] == jointName {
+							return n
+						}
+					}
+				}
+			}
+			if n, exists := daeNodesBySID[jointName]; exists {
+				return n
+			}
+			return daeNodesByID[jointName]
+		}
 
+		joints := []*Node{}
+
+		for jointIndex, jointName := range jointNames {
+
+			joint, isNode := findJoint(jointName).(*Node)
+			if !isNode {
+				return nil, fmt.Errorf("error parsing DAE data: skin controller %s refers to joint %s, which can't be found", skinned.controller.ID, jointName)
+			}
+
+			inverseBindMatrix := parseDAEMatrix(inverseBindMatrices[jointIndex*16 : (jointIndex+1)*16])
+			if options.CorrectYUp {
+				inverseBindMatrix = daeToYUp(inverseBindMatrix)
+			}
+
+			joint.inverseBindMatrix = inverseBindMatrix
+			joint.isBone = true
+			joints = append(joints, joint)
+
+			// This is incorrect, but it gives us a link to any bone in the armature to establish the true root below
+			model.SkinRoot = joint
+
+		}
+
+		if len(joints) == 0 {
+			continue
+		}
+
+		model.Skinned = true
+
+		for vertIndex, boneIndices := range model.Mesh.VertexBones {
+			model.bones = append(model.bones, []*Node{})
+			for _, boneID := range boneIndices {
+				if int(boneID) >= len(joints) {
+					return nil, fmt.Errorf("error parsing DAE data: skin controller %s weights vertices to joint %d, but only has %d joints", skinned.controller.ID, boneID, len(joints))
+				}
+				model.bones[vertIndex] = append(model.bones[vertIndex], joints[boneID])
+			}
+		}
+
+		// SkinRoot should be the root node of a hierarchy of bone Nodes.
+		parent := model.SkinRoot
+		for parent.IsBone() && parent.Parent() != nil {
+			parent = parent.Parent()
+		}
+		model.SkinRoot = parent
+
+	}
+
+	// Animations
+
+	daeAnimationSources := map[string]daeSource{}
+	daeSamplers := map[string]daeSampler{}
+	daeAnimationsByID := map[string]daeAnimation{}
+
+	var indexAnimation func(anim daeAnimation)
+	indexAnimation = func(anim daeAnimation) {
+		daeAnimationsByID[anim.ID] = anim
+		for _, source := range anim.Sources {
+			daeAnimationSources[source.ID] = source
+		}
+		for _, sampler := range anim.Samplers {
+			daeSamplers[sampler.ID] = sampler
+		}
+		for _, child := range anim.Children {
+			indexAnimation(child)
+		}
+	}
+
+	for _, anim := range doc.Animations {
+		indexAnimation(anim)
 	}
 
-	var parseDAENode func(node daeNode) INode
+	unsupportedTargets := map[string]bool{}
 
-	parseDAENode = func(node daeNode) INode {
+	var addAnimationChannels func(anim *Animation, daeAnim daeAnimation, start, end float64)
 
-		var mesh *Mesh
+	addAnimationChannels = func(anim *Animation, daeAnim daeAnimation, start, end float64) {
 
-		if node.Mesh.URL != "" {
+		for _, channel := range daeAnim.Channels {
 
-			meshURL := strings.Split(node.Mesh.URL, "#")[1]
-			for url, m := range daeURLsToMeshes {
-				if url == meshURL {
-					mesh = m
+			targetNodeID := strings.Split(channel.Target, "/")[0]
+
+			node, exists := daeNodesByID[targetNodeID]
+			if !exists {
+				continue
+			}
+
+			var times, values []float64
+			stride := 0
+			interpolation := InterpolationLinear
+
+			for _, input := range daeSamplers[daeID(channel.Source)].Inputs {
+				source := daeAnimationSources[daeID(input.Source)]
+				switch input.Semantic {
+				case "INPUT":
+					times = source.Parse()
+				case "OUTPUT":
+					values = source.Parse()
+					stride = source.Stride()
+				case "INTERPOLATION":
+					if names := strings.Fields(source.NameArray); len(names) > 0 && names[0] == "STEP" {
+						interpolation = InterpolationConstant
+					}
+				}
+			}
+
+			// Only animated transform matrices are supported (rather than individual animated translations, rotations, or scales)
+			if stride != 16 {
+				if !unsupportedTargets[channel.Target] {
+					log.Println("Warning: animation channel targeting " + channel.Target + " doesn't animate a transform matrix, and will be skipped")
+					unsupportedTargets[channel.Target] = true
+				}
+				continue
+			}
+
+			animChannel := anim.Channels[node.Name()]
+			if animChannel == nil {
+				animChannel = anim.AddChannel(node.Name())
+			}
+
+			positionTrack := animChannel.AddTrack(TrackTypePosition)
+			positionTrack.Interpolation = interpolation
+			scaleTrack := animChannel.AddTrack(TrackTypeScale)
+			scaleTrack.Interpolation = interpolation
+			rotationTrack := animChannel.AddTrack(TrackTypeRotation)
+			rotationTrack.Interpolation = interpolation
+
+			for i, t := range times {
+
+				if (i+1)*16 > len(values) {
 					break
 				}
+
+				if t < start || (end > start && t > end) {
+					continue
+				}
+
+				mat := convertTransform(parseDAEMatrix(values[i*16:(i+1)*16]), daeNodeTransforms[targetNodeID])
+				p, s, r := mat.Decompose()
+
+				positionTrack.AddKeyframe(t-start, p)
+				scaleTrack.AddKeyframe(t-start, s)
+				rotationTrack.AddKeyframe(t-start, r.ToQuaternion())
+
+				if t-start > anim.Length {
+					anim.Length = t - start
+				}
+
 			}
 
 		}
 
-		var model INode
+		for _, child := range daeAnim.Children {
+			addAnimationChannels(anim, child, start, end)
+		}
+
+	}
+
+	if len(doc.AnimationClips) > 0 {
+
+		for _, clip := range doc.AnimationClips {
+
+			name := clip.Name
+			if name == "" {
+				name = clip.ID
+			}
+
+			anim := NewAnimation(name)
+			anim.library = scenes
+			scenes.Animations[name] = anim
+
+			for _, instance := range clip.Animations {
+				if daeAnim, exists := daeAnimationsByID[daeID(instance.URL)]; exists {
+					addAnimationChannels(anim, daeAnim, clip.Start, clip.End)
+				}
+			}
 
-		if mesh != nil {
-			model = NewModel(mesh, node.Name)
-		} else {
-			model = NewNode(node.Name)
 		}
 
-		model.setLibrary(scenes)
+	} else {
 
-		mat := node.ParseTransform()
+		for _, daeAnim := range doc.Animations {
 
-		// Correct transform matrix
-		if options.CorrectYUp {
+			// Animations exported from older versions of Blender aren't named or grouped, so they're combined
+			name := daeAnim.Name
+			if name == "" {
+				name = "default"
+			}
 
-			// Tetra's +Y is Blender's +Z
-			by := mat.Column(1)
-			bz := mat.Column(2)
+			anim := scenes.Animations[name]
+			if anim == nil {
+				anim = NewAnimation(name)
+				anim.library = scenes
+				scenes.Animations[name] = anim
+			}
 
-			mat.SetColumn(1, bz)
-			mat.SetColumn(2, by.Invert())
+			addAnimationChannels(anim, daeAnim, 0, 0)
 
-			by = mat.Row(1)
-			bz = mat.Row(2)
+		}
 
-			mat.SetRow(1, bz)
-			mat.SetRow(2, by.Invert())
+	}
+
+	return scenes, nil
 
+}
+
+// parseDAEMesh creates a Mesh from the given geometry, skinned using the provided skin if it's not nil.
+func parseDAEMesh(geo daeGeometry, skin *daeSkin, materialForSymbol func(symbol string) *Material, options *DaeLoadOptions) (*Mesh, error) {
+
+	sourceData := map[string][]float64{}
+	sourceStrides := map[string]int{}
+	sourceNames := map[string]string{}
+
+	for _, source := range geo.Mesh.Sources {
+		sourceData[source.ID] = source.Parse()
+		sourceStrides[source.ID] = source.Stride()
+		sourceNames[source.ID] = source.Name
+		if source.Name == "" {
+			sourceNames[source.ID] = source.ID
 		}
+	}
 
-		// We parse and parent children before setting position, scale, and rotation because by doing it in this order,
-		// we get the child being in its final transform as a result of the parent, rather than parenting leaving the child
-		// at its original position. In other words, in the 3D modeler, the transform of children is the result of transforms
-		// having been set AFTER parenting.
-		for _, child := range node.Children {
-			model.AddChildren(parseDAENode(child))
+	// Reads the element at the given index from the source, returning nil if it's out of range
+	readSource := func(input daeInput, index int) []float64 {
+		id := daeID(input.Source)
+		stride := sourceStrides[id]
+		data := sourceData[id]
+		if index < 0 || (index+1)*stride > len(data) {
+			return nil
 		}
+		return data[index*stride : (index+1)*stride]
+	}
 
-		p, s, r := mat.Decompose()
+	// Skin weights are specified for each position in the geometry.
+	var vertexWeights [][]float32
+	var vertexBones [][]uint16
+	bindShapeMatrix := NewMatrix4()
 
-		model.SetLocalPositionVec(p)
-		model.SetLocalScaleVec(s)
-		model.SetLocalRotation(r)
+	if skin != nil {
 
-		return model
+		var err error
+		vertexWeights, vertexBones, err = parseDAESkinWeights(skin)
+		if err != nil {
+			return nil, err
+		}
+
+		if data := parseDAEFloats(skin.BindShapeMatrix); len(data) >= 16 {
+			bindShapeMatrix = parseDAEMatrix(data)
+		}
+
+	}
+
+	normalMatrix := bindShapeMatrix.Clone()
+	normalMatrix[3][0] = 0
+	normalMatrix[3][1] = 0
+	normalMatrix[3][2] = 0
+
+	mesh := NewMesh(geo.Name)
+
+	colorChannels := map[string]int{}
+
+	for _, prim := range geo.Mesh.Primitives {
+
+		switch prim.XMLName.Local {
+		case "triangles", "polylist", "polygons":
+		default:
+			continue
+		}
+
+		stride := 0
+		for _, input := range prim.Inputs {
+			if input.Offset+1 > stride {
+				stride = input.Offset + 1
+			}
+		}
+
+		if stride == 0 {
+			continue
+		}
+
+		// Each polygon is a list of indices; each corner of the polygon has stride indices.
+		polygons := [][]int{}
+
+		indices := []int{}
+		for _, p := range prim.Indices {
+			polygon, err := parseDAEInts(p)
+			if err != nil {
+				return nil, fmt.Errorf("error parsing DAE data: invalid index in geometry %s: %w", geo.Name, err)
+			}
+			if prim.XMLName.Local == "polygons" {
+				polygons = append(polygons, polygon)
+			} else {
+				indices = append(indices, polygon...)
+			}
+		}
+
+		switch prim.XMLName.Local {
+
+		case "triangles":
+			for i := 0; i+stride*3 <= len(indices); i += stride * 3 {
+				polygons = append(polygons, indices[i:i+stride*3])
+			}
+
+		case "polylist":
+			counts, err := parseDAEInts(prim.VertexCounts)
+			if err != nil {
+				return nil, fmt.Errorf("error parsing DAE data: invalid vertex count in geometry %s: %w", geo.Name, err)
+			}
+			start := 0
+			for _, count := range counts {
+				end := start + count*stride
+				if end > len(indices) {
+					return nil, fmt.Errorf("error parsing DAE data: geometry %s has fewer indices than its vertex counts specify", geo.Name)
+				}
+				polygons = append(polygons, indices[start:end])
+				start = end
+			}
+
+		}
+
+		colorInputs := []daeInput{}
+		for _, input := range prim.Inputs {
+			if input.Semantic == "COLOR" {
+				colorInputs = append(colorInputs, input)
+			}
+		}
+		for _, input := range geo.Mesh.Vertices.Inputs {
+			if input.Semantic == "COLOR" {
+				colorInputs = append(colorInputs, input)
+			}
+		}
+		for _, input := range colorInputs {
+			name := sourceNames[daeID(input.Source)]
+			if _, exists := colorChannels[name]; !exists {
+				colorChannels[name] = len(colorChannels)
+			}
+		}
+
+		vertexFromCorner := func(corner []int) (VertexInfo, error) {
+
+			vert := NewVertex(0, 0, 0, 0, 0)
+			hasUV := false
+
+			readInput := func(input daeInput, index int) error {
+
+				values := readSource(input, index)
+
+				if values == nil {
+					return fmt.Errorf("error parsing DAE data: geometry %s has an index outside of source %s", geo.Name, daeID(input.Source))
+				}
+
+				switch input.Semantic {
+
+				case "POSITION":
+					if len(values) >= 3 {
+						vert.X, vert.Y, vert.Z = fastMatrixMultVec(bindShapeMatrix, vector.Vector{values[0], values[1], values[2]})
+					}
+					if vertexWeights != nil && index < len(vertexWeights) {
+						vert.Weights = vertexWeights[index]
+						vert.Bones = vertexBones[index]
+					}
+
+				case "NORMAL":
+					if len(values) >= 3 {
+						vert.NormalX, vert.NormalY, vert.NormalZ = fastMatrixMultVec(normalMatrix, vector.Vector{values[0], values[1], values[2]})
+					}
+
+				case "TEXCOORD":
+					if !hasUV && len(values) >= 2 {
+						vert.U = values[0]
+						vert.V = values[1]
+						hasUV = true
+					}
+
+				case "COLOR":
+					color := NewColor(1, 1, 1, 1)
+					if len(values) >= 3 {
+						color.R, color.G, color.B = float32(values[0]), float32(values[1]), float32(values[2])
+					}
+					if len(values) >= 4 {
+						color.A = float32(values[3])
+					}
+					channel := colorChannels[sourceNames[daeID(input.Source)]]
+					for len(vert.Colors) <= channel {
+						vert.Colors = append(vert.Colors, NewColor(1, 1, 1, 1))
+					}
+					vert.Colors[channel] = color
+					vert.ActiveColorChannel = 0
+
+				}
+
+				return nil
+
+			}
+
+			for _, input := range prim.Inputs {
+
+				index := corner[input.Offset]
+
+				if input.Semantic == "VERTEX" {
+					// The VERTEX input refers to the geometry's <vertices> element, which can contain multiple inputs
+					for _, vertexInput := range geo.Mesh.Vertices.Inputs {
+						if err := readInput(vertexInput, index); err != nil {
+							return vert, err
+						}
+					}
+				} else if err := readInput(input, index); err != nil {
+					return vert, err
+				}
+
+			}
+
+			if options.CorrectYUp {
+				// Tetra's +Y is Blender's +Z
+				vert.Y, vert.Z = vert.Z, -vert.Y
+				vert.NormalY, vert.NormalZ = vert.NormalZ, -vert.NormalY
+			}
+
+			return vert, nil
+
+		}
+
+		verts := []VertexInfo{}
+
+		for _, polygon := range polygons {
+
+			corners := len(polygon) / stride
+
+			// Polygons are triangulated as fans
+			for i := 1; i < corners-1; i++ {
+				for _, c := range []int{0, i, i + 1} {
+					vert, err := vertexFromCorner(polygon[c*stride : (c+1)*stride])
+					if err != nil {
+						return nil, err
+					}
+					verts = append(verts, vert)
+				}
+			}
+
+		}
+
+		if len(verts) > 0 {
+			mesh.AddMeshPart(materialForSymbol(prim.MaterialName)).AddTriangles(verts...)
+		}
 
 	}
 
-	for _, node := range daeScene.LibraryScene.Nodes {
-		scene.Root.AddChildren(parseDAENode(node))
+	for name, index := range colorChannels {
+		mesh.VertexColorChannelNames[name] = index
 	}
 
-	return scenes, nil
+	mesh.UpdateBounds()
+
+	return mesh, nil
+
+}
+
+// parseDAESkinWeights returns the bone weights and the bone indices for each position in the geometry skinned by the given skin.
+func parseDAESkinWeights(skin *daeSkin) ([][]float32, [][]uint16, error) {
+
+	var weights []float64
+	jointOffset, weightOffset := -1, -1
+	stride := 0
+
+	for _, input := range skin.WeightInputs {
+
+		switch input.Semantic {
+		case "JOINT":
+			jointOffset = input.Offset
+		case "WEIGHT":
+			weightOffset = input.Offset
+			for _, source := range skin.Sources {
+				if source.ID == daeID(input.Source) {
+					weights = source.Parse()
+				}
+			}
+		}
+
+		if input.Offset+1 > stride {
+			stride = input.Offset + 1
+		}
+
+	}
+
+	if jointOffset < 0 || weightOffset < 0 {
+		return nil, nil, fmt.Errorf("error parsing DAE data: skin of %s is missing joints or weights", daeID(skin.Source))
+	}
+
+	counts, err := parseDAEInts(skin.VertexCounts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	indices, err := parseDAEInts(skin.Indices)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	vertexWeights := make([][]float32, len(counts))
+	vertexBones := make([][]uint16, len(counts))
+
+	i := 0
+
+	for v, count := range counts {
+
+		vertexWeights[v] = []float32{}
+		vertexBones[v] = []uint16{}
+		total := float32(0)
+
+		for c := 0; c < count; c++ {
+
+			if i+stride > len(indices) {
+				return nil, nil, fmt.Errorf("error parsing DAE data: skin of %s has fewer weights than its vertex counts specify", daeID(skin.Source))
+			}
+
+			joint := indices[i+jointOffset]
+			weightIndex := indices[i+weightOffset]
+			i += stride
+
+			// A joint index of -1 refers to the bind shape itself, rather than a joint
+			if joint < 0 || weightIndex < 0 || weightIndex >= len(weights) || weights[weightIndex] == 0 {
+				continue
+			}
+
+			vertexWeights[v] = append(vertexWeights[v], float32(weights[weightIndex]))
+			vertexBones[v] = append(vertexBones[v], uint16(joint))
+			total += float32(weights[weightIndex])
+
+		}
+
+		// Weights should add up to 1
+		if total > 0 {
+			for w := range vertexWeights[v] {
+				vertexWeights[v][w] /= total
+			}
+		}
+
+	}
+
+	return vertexWeights, vertexBones, nil
+
+}
+
+// parseDAELight creates a light from the given DAE light.
+func parseDAELight(light daeLight, name string) INode {
+
+	blenderEnergy := float32(0)
+	spotBlend := 0.0
+
+	for _, extra := range light.Extras {
+		if extra.Profile == "blender" {
+			blenderEnergy = float32(extra.Energy)
+			spotBlend = extra.SpotBlend
+		}
+	}
+
+	color := NewColor(1, 1, 1, 1)
+
+	for _, lightType := range []*daeLightType{light.Ambient, light.Directional, light.Point, light.Spot} {
+		if lightType != nil {
+			color = daeColor(lightType.Color)
+		}
+	}
+
+	energy := float32(1)
+	pointEnergy := float32(1)
+
+	if blenderEnergy > 0 {
+		// Blender exports light colors multiplied by the light's energy; point and spot light energy is in Watts, with 1000W being standard.
+		color.R /= blenderEnergy
+		color.G /= blenderEnergy
+		color.B /= blenderEnergy
+		energy = blenderEnergy
+		pointEnergy = blenderEnergy / 1000
+	} else if brightest := float32(math.Max(float64(color.R), math.Max(float64(color.G), float64(color.B)))); brightest > 1 {
+		color.R /= brightest
+		color.G /= brightest
+		color.B /= brightest
+		energy = brightest
+		pointEnergy = brightest
+	}
+
+	switch {
+	case light.Directional != nil:
+		return NewDirectionalLight(name, color.R, color.G, color.B, energy)
+	case light.Point != nil:
+		return NewPointLight(name, color.R, color.G, color.B, pointEnergy)
+	case light.Spot != nil:
+		spotLight := NewSpotLight(name, color.R, color.G, color.B, pointEnergy)
+		if light.Spot.FalloffAngle > 0 {
+			spotLight.OuterConeAngle = light.Spot.FalloffAngle * math.Pi / 180 / 2
+			spotLight.InnerConeAngle = spotLight.OuterConeAngle * (1 - spotBlend)
+		}
+		return spotLight
+	default:
+		// Any unsupported light type just gets turned into an ambient light
+		return NewAmbientLight(name, color.R, color.G, color.B, energy)
+	}
 
 }
@@ -0,0 +1,514 @@
+package tetra3d
+
+import (
+	"math"
+	"testing"
+
+	"github.com/kvartborg/vector"
+)
+
+// loadDAE loads a COLLADA document consisting of the given library elements.
+func loadDAE(t *testing.T, libraries string, options *DaeLoadOptions) *Library {
+
+	t.Helper()
+
+	library, err := LoadDAEData([]byte(`<?xml version="1.0" encoding="utf-8"?>
+<COLLADA xmlns="http://www.collada.org/2005/11/COLLADASchema" version="1.4.1">`+libraries+`
+</COLLADA>`), options)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return library
+
+}
+
+// daeTriangle is a geometry consisting of a single triangle, using the material symbol "Material-symbol".
+const daeTriangle = `
+<library_geometries>
+	<geometry id="Triangle-mesh" name="Triangle">
+		<mesh>
+			<source id="Triangle-positions">
+				<float_array id="Triangle-positions-array" count="9">0 0 0 1 0 0 0 1 0</float_array>
+				<technique_common><accessor source="#Triangle-positions-array" count="3" stride="3"/></technique_common>
+			</source>
+			<vertices id="Triangle-vertices"><input semantic="POSITION" source="#Triangle-positions"/></vertices>
+			<triangles material="Material-symbol" count="1">
+				<input semantic="VERTEX" source="#Triangle-vertices" offset="0"/>
+				<p>0 1 2</p>
+			</triangles>
+		</mesh>
+	</geometry>
+</library_geometries>`
+
+func TestLoadDAEDataMaterials(t *testing.T) {
+
+	library := loadDAE(t, `
+<library_images>
+	<image id="wall_png" name="wall_png"><init_from>textures/wall.png</init_from></image>
+</library_images>
+<library_effects>
+	<effect id="Textured-effect">
+		<profile_COMMON>
+			<newparam sid="wall_png-surface"><surface type="2D"><init_from>wall_png</init_from></surface></newparam>
+			<newparam sid="wall_png-sampler"><sampler2D><source>wall_png-surface</source></sampler2D></newparam>
+			<technique sid="common">
+				<lambert>
+					<emission><color>1 0 0 1</color></emission>
+					<diffuse><texture texture="wall_png-sampler" texcoord="UVMap"/></diffuse>
+				</lambert>
+			</technique>
+		</profile_COMMON>
+	</effect>
+	<effect id="Flat-effect">
+		<profile_COMMON>
+			<technique sid="common">
+				<constant><diffuse><color>0 1 0 1</color></diffuse></constant>
+			</technique>
+		</profile_COMMON>
+	</effect>
+</library_effects>
+<library_materials>
+	<material id="Textured-material" name="Textured"><instance_effect url="#Textured-effect"/></material>
+	<material id="Flat-material" name="Flat"><instance_effect url="#Flat-effect"/></material>
+</library_materials>`+daeTriangle+`
+<library_visual_scenes>
+	<visual_scene id="Scene" name="Scene">
+		<node id="Triangle" name="Triangle" type="NODE">
+			<instance_geometry url="#Triangle-mesh" name="Triangle">
+				<bind_material><technique_common>
+					<instance_material symbol="Material-symbol" target="#Textured-material"/>
+				</technique_common></bind_material>
+			</instance_geometry>
+		</node>
+	</visual_scene>
+</library_visual_scenes>
+<scene><instance_visual_scene url="#Scene"/></scene>`, nil)
+
+	textured, flat := library.Materials["Textured"], library.Materials["Flat"]
+
+	if textured == nil || flat == nil {
+		t.Fatalf("expected Materials named Textured and Flat, got %v", library.Materials)
+	}
+
+	// The primitive's material symbol is bound to a material by the node instancing the geometry.
+	if mat := library.Meshes["Triangle"].MeshParts[0].Material; mat != textured {
+		t.Errorf("the Triangle Mesh uses Material %v, expected Textured", mat)
+	}
+
+	if textured.TexturePath != "textures/wall.png" {
+		t.Errorf("expected a texture path of %q, got %q", "textures/wall.png", textured.TexturePath)
+	}
+
+	if !colorsEqual(textured.EmissiveColor, NewColor(1, 0, 0, 1)) {
+		t.Errorf("expected an emissive color of (1, 0, 0, 1), got %v", textured.EmissiveColor)
+	}
+
+	if textured.Shadeless || !flat.Shadeless {
+		t.Errorf("only Materials using constant shading should be shadeless")
+	}
+
+	if !colorsEqual(flat.Color, NewColor(0, 1, 0, 1)) {
+		t.Errorf("expected a diffuse color of (0, 1, 0, 1), got %v", flat.Color)
+	}
+
+}
+
+func TestLoadDAEDataPolygons(t *testing.T) {
+
+	options := DefaultDaeLoadOptions()
+	options.CorrectYUp = false
+
+	// A quad and a triangle in a polylist, and a pentagon as <polygons>
+	library := loadDAE(t, `
+<library_geometries>
+	<geometry id="Shapes-mesh" name="Shapes">
+		<mesh>
+			<source id="Shapes-positions">
+				<float_array id="Shapes-positions-array" count="15">0 0 0 1 0 0 2 1 0 1 2 0 0 1 0</float_array>
+				<technique_common><accessor source="#Shapes-positions-array" count="5" stride="3"/></technique_common>
+			</source>
+			<source id="Shapes-normals">
+				<float_array id="Shapes-normals-array" count="3">0 0 1</float_array>
+				<technique_common><accessor source="#Shapes-normals-array" count="1" stride="3"/></technique_common>
+			</source>
+			<vertices id="Shapes-vertices"><input semantic="POSITION" source="#Shapes-positions"/></vertices>
+			<polylist count="2">
+				<input semantic="VERTEX" source="#Shapes-vertices" offset="0"/>
+				<input semantic="NORMAL" source="#Shapes-normals" offset="1"/>
+				<vcount>4 3</vcount>
+				<p>0 0 1 0 2 0 4 0 1 0 2 0 3 0</p>
+			</polylist>
+			<polygons count="1">
+				<input semantic="VERTEX" source="#Shapes-vertices" offset="0"/>
+				<input semantic="NORMAL" source="#Shapes-normals" offset="1"/>
+				<p>0 0 1 0 2 0 3 0 4 0</p>
+			</polygons>
+		</mesh>
+	</geometry>
+</library_geometries>`, options)
+
+	mesh := library.Meshes["Shapes"]
+
+	if len(mesh.MeshParts) != 2 {
+		t.Fatalf("expected a MeshPart for each primitive, got %d", len(mesh.MeshParts))
+	}
+
+	if count := mesh.MeshParts[0].TriangleEnd - mesh.MeshParts[0].TriangleStart; count != 3 {
+		t.Errorf("expected the polylist to be split into 3 triangles, got %d", count)
+	}
+
+	if count := mesh.MeshParts[1].TriangleEnd - mesh.MeshParts[1].TriangleStart; count != 3 {
+		t.Errorf("expected the pentagon to be split into 3 triangles, got %d", count)
+	}
+
+	corners := []vector.Vector{{0, 0, 0}, {1, 0, 0}, {2, 1, 0}, {1, 2, 0}, {0, 1, 0}}
+
+	// Each polygon is triangulated as a fan starting at its first corner.
+	expected := [][]int{
+		{0, 1, 2}, {0, 2, 4}, {1, 2, 3},
+		{0, 1, 2}, {0, 2, 3}, {0, 3, 4},
+	}
+
+	for triIndex, tri := range expected {
+		for v, corner := range tri {
+			if position := mesh.VertexPositions[triIndex*3+v]; !vectorsEqual(position, corners[corner]) {
+				t.Errorf("vertex %d of triangle %d is %v, expected %v", v, triIndex, position, corners[corner])
+			}
+		}
+	}
+
+	for i := 0; i < mesh.VertexCount; i++ {
+		if !vectorsEqual(mesh.VertexNormals[i], vector.Vector{0, 0, 1}) {
+			t.Errorf("vertex %d has normal %v, expected {0, 0, 1}", i, mesh.VertexNormals[i])
+		}
+	}
+
+	// Converting from Z-up, Blender's +Z becomes +Y and Blender's +Y becomes -Z.
+	mesh = loadDAE(t, daeTriangle, nil).Meshes["Triangle"]
+
+	if !vectorsEqual(mesh.VertexPositions[2], vector.Vector{0, 0, -1}) {
+		t.Errorf("vertex {0, 1, 0} was converted to %v, expected {0, 0, -1}", mesh.VertexPositions[2])
+	}
+
+}
+
+func TestLoadDAEDataNodes(t *testing.T) {
+
+	library := loadDAE(t, daeTriangle+`
+<library_visual_scenes>
+	<visual_scene id="Scene" name="Scene">
+		<node id="Parent" name="Parent" type="NODE">
+			<translate sid="location">1 2 3</translate>
+			<rotate sid="rotationZ">0 0 1 90</rotate>
+			<node id="Child" name="Child" type="NODE">
+				<matrix sid="transform">1 0 0 1 0 1 0 0 0 0 1 0 0 0 0 1</matrix>
+				<instance_geometry url="#Triangle-mesh" name="Child"/>
+			</node>
+		</node>
+	</visual_scene>
+</library_visual_scenes>
+<scene><instance_visual_scene url="#Scene"/></scene>`, nil)
+
+	scene := library.ExportedScene
+	if scene == nil || scene.Name != "Scene" {
+		t.Fatalf("expected the exported Scene to be Scene, got %v", scene)
+	}
+
+	parent := scene.Root.Get("Parent")
+	if parent == nil {
+		t.Fatalf("node Parent wasn't loaded")
+	}
+
+	child, isModel := parent.Get("Child").(*Model)
+	if !isModel {
+		t.Fatalf("expected Child to be a Model parented to Parent, got %v", parent.Children())
+	}
+
+	if child.Mesh != library.Meshes["Triangle"] {
+		t.Errorf("Child doesn't use the Triangle Mesh")
+	}
+
+	// Parent is at (1, 2, 3) and rotated 90 degrees around Blender's Z axis, so Child's local +X offset points along Blender's +Y.
+	if position := parent.WorldPosition(); !vectorsEqual(position, vector.Vector{1, 3, -2}) {
+		t.Errorf("Parent is at %v, expected {1, 3, -2}", position)
+	}
+
+	if position := child.WorldPosition(); !vectorsEqual(position, vector.Vector{1, 3, -3}) {
+		t.Errorf("Child is at %v, expected {1, 3, -3}", position)
+	}
+
+}
+
+func TestLoadDAEDataCamerasAndLights(t *testing.T) {
+
+	library := loadDAE(t, `
+<library_cameras>
+	<camera id="Camera-camera" name="Camera">
+		<optics><technique_common>
+			<orthographic><xmag>4</xmag><aspect_ratio>1.5</aspect_ratio><znear>0.5</znear><zfar>50</zfar></orthographic>
+		</technique_common></optics>
+	</camera>
+</library_cameras>
+<library_lights>
+	<light id="Point-light" name="Point">
+		<technique_common><point><color>1000 500 0</color></point></technique_common>
+		<extra><technique profile="blender"><energy>1000</energy></technique></extra>
+	</light>
+	<light id="Sun-light" name="Sun">
+		<technique_common><directional><color>2 2 2</color></directional></technique_common>
+		<extra><technique profile="blender"><energy>2</energy></technique></extra>
+	</light>
+</library_lights>
+<library_visual_scenes>
+	<visual_scene id="Scene" name="Scene">
+		<node id="Camera" name="Camera" type="NODE">
+			<translate sid="location">0 0 5</translate>
+			<instance_camera url="#Camera-camera"/>
+			<node id="Target" name="Target" type="NODE">
+				<translate sid="location">0 0 -2</translate>
+			</node>
+		</node>
+		<node id="Point" name="Point" type="NODE">
+			<instance_light url="#Point-light"/>
+		</node>
+		<node id="Sun" name="Sun" type="NODE">
+			<instance_light url="#Sun-light"/>
+		</node>
+	</visual_scene>
+</library_visual_scenes>`, nil)
+
+	root := library.Scenes[0].Root
+
+	camera, isCamera := root.Get("Camera").(*Camera)
+	if !isCamera {
+		t.Fatalf("expected Camera to be a Camera, got %T", root.Get("Camera"))
+	}
+
+	if camera.Perspective || camera.OrthoScale != 4 || camera.Near != 0.5 || camera.Far != 50 {
+		t.Errorf("expected an orthographic Camera with a scale of 4 from 0.5 to 50, got perspective: %t, scale: %f, near: %f, far: %f",
+			camera.Perspective, camera.OrthoScale, camera.Near, camera.Far)
+	}
+
+	// Cameras and lights face down Blender's -Z axis when unrotated, which is down in Tetra3D; as Tetra3D's Cameras face -Z, they're rotated to match.
+	if forward := camera.WorldRotation().Forward(); !vectorsEqual(forward, vector.Vector{0, 1, 0}) {
+		t.Errorf("the Camera should face down (with its +Z axis pointing up), but its +Z axis points along %v", forward)
+	}
+
+	// Children are rotated back, so they stay where they are in Blender (2 units in front of the Camera).
+	if position := camera.Get("Target").WorldPosition(); !vectorsEqual(position, vector.Vector{0, 3, 0}) {
+		t.Errorf("the Camera's child is at %v, expected {0, 3, 0}", position)
+	}
+
+	point, isPointLight := root.Get("Point").(*PointLight)
+	if !isPointLight {
+		t.Fatalf("expected Point to be a PointLight, got %T", root.Get("Point"))
+	}
+
+	// Blender's light colors are multiplied by the light's energy, with point lights' energy being in Watts.
+	if !colorsEqual(point.Color, NewColor(1, 0.5, 0, 1)) || point.Energy != 1 {
+		t.Errorf("expected a point light color of (1, 0.5, 0) with an energy of 1, got %v with an energy of %f", point.Color, point.Energy)
+	}
+
+	sun, isSun := root.Get("Sun").(*DirectionalLight)
+	if !isSun {
+		t.Fatalf("expected Sun to be a DirectionalLight, got %T", root.Get("Sun"))
+	}
+
+	if !colorsEqual(sun.Color, NewColor(1, 1, 1, 1)) || sun.Energy != 2 {
+		t.Errorf("expected a directional light color of (1, 1, 1) with an energy of 2, got %v with an energy of %f", sun.Color, sun.Energy)
+	}
+
+	if forward := sun.WorldRotation().Forward(); !vectorsEqual(forward, vector.Vector{0, 1, 0}) {
+		t.Errorf("the Sun should shine down (with its +Z axis pointing up), but its +Z axis points along %v", forward)
+	}
+
+}
+
+func TestLoadDAEDataVertexColors(t *testing.T) {
+
+	options := DefaultDaeLoadOptions()
+	options.CorrectYUp = false
+
+	mesh := loadDAE(t, `
+<library_geometries>
+	<geometry id="Triangle-mesh" name="Triangle">
+		<mesh>
+			<source id="Triangle-positions">
+				<float_array id="Triangle-positions-array" count="9">0 0 0 1 0 0 0 1 0</float_array>
+				<technique_common><accessor source="#Triangle-positions-array" count="3" stride="3"/></technique_common>
+			</source>
+			<source id="Triangle-colors-Col" name="Col">
+				<float_array id="Triangle-colors-Col-array" count="8">1 0 0 1 0 0 1 0.5</float_array>
+				<technique_common><accessor source="#Triangle-colors-Col-array" count="2" stride="4"/></technique_common>
+			</source>
+			<vertices id="Triangle-vertices"><input semantic="POSITION" source="#Triangle-positions"/></vertices>
+			<triangles count="1">
+				<input semantic="VERTEX" source="#Triangle-vertices" offset="0"/>
+				<input semantic="COLOR" source="#Triangle-colors-Col" offset="1" set="0"/>
+				<p>0 0 1 1 2 0</p>
+			</triangles>
+		</mesh>
+	</geometry>
+</library_geometries>`, options).Meshes["Triangle"]
+
+	if channel, exists := mesh.VertexColorChannelNames["Col"]; !exists || channel != 0 {
+		t.Fatalf("expected a vertex color channel named Col at index 0, got %v", mesh.VertexColorChannelNames)
+	}
+
+	expected := []*Color{NewColor(1, 0, 0, 1), NewColor(0, 0, 1, 0.5), NewColor(1, 0, 0, 1)}
+
+	for i, color := range expected {
+		if len(mesh.VertexColors[i]) == 0 || !colorsEqual(mesh.VertexColors[i][0], color) {
+			t.Errorf("vertex %d has colors %v, expected %v", i, mesh.VertexColors[i], color)
+		}
+		if mesh.VertexActiveColorChannel[i] != 0 {
+			t.Errorf("vertex %d has active color channel %d, expected 0", i, mesh.VertexActiveColorChannel[i])
+		}
+	}
+
+}
+
+func TestLoadDAEDataSkinningAndAnimation(t *testing.T) {
+
+	library := loadDAE(t, daeTriangle+`
+<library_controllers>
+	<controller id="Armature_Triangle-skin" name="Armature">
+		<skin source="#Triangle-mesh">
+			<bind_shape_matrix>1 0 0 0 0 1 0 0 0 0 1 0 0 0 0 1</bind_shape_matrix>
+			<source id="Armature_Triangle-skin-joints">
+				<Name_array id="Armature_Triangle-skin-joints-array" count="2">Root Tip</Name_array>
+				<technique_common><accessor source="#Armature_Triangle-skin-joints-array" count="2" stride="1"/></technique_common>
+			</source>
+			<source id="Armature_Triangle-skin-bind_poses">
+				<float_array id="Armature_Triangle-skin-bind_poses-array" count="32">
+					1 0 0 0 0 1 0 0 0 0 1 0 0 0 0 1
+					1 0 0 0 0 1 0 0 0 0 1 -1 0 0 0 1
+				</float_array>
+				<technique_common><accessor source="#Armature_Triangle-skin-bind_poses-array" count="2" stride="16"/></technique_common>
+			</source>
+			<source id="Armature_Triangle-skin-weights">
+				<float_array id="Armature_Triangle-skin-weights-array" count="4">1 0.25 0.5 0</float_array>
+				<technique_common><accessor source="#Armature_Triangle-skin-weights-array" count="4" stride="1"/></technique_common>
+			</source>
+			<joints>
+				<input semantic="JOINT" source="#Armature_Triangle-skin-joints"/>
+				<input semantic="INV_BIND_MATRIX" source="#Armature_Triangle-skin-bind_poses"/>
+			</joints>
+			<vertex_weights count="3">
+				<input semantic="JOINT" source="#Armature_Triangle-skin-joints" offset="0"/>
+				<input semantic="WEIGHT" source="#Armature_Triangle-skin-weights" offset="1"/>
+				<vcount>1 2 2</vcount>
+				<v>0 0 0 1 1 2 1 0 0 3</v>
+			</vertex_weights>
+		</skin>
+	</controller>
+</library_controllers>
+<library_animations>
+	<animation id="action_container-Armature" name="Armature">
+		<animation id="Armature_Tip_pose_matrix" name="Armature">
+			<source id="Armature_Tip_pose_matrix-input">
+				<float_array id="Armature_Tip_pose_matrix-input-array" count="2">0 1</float_array>
+				<technique_common><accessor source="#Armature_Tip_pose_matrix-input-array" count="2" stride="1"/></technique_common>
+			</source>
+			<source id="Armature_Tip_pose_matrix-output">
+				<float_array id="Armature_Tip_pose_matrix-output-array" count="32">
+					1 0 0 0 0 1 0 0 0 0 1 1 0 0 0 1
+					1 0 0 2 0 1 0 0 0 0 1 1 0 0 0 1
+				</float_array>
+				<technique_common><accessor source="#Armature_Tip_pose_matrix-output-array" count="2" stride="16"/></technique_common>
+			</source>
+			<source id="Armature_Tip_pose_matrix-interpolation">
+				<Name_array id="Armature_Tip_pose_matrix-interpolation-array" count="2">LINEAR LINEAR</Name_array>
+				<technique_common><accessor source="#Armature_Tip_pose_matrix-interpolation-array" count="2" stride="1"/></technique_common>
+			</source>
+			<sampler id="Armature_Tip_pose_matrix-sampler">
+				<input semantic="INPUT" source="#Armature_Tip_pose_matrix-input"/>
+				<input semantic="OUTPUT" source="#Armature_Tip_pose_matrix-output"/>
+				<input semantic="INTERPOLATION" source="#Armature_Tip_pose_matrix-interpolation"/>
+			</sampler>
+			<channel source="#Armature_Tip_pose_matrix-sampler" target="Armature_Tip/transform"/>
+		</animation>
+	</animation>
+</library_animations>
+<library_visual_scenes>
+	<visual_scene id="Scene" name="Scene">
+		<node id="Armature" name="Armature" type="NODE">
+			<node id="Armature_Root" name="Root" sid="Root" type="JOINT">
+				<node id="Armature_Tip" name="Tip" sid="Tip" type="JOINT">
+					<matrix sid="transform">1 0 0 0 0 1 0 0 0 0 1 1 0 0 0 1</matrix>
+				</node>
+			</node>
+		</node>
+		<node id="Triangle" name="Triangle" type="NODE">
+			<instance_controller url="#Armature_Triangle-skin">
+				<skeleton>#Armature_Root</skeleton>
+			</instance_controller>
+		</node>
+	</visual_scene>
+</library_visual_scenes>`, nil)
+
+	root := library.Scenes[0].Root
+
+	model, isModel := root.Get("Triangle").(*Model)
+	if !isModel {
+		t.Fatalf("expected Triangle to be a Model, got %T", root.Get("Triangle"))
+	}
+
+	armature := root.Get("Armature")
+	bone := root.Get("Armature/Root")
+	tip := root.Get("Armature/Root/Tip")
+
+	// As with GLTF files, the skin root is the node containing the hierarchy of bones.
+	if !model.Skinned || model.SkinRoot != armature {
+		t.Fatalf("expected Triangle to be skinned with a skin root of Armature, got skinned: %t, root: %v", model.Skinned, model.SkinRoot.Name())
+	}
+
+	// Weights are normalized, and zero weights are dropped.
+	expectedBones := [][]INode{{bone}, {bone, tip}, {tip}}
+	expectedWeights := [][]float32{{1}, {1.0 / 3, 2.0 / 3}, {1}}
+
+	for i := range expectedBones {
+		if len(model.bones[i]) != len(expectedBones[i]) {
+			t.Errorf("vertex %d is weighted to %d bones, expected %d", i, len(model.bones[i]), len(expectedBones[i]))
+			continue
+		}
+		for b, expected := range expectedBones[i] {
+			if model.bones[i][b] != expected || math.Abs(float64(model.Mesh.VertexWeights[i][b]-expectedWeights[i][b])) > 1e-4 {
+				t.Errorf("vertex %d is weighted %f to %s, expected %f to %s", i, model.Mesh.VertexWeights[i][b], model.bones[i][b].Name(), expectedWeights[i][b], expected.Name())
+			}
+		}
+	}
+
+	if !matricesEqual(tip.(*Node).inverseBindMatrix, NewMatrix4Translate(0, -1, 0)) {
+		t.Errorf("Tip has an inverse bind matrix of %v, expected a translation of {0, -1, 0}", tip.(*Node).inverseBindMatrix)
+	}
+
+	// The animation isn't part of a clip, so it's loaded using its own name.
+	anim := library.Animations["Armature"]
+	if anim == nil {
+		t.Fatalf("expected an Animation named Armature, got %v", library.Animations)
+	}
+
+	if anim.Length != 1 {
+		t.Errorf("expected the Animation to be 1 second long, got %f", anim.Length)
+	}
+
+	channel := anim.Channels["Tip"]
+	if channel == nil {
+		t.Fatalf("expected the Animation to have a channel for Tip, got %v", anim.Channels)
+	}
+
+	positions := channel.Tracks[TrackTypePosition]
+	if positions == nil || len(positions.Keyframes) != 2 {
+		t.Fatalf("expected the Tip channel to have a position track with 2 keyframes")
+	}
+
+	for i, expected := range []vector.Vector{{0, 1, 0}, {2, 1, 0}} {
+		if position := positions.Keyframes[i].Data.AsVector(); !vectorsEqual(position, expected) || positions.Keyframes[i].Time != float64(i) {
+			t.Errorf("keyframe %d positions Tip at %v at %f seconds, expected %v at %d seconds", i, position, positions.Keyframes[i].Time, expected, i)
+		}
+	}
+
+}
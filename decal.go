@@ -0,0 +1,395 @@
+package tetra3d
+
+import (
+	"math"
+
+	"github.com/kvartborg/vector"
+)
+
+// Decal is a single decal projected by a DecalSystem onto the surfaces of Models, like a bullet hole, a blood splat, or a footprint.
+type Decal struct {
+	Model    *Model  // The Model displaying the Decal; its Mesh holds the clipped and projected triangles, in world space.
+	Life     float64 // How long the Decal has been alive, in seconds.
+	Lifetime float64 // How long the Decal lives, in seconds, before it is removed. If 0, the Decal lives until it is removed, or until it's replaced by a newer Decal.
+	FadeTime float64 // How long the Decal takes to fade out at the end of its Lifetime, in seconds.
+}
+
+// DecalSystem projects Decals onto Models and manages them, capping the number of Decals alive at once and fading them out over time.
+// Decals are generated by clipping the triangles of any Models touching a projector box to that box, and mapping the box's X and Y axes to the
+// texture's U and V coordinates; the box projects along its local -Z axis.
+// Note that Decals are generated in world space from the Models' current transforms, so they don't follow Models that move afterwards; skinned Models
+// are skipped for the same reason.
+type DecalSystem struct {
+	Root     INode     // The Node Decals' Models are parented to (usually the Scene's Root).
+	Material *Material // The Material used to render Decals. To fade Decals out, the Material's TransparencyMode should be set to TransparencyModeTransparent.
+
+	// MaxDecals is the maximum number of Decals alive at once; when a Decal is projected beyond this limit, the oldest Decal is removed.
+	// If MaxDecals is 0 or below, there's no limit.
+	MaxDecals int
+
+	Lifetime float64 // How long new Decals live, in seconds. If 0 (the default), Decals live until they're removed or replaced.
+	FadeTime float64 // How long new Decals take to fade out at the end of their Lifetime, in seconds.
+
+	// DepthBias is how far Decals are pushed away from the surfaces they're projected onto (towards the projector) to keep them from
+	// z-fighting with those surfaces. Defaults to 0.01.
+	DepthBias float64
+
+	// MaxAngle is the maximum angle (in radians) between a surface and the projector for Decals to be projected onto it;
+	// triangles facing further away than this from the projector are skipped. Defaults to 80 degrees.
+	MaxAngle float64
+
+	Decals []*Decal // The Decals currently alive, ordered from oldest to newest.
+}
+
+// NewDecalSystem creates a new DecalSystem that parents its Decals to the root Node given, renders them using the given Material, and keeps
+// at most maxDecals Decals alive at once.
+func NewDecalSystem(root INode, material *Material, maxDecals int) *DecalSystem {
+	return &DecalSystem{
+		Root:      root,
+		Material:  material,
+		MaxDecals: maxDecals,
+		DepthBias: 0.01,
+		MaxAngle:  ToRadians(80),
+		Decals:    []*Decal{},
+	}
+}
+
+// Clone creates a new DecalSystem with the same settings as the original. The original's Decals are not cloned.
+func (ds *DecalSystem) Clone() *DecalSystem {
+	newDS := NewDecalSystem(ds.Root, ds.Material, ds.MaxDecals)
+	newDS.Lifetime = ds.Lifetime
+	newDS.FadeTime = ds.FadeTime
+	newDS.DepthBias = ds.DepthBias
+	newDS.MaxAngle = ds.MaxAngle
+	return newDS
+}
+
+// Project projects a Decal onto the Models under the target Nodes (including the targets themselves). The Decal is centered on position, and
+// projected along the opposite of normal (so the normal should point away from the surface, like the Normal of a Collision or a PickResult).
+// width and height are the size of the Decal, while depth is how far the projection reaches (in total, both in front of and behind the position).
+// If nothing is hit, Project returns nil.
+func (ds *DecalSystem) Project(position, normal vector.Vector, width, height, depth float64, targets ...INode) *Decal {
+
+	normal = normal.Unit()
+
+	up := vector.Vector{0, 1, 0}
+	if math.Abs(normal.Dot(up)) > 0.99 {
+		up = vector.Vector{0, 0, -1}
+	}
+
+	projector := NewMatrix4Scale(width/2, height/2, depth/2)
+	projector = projector.Mult(NewLookAtMatrix(position, position.Add(normal), up))
+	projector = projector.Mult(NewMatrix4Translate(position[0], position[1], position[2]))
+
+	return ds.ProjectBox(projector, targets...)
+
+}
+
+// ProjectBox projects a Decal onto the Models under the target Nodes (including the targets themselves) using the projector transform given.
+// The projector box is a cube spanning from -1 to 1 on each axis, transformed by the projector Matrix4 (so a Node's Transform() can be used
+// as a projector, with its scale being half of the box's size); the Decal is projected along the box's local -Z axis. BoundingTriangles targets are
+// projected onto as well, using their broadphase to find the triangles within the box, unless they share their Mesh with a Model that's
+// their parent or sibling (as that Model's triangles are already projected onto). If nothing is hit, ProjectBox returns nil.
+func (ds *DecalSystem) ProjectBox(projector Matrix4, targets ...INode) *Decal {
+
+	mesh := ds.projectMesh(projector, targets)
+
+	if mesh == nil {
+		return nil
+	}
+
+	decal := &Decal{
+		Model:    NewModel(mesh, "Decal"),
+		Lifetime: ds.Lifetime,
+		FadeTime: ds.FadeTime,
+	}
+
+	if ds.Root != nil {
+		ds.Root.AddChildren(decal.Model)
+	}
+
+	ds.Decals = append(ds.Decals, decal)
+
+	if ds.MaxDecals > 0 {
+		for len(ds.Decals) > ds.MaxDecals {
+			ds.Remove(ds.Decals[0])
+		}
+	}
+
+	return decal
+
+}
+
+// Update updates the Decals' lives, fading them out and removing them at the end of their Lifetimes. It should be called once per tick.
+func (ds *DecalSystem) Update(dt float64) {
+
+	for _, decal := range append([]*Decal{}, ds.Decals...) {
+
+		if decal.Lifetime <= 0 {
+			continue
+		}
+
+		decal.Life += dt
+
+		if decal.Life >= decal.Lifetime {
+			ds.Remove(decal)
+			continue
+		}
+
+		if remaining := decal.Lifetime - decal.Life; decal.FadeTime > 0 && remaining < decal.FadeTime {
+			decal.Model.Color.A = float32(remaining / decal.FadeTime)
+		}
+
+	}
+
+}
+
+// Remove removes the given Decal from the DecalSystem, unparenting its Model.
+func (ds *DecalSystem) Remove(decal *Decal) {
+	for i, existing := range ds.Decals {
+		if existing == decal {
+			decal.Model.Unparent()
+			ds.Decals[i] = nil
+			ds.Decals = append(ds.Decals[:i], ds.Decals[i+1:]...)
+			return
+		}
+	}
+}
+
+// Clear removes all Decals from the DecalSystem.
+func (ds *DecalSystem) Clear() {
+	for _, decal := range ds.Decals {
+		decal.Model.Unparent()
+	}
+	ds.Decals = []*Decal{}
+}
+
+// decalVertex is a vertex of a triangle being clipped to a decal projector; position is in the projector's local space, while normal is in world space.
+type decalVertex struct {
+	position vector.Vector
+	normal   vector.Vector
+}
+
+func (dv decalVertex) lerp(other decalVertex, t float64) decalVertex {
+	return decalVertex{
+		position: dv.position.Add(other.position.Sub(dv.position).Scale(t)),
+		normal:   dv.normal.Add(other.normal.Sub(dv.normal).Scale(t)),
+	}
+}
+
+// projectMesh creates a Mesh out of the triangles of the targets clipped to the projector box, or returns nil if there are none.
+func (ds *DecalSystem) projectMesh(projector Matrix4, targets []INode) *Mesh {
+
+	position, scale, rotation := projector.Decompose()
+	inverse := projector.Inverted()
+
+	// The projector's bounding sphere, used to skip Models that can't be touching it.
+	radius := vector.Vector{scale[0], scale[1], scale[2]}.Magnitude()
+
+	forward := rotation.MultVec(vector.Vector{0, 0, 1}).Unit()
+	minFacing := math.Cos(ds.MaxAngle)
+
+	decalModels := make(map[*Model]bool, len(ds.Decals))
+	for _, decal := range ds.Decals {
+		decalModels[decal.Model] = true
+	}
+
+	verts := []VertexInfo{}
+
+	// addTriangle clips the given triangle (in world space) to the projector box and adds the resulting triangles to the vertex list.
+	addTriangle := func(p0, p1, p2, n0, n1, n2 vector.Vector) {
+
+		if calculateNormal(p0, p1, p2).Dot(forward) < minFacing {
+			return
+		}
+
+		polygon := []decalVertex{
+			{inverse.MultVec(p0), n0},
+			{inverse.MultVec(p1), n1},
+			{inverse.MultVec(p2), n2},
+		}
+
+		for axis := 0; axis < 3 && len(polygon) > 0; axis++ {
+			polygon = clipDecalPolygon(polygon, axis, 1)
+			polygon = clipDecalPolygon(polygon, axis, -1)
+		}
+
+		if len(polygon) < 3 {
+			return
+		}
+
+		outVerts := make([]VertexInfo, 0, len(polygon))
+
+		for _, dv := range polygon {
+
+			world := projector.MultVec(dv.position).Add(forward.Scale(ds.DepthBias))
+			vert := NewVertex(world[0], world[1], world[2], (dv.position[0]+1)/2, (dv.position[1]+1)/2)
+
+			if dv.normal.Magnitude() > 0 {
+				normal := dv.normal.Unit()
+				vert.NormalX = normal[0]
+				vert.NormalY = normal[1]
+				vert.NormalZ = normal[2]
+			}
+
+			outVerts = append(outVerts, vert)
+
+		}
+
+		// The clipped polygon is convex, so it can be triangulated as a fan.
+		for i := 1; i < len(outVerts)-1; i++ {
+			verts = append(verts, outVerts[0], outVerts[i], outVerts[i+1])
+		}
+
+	}
+
+	nodes := []INode{}
+	added := map[INode]bool{}
+
+	// Models that can be projected onto, by Mesh.
+	projected := map[*Mesh][]*Model{}
+
+	for _, target := range targets {
+		for _, node := range append([]INode{target}, target.ChildrenRecursive()...) {
+
+			if added[node] {
+				continue
+			}
+
+			added[node] = true
+			nodes = append(nodes, node)
+
+			if model, ok := node.(*Model); ok && model.Mesh != nil && !model.Skinned && !decalModels[model] && model.visible {
+				projected[model.Mesh] = append(projected[model.Mesh], model)
+			}
+
+		}
+	}
+
+	var broadphaseAABB *BoundingAABB
+
+	for _, node := range nodes {
+
+		switch target := node.(type) {
+
+		case *Model:
+
+			if target.Mesh == nil || target.Skinned || decalModels[target] || !target.visible {
+				continue
+			}
+
+			if target.BoundingSphere.WorldPosition().Sub(position).Magnitude() > target.BoundingSphere.WorldRadius()+radius {
+				continue
+			}
+
+			transform := target.Transform()
+			normalMatrix := transform.Inverted().Transposed()
+			mesh := target.Mesh
+
+			for _, tri := range mesh.Triangles {
+				ids := tri.VertexIndices()
+				addTriangle(
+					transform.MultVec(mesh.VertexPositions[ids[0]]),
+					transform.MultVec(mesh.VertexPositions[ids[1]]),
+					transform.MultVec(mesh.VertexPositions[ids[2]]),
+					normalMatrix.MultVec(mesh.VertexNormals[ids[0]]),
+					normalMatrix.MultVec(mesh.VertexNormals[ids[1]]),
+					normalMatrix.MultVec(mesh.VertexNormals[ids[2]]),
+				)
+			}
+
+		case *BoundingTriangles:
+
+			// BoundingTriangles are usually made from the Mesh of the Model they're parented to (or alongside), in which case the
+			// triangles have already been projected through that Model.
+			if sharesMeshWithModel(target, projected[target.Mesh]) {
+				continue
+			}
+
+			if broadphaseAABB == nil {
+				corners := []vector.Vector{}
+				for _, corner := range [][]float64{{1, 1, 1}, {1, -1, 1}, {-1, 1, 1}, {-1, -1, 1}, {1, 1, -1}, {1, -1, -1}, {-1, 1, -1}, {-1, -1, -1}} {
+					corners = append(corners, projector.MultVec(corner))
+				}
+				dim := NewDimensionsFromPoints(corners...)
+				broadphaseAABB = NewBoundingAABB("decal projector aabb", dim.Width(), dim.Height(), dim.Depth())
+				broadphaseAABB.SetLocalPositionVec(dim.Center())
+			}
+
+			transform := target.Transform()
+			normalMatrix := transform.Inverted().Transposed()
+			mesh := target.Mesh
+
+			for triID := range target.Broadphase.GetTrianglesFromBounding(broadphaseAABB) {
+				ids := mesh.Triangles[triID].VertexIndices()
+				addTriangle(
+					transform.MultVec(mesh.VertexPositions[ids[0]]),
+					transform.MultVec(mesh.VertexPositions[ids[1]]),
+					transform.MultVec(mesh.VertexPositions[ids[2]]),
+					normalMatrix.MultVec(mesh.VertexNormals[ids[0]]),
+					normalMatrix.MultVec(mesh.VertexNormals[ids[1]]),
+					normalMatrix.MultVec(mesh.VertexNormals[ids[2]]),
+				)
+			}
+
+		}
+
+	}
+
+	if len(verts) == 0 {
+		return nil
+	}
+
+	mesh := NewMesh("Decal")
+	mesh.AddMeshPart(ds.Material).AddTriangles(verts...)
+	mesh.UpdateBounds()
+
+	return mesh
+
+}
+
+// sharesMeshWithModel returns true if any of the given Models (which use the BoundingTriangles' Mesh) is the BoundingTriangles' parent or sibling.
+func sharesMeshWithModel(bt *BoundingTriangles, models []*Model) bool {
+
+	parent := bt.Parent()
+
+	for _, model := range models {
+		if model == parent || (parent != nil && model.Parent() == parent) {
+			return true
+		}
+	}
+
+	return false
+
+}
+
+// clipDecalPolygon clips the convex polygon given against the plane where the given axis equals side (either 1 or -1), keeping the part of
+// the polygon within the projector box, using the Sutherland-Hodgman algorithm.
+func clipDecalPolygon(polygon []decalVertex, axis int, side float64) []decalVertex {
+
+	out := make([]decalVertex, 0, len(polygon)+1)
+
+	// The signed distance of each vertex from the plane; positive values are inside the box.
+	distance := func(dv decalVertex) float64 {
+		return 1 - dv.position[axis]*side
+	}
+
+	for i, current := range polygon {
+
+		next := polygon[(i+1)%len(polygon)]
+		currentDist := distance(current)
+		nextDist := distance(next)
+
+		if currentDist >= 0 {
+			out = append(out, current)
+		}
+
+		if (currentDist >= 0) != (nextDist >= 0) {
+			out = append(out, current.lerp(next, currentDist/(currentDist-nextDist)))
+		}
+
+	}
+
+	return out
+
+}
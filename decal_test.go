@@ -0,0 +1,178 @@
+package tetra3d
+
+import (
+	"math"
+	"testing"
+
+	"github.com/kvartborg/vector"
+)
+
+// newDecalTarget creates a Model with a single triangle on the XY plane (at Z = 0), facing +Z.
+func newDecalTarget(p0, p1, p2 vector.Vector) *Model {
+	mesh := NewMesh("Target")
+	mesh.AddMeshPart(NewMaterial("Target")).AddTriangles(
+		NewVertex(p0[0], p0[1], 0, 0, 0),
+		NewVertex(p1[0], p1[1], 0, 0, 0),
+		NewVertex(p2[0], p2[1], 0, 0, 0),
+	)
+	mesh.UpdateBounds()
+	mesh.AutoNormal()
+	return NewModel(mesh, "Target")
+}
+
+func TestDecalSystemProjectBox(t *testing.T) {
+
+	// The projector is the box from -1 to 1 on each axis, projecting down -Z onto the XY plane, so X and Y map directly to U and V.
+	projector := NewMatrix4()
+
+	tests := []struct {
+		name      string
+		triangle  []vector.Vector
+		uvs       []vector.Vector // The UVs of the clipped polygon's corners
+		triangles int
+	}{
+		{
+			"covering the whole box",
+			[]vector.Vector{{-10, -10}, {10, -10}, {0, 10}},
+			[]vector.Vector{{0, 0}, {1, 0}, {1, 1}, {0, 1}},
+			2,
+		},
+		{
+			"clipped to a hexagon",
+			[]vector.Vector{{-1.5, -0.5}, {1.5, -0.5}, {0, 2.5}},
+			[]vector.Vector{{0, 0.25}, {1, 0.25}, {1, 0.75}, {0.875, 1}, {0.125, 1}, {0, 0.75}},
+			4,
+		},
+		{
+			"within the box",
+			[]vector.Vector{{-0.5, -0.5}, {0.5, -0.5}, {0, 0.5}},
+			[]vector.Vector{{0.25, 0.25}, {0.75, 0.25}, {0.5, 0.75}},
+			1,
+		},
+	}
+
+	for _, test := range tests {
+
+		ds := NewDecalSystem(nil, NewMaterial("Decal"), 0)
+		target := newDecalTarget(test.triangle[0], test.triangle[1], test.triangle[2])
+
+		decal := ds.ProjectBox(projector, target)
+		if decal == nil {
+			t.Errorf("%s: expected a Decal", test.name)
+			continue
+		}
+
+		mesh := decal.Model.Mesh
+
+		if len(mesh.VertexPositions) != test.triangles*3 {
+			t.Errorf("%s: expected %d vertices, got %d", test.name, test.triangles*3, len(mesh.VertexPositions))
+			continue
+		}
+
+		// Every vertex is a corner of the clipped polygon, and every corner is used.
+		used := make([]bool, len(test.uvs))
+
+		for index, uv := range mesh.VertexUVs {
+
+			corner := -1
+			for c, expected := range test.uvs {
+				if math.Abs(uv[0]-expected[0]) < 1e-6 && math.Abs(uv[1]-expected[1]) < 1e-6 {
+					corner = c
+				}
+			}
+
+			if corner < 0 {
+				t.Errorf("%s: vertex %d has UV %v, which isn't a corner of the clipped polygon", test.name, index, uv)
+				continue
+			}
+
+			used[corner] = true
+
+			// The Decal is pushed off of the surface towards the projector by the DepthBias.
+			position := vector.Vector{uv[0]*2 - 1, uv[1]*2 - 1, ds.DepthBias}
+			if !vectorsEqual(mesh.VertexPositions[index], position) {
+				t.Errorf("%s: vertex %d is at %v, expected %v", test.name, index, mesh.VertexPositions[index], position)
+			}
+
+		}
+
+		for c, isUsed := range used {
+			if !isUsed {
+				t.Errorf("%s: the clipped polygon's corner at UV %v is missing", test.name, test.uvs[c])
+			}
+		}
+
+	}
+
+	ds := NewDecalSystem(nil, NewMaterial("Decal"), 0)
+
+	// Outside of the box, nothing is hit.
+	outside := newDecalTarget(vector.Vector{2, 2}, vector.Vector{3, 2}, vector.Vector{2, 3})
+	outside.Transform()
+	if ds.ProjectBox(projector, outside) != nil {
+		t.Errorf("a triangle outside of the projector box shouldn't be projected onto")
+	}
+
+	// Facing away from the projector, nothing is hit either.
+	away := newDecalTarget(vector.Vector{-10, -10}, vector.Vector{0, 10}, vector.Vector{10, -10})
+	if ds.ProjectBox(projector, away) != nil {
+		t.Errorf("a triangle facing away from the projector shouldn't be projected onto")
+	}
+
+	if len(ds.Decals) != 0 {
+		t.Errorf("Decals that hit nothing shouldn't be added to the DecalSystem")
+	}
+
+}
+
+func TestDecalSystemMaxDecals(t *testing.T) {
+
+	scene := NewScene("Decals")
+	target := newDecalTarget(vector.Vector{-10, -10}, vector.Vector{10, -10}, vector.Vector{0, 10})
+	scene.Root.AddChildren(target)
+
+	ds := NewDecalSystem(scene.Root, NewMaterial("Decal"), 3)
+
+	decals := []*Decal{}
+
+	for i := 0; i < 5; i++ {
+
+		decal := ds.Project(vector.Vector{float64(i) - 2, 0, 0}, vector.Vector{0, 0, 1}, 1, 1, 1, target)
+		if decal == nil {
+			t.Fatalf("expected Decal %d to hit the target", i)
+		}
+		decals = append(decals, decal)
+
+		if expected := math.Min(float64(i+1), 3); len(ds.Decals) != int(expected) {
+			t.Fatalf("after projecting %d Decals, expected %d to be alive, got %d", i+1, int(expected), len(ds.Decals))
+		}
+
+	}
+
+	// The two oldest Decals were removed, oldest first, leaving the newest three in order.
+	for i, decal := range ds.Decals {
+		if decal != decals[i+2] {
+			t.Errorf("expected Decal %d to be alive at index %d", i+2, i)
+		}
+		if decal.Model.Parent() != scene.Root {
+			t.Errorf("Decal %d's Model should be parented to the DecalSystem's Root", i+2)
+		}
+	}
+
+	for _, decal := range decals[:2] {
+		if decal.Model.Parent() != nil {
+			t.Errorf("a removed Decal's Model should be unparented")
+		}
+	}
+
+	// Projecting onto the root, existing Decals aren't projected onto themselves.
+	decal := ds.Project(vector.Vector{0, 0, 0}, vector.Vector{0, 0, 1}, 1, 1, 1, scene.Root)
+	if decal == nil || len(decal.Model.Mesh.VertexPositions) != 6 {
+		t.Errorf("expected a Decal projected onto the root to only hit the target's triangle")
+	}
+
+	if len(ds.Decals) != 3 || ds.Decals[2] != decal || ds.Decals[0] != decals[3] {
+		t.Errorf("expected the oldest Decal to be removed once the newest was projected")
+	}
+
+}
@@ -22,6 +22,23 @@ func fastVectorSub(a, b vector.Vector) vector.Vector {
 
 }
 
+// fastVectorSubUnit returns the normalized difference between the two vectors. Unlike fastVectorSub, it doesn't use a shared
+// standin vector, so it's safe to use when processing vertices across multiple goroutines.
+func fastVectorSubUnit(a, b vector.Vector) [3]float64 {
+
+	out := [3]float64{a[0] - b[0], a[1] - b[1], a[2] - b[2]}
+
+	l := math.Sqrt(out[0]*out[0] + out[1]*out[1] + out[2]*out[2])
+	if l >= 1e-8 {
+		out[0] /= l
+		out[1] /= l
+		out[2] /= l
+	}
+
+	return out
+
+}
+
 func fastVectorDistanceSquared(a, b vector.Vector) float64 {
 	subX := a[0] - b[0]
 	subY := a[1] - b[1]
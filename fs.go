@@ -0,0 +1,221 @@
+package tetra3d
+
+import (
+	"image"
+	"io/fs"
+	"log"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+
+	_ "image/jpeg"
+	_ "image/png"
+)
+
+// osDirFS is a filesystem that opens files from the operating system's filesystem relative to a directory; it's used to load the files
+// referenced by model files loaded with LoadGLTFFile(), LoadDAEFile(), and LoadOBJFile(). Unlike os.DirFS(), paths may lead outside of
+// the directory (like "../textures/wall.png") or be absolute, as model files often reference files in neighbouring directories.
+type osDirFS string
+
+func (dir osDirFS) Open(name string) (fs.File, error) {
+	return os.Open(dir.join(name))
+}
+
+// Sub returns the filesystem for the given subdirectory; implementing fs.SubFS keeps fs.Sub() from wrapping the filesystem in one
+// that rejects paths outside of the subdirectory.
+func (dir osDirFS) Sub(name string) (fs.FS, error) {
+	return osDirFS(dir.join(name)), nil
+}
+
+func (dir osDirFS) join(name string) string {
+	name = filepath.FromSlash(name)
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(string(dir), name)
+}
+
+// LoadDAEFileFS takes a filesystem (like an embed.FS) and the name (path) of a DAE file in it, and returns a *Library populated with the
+// .dae file's objects and meshes (see LoadDAEData()). Textures referenced by the file's materials are loaded from the filesystem,
+// relative to the DAE file.
+func LoadDAEFileFS(fsys fs.FS, name string, options *DaeLoadOptions) (*Library, error) {
+
+	fileData, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+
+	library, err := LoadDAEData(fileData, options)
+	if err != nil {
+		return nil, err
+	}
+
+	if options == nil {
+		options = DefaultDaeLoadOptions()
+	}
+
+	if err := loadTexturePaths(library, fsys, path.Dir(name), options.KeepTextureSources, nil); err != nil {
+		return nil, err
+	}
+
+	return library, nil
+
+}
+
+// LoadOBJFileFS loads an OBJ file with the given name (path) in the provided filesystem (like an embed.FS), using the given OBJLoadOptions
+// (see LoadOBJData()). If the options don't specify a MaterialLibraryResolver or a TextureResolver, material libraries and textures are
+// loaded from the filesystem, relative to the OBJ file.
+func LoadOBJFileFS(fsys fs.FS, name string, options *OBJLoadOptions) (*Library, error) {
+
+	fileData, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if options == nil {
+		options = DefaultOBJLoadOptions()
+	}
+
+	dir := path.Dir(name)
+	resolvedOptions := *options
+
+	if resolvedOptions.MaterialLibraryResolver == nil {
+		resolvedOptions.MaterialLibraryResolver = func(mtlPath string) ([]byte, error) {
+			return fs.ReadFile(fsys, resolveFSPath(dir, mtlPath))
+		}
+	}
+
+	if resolvedOptions.TextureResolver == nil {
+		textures := map[string]*ebiten.Image{}
+		resolvedOptions.TextureResolver = func(texturePath string) *ebiten.Image {
+			if texture, exists := textures[texturePath]; exists {
+				return texture
+			}
+			img, err := loadFSImage(fsys, dir, texturePath)
+			if err != nil {
+				log.Println("Warning: couldn't load texture " + texturePath + ": " + err.Error())
+				return nil
+			}
+			textures[texturePath] = newTextureFromImage(img, options.KeepTextureSources)
+			return textures[texturePath]
+		}
+	}
+
+	return LoadOBJData(fileData, &resolvedOptions)
+
+}
+
+// loadTexturePaths loads the textures referenced by the texture paths of the Library's Materials and Worlds from the filesystem
+// (relative to dir), and assigns them to any Materials and Worlds that don't already have textures. Textures that can't be loaded are
+// skipped with a warning. If keepSources is true, the loaded images are registered as the textures' sources (see RegisterTextureSource()).
+// If async is not nil, the textures are left for it to create on the main thread.
+func loadTexturePaths(library *Library, fsys fs.FS, dir string, keepSources bool, async *AsyncLoad) error {
+
+	type textureTarget struct {
+		path   string
+		target **ebiten.Image
+	}
+
+	targets := []textureTarget{}
+
+	for _, name := range sortedKeys(library.Materials) {
+		mat := library.Materials[name]
+		targets = append(targets,
+			textureTarget{mat.TexturePath, &mat.Texture},
+			textureTarget{mat.NormalTexturePath, &mat.NormalTexture},
+			textureTarget{mat.SpecularTexturePath, &mat.SpecularTexture},
+			textureTarget{mat.EmissiveTexturePath, &mat.EmissiveTexture},
+		)
+	}
+
+	for _, name := range sortedKeys(library.Worlds) {
+		world := library.Worlds[name]
+		targets = append(targets, textureTarget{world.SkyTexturePath, &world.SkyTexture})
+	}
+
+	textures := map[string]*ebiten.Image{}
+	pendingTextures := map[string]int{}
+	failed := map[string]bool{}
+
+	for i, t := range targets {
+
+		if t.path == "" || *t.target != nil || failed[t.path] {
+			continue
+		}
+
+		if err := async.cancelled(); err != nil {
+			return err
+		}
+
+		async.setProgress(LoadStageImages, i, len(targets))
+
+		if texture, exists := textures[t.path]; exists {
+			*t.target = texture
+			continue
+		}
+
+		if index, exists := pendingTextures[t.path]; exists {
+			async.pendingTextures[index].targets = append(async.pendingTextures[index].targets, t.target)
+			continue
+		}
+
+		img, err := loadFSImage(fsys, dir, t.path)
+		if err != nil {
+			log.Println("Warning: couldn't load texture " + t.path + ": " + err.Error())
+			failed[t.path] = true
+			continue
+		}
+
+		// When loading asynchronously, textures are created on the main thread afterwards
+		if async != nil {
+			index := async.addPendingTexture(img)
+			async.pendingTextures[index].targets = append(async.pendingTextures[index].targets, t.target)
+			pendingTextures[t.path] = index
+		} else {
+			textures[t.path] = newTextureFromImage(img, keepSources)
+			*t.target = textures[t.path]
+		}
+
+	}
+
+	return nil
+
+}
+
+// loadFSImage loads and decodes the image at the given path (relative to dir) from the filesystem.
+func loadFSImage(fsys fs.FS, dir, imagePath string) (image.Image, error) {
+
+	file, err := fsys.Open(resolveFSPath(dir, imagePath))
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	return img, err
+
+}
+
+// resolveFSPath resolves the given path (as exported from Blender or written in a model file, so possibly URL-encoded, Blender-relative,
+// or using backslashes) relative to dir, returning a path that can be used with fs.FS.
+func resolveFSPath(dir, filePath string) string {
+
+	if unescaped, err := url.PathUnescape(filePath); err == nil {
+		filePath = unescaped
+	}
+
+	filePath = strings.TrimPrefix(filePath, "//") // Blender relative paths start with double-slashes
+	filePath = strings.ReplaceAll(filePath, "\\", "/")
+
+	if path.IsAbs(filePath) {
+		return filePath // This will be rejected as an invalid path by filesystems other than the one used when loading from a file path
+	}
+
+	return path.Join(dir, filePath)
+
+}
@@ -4,9 +4,12 @@ import (
 	"bytes"
 	"encoding/json"
 	"image"
+	"io/fs"
 	"log"
 	"math"
-	"os"
+	"path"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -33,6 +36,10 @@ type GLTFLoadOptions struct {
 	// You could then simply load the assets library first and then code the DependentLibraryResolver function to take the assets library, or code the
 	// function to use the path to load the library on demand. You could then store the loaded result as necessary if multiple levels use this assets Library.
 	DependentLibraryResolver func(blendPath string) *Library
+	// KeepTextureSources indicates whether the images that loaded textures are created from should be registered as the textures'
+	// sources (see RegisterTextureSource()), so that they can be rendered by Camera.RenderToImage() or packed by ExportGLTFData() before
+	// the game is running. Defaults to false, as the source images are kept in memory until unregistered (see Library.UnregisterTextureSources()).
+	KeepTextureSources bool
 }
 
 // DefaultGLTFLoadOptions creates an instance of GLTFLoadOptions with some sensible defaults.
@@ -49,16 +56,28 @@ func DefaultGLTFLoadOptions() *GLTFLoadOptions {
 // LoadGLTFFile loads a .gltf or .glb file from the filepath given, using a provided GLTFLoadOptions struct to alter how the file is loaded.
 // Passing nil for loadOptions will load the file using default load options. Unlike with DAE files, Animations (including armature-based
 // animations) and Cameras (assuming they are exported in the GLTF file) will be parsed properly.
+// External buffers and textures referenced by the file are loaded relative to the file, including from other directories (like
+// "../textures/wall.png"; see LoadGLTFFileFS()).
 // LoadGLTFFile will return a Library, and an error if the process fails.
 func LoadGLTFFile(path string, loadOptions *GLTFLoadOptions) (*Library, error) {
+	return LoadGLTFFileFS(osDirFS(filepath.Dir(path)), filepath.Base(path), loadOptions)
+}
+
+// LoadGLTFFileFS loads a .gltf or .glb file with the given name (path) in the provided filesystem (like an embed.FS), using a provided GLTFLoadOptions
+// struct to alter how the file is loaded. Passing nil for loadOptions will load the file using default load options.
+// External .bin buffers referenced by the file are loaded relative to the file. If the textures weren't packed into the file, the textures
+// referenced by Materials' texture paths (and Worlds' sky texture paths) are also loaded relative to the file and assigned automatically;
+// textures that can't be found in the filesystem are skipped with a warning.
+// LoadGLTFFileFS will return a Library, and an error if the process fails.
+func LoadGLTFFileFS(fsys fs.FS, name string, loadOptions *GLTFLoadOptions) (*Library, error) {
 
-	fileData, err := os.ReadFile(path)
+	fileData, err := fs.ReadFile(fsys, name)
 
 	if err != nil {
 		return nil, err
 	}
 
-	return LoadGLTFData(fileData, loadOptions)
+	return loadGLTFData(fileData, fsys, path.Dir(name), loadOptions, nil)
 
 }
 
@@ -67,8 +86,24 @@ func LoadGLTFFile(path string, loadOptions *GLTFLoadOptions) (*Library, error) {
 // animations) and Cameras (assuming they are exported in the GLTF file) will be parsed properly.
 // LoadGLTFFile will return a Library, and an error if the process fails.
 func LoadGLTFData(data []byte, gltfLoadOptions *GLTFLoadOptions) (*Library, error) {
+	return loadGLTFData(data, nil, "", gltfLoadOptions, nil)
+}
+
+// loadGLTFData loads GLTF data; if fsys is not nil, external buffers and textures are loaded from it, relative to dir. If async is not nil,
+// loading progress is reported to it, and textures are left for it to create on the main thread.
+func loadGLTFData(data []byte, fsys fs.FS, dir string, gltfLoadOptions *GLTFLoadOptions, async *AsyncLoad) (*Library, error) {
+
+	var bufferFS fs.FS
 
-	decoder := gltf.NewDecoder(bytes.NewReader(data))
+	if fsys != nil {
+		sub, err := fs.Sub(fsys, dir)
+		if err != nil {
+			return nil, err
+		}
+		bufferFS = sub
+	}
+
+	decoder := gltf.NewDecoderFS(bytes.NewReader(data), bufferFS)
 
 	doc := gltf.NewDocument()
 
@@ -78,10 +113,16 @@ func LoadGLTFData(data []byte, gltfLoadOptions *GLTFLoadOptions) (*Library, erro
 		return nil, err
 	}
 
+	async.setProgress(LoadStageBuffers, len(doc.Buffers), len(doc.Buffers))
+
 	if gltfLoadOptions == nil {
 		gltfLoadOptions = DefaultGLTFLoadOptions()
 	}
 
+	if async != nil {
+		async.keepSources = gltfLoadOptions.KeepTextureSources
+	}
+
 	library := NewLibrary()
 
 	var images []*ebiten.Image
@@ -144,8 +185,15 @@ func LoadGLTFData(data []byte, gltfLoadOptions *GLTFLoadOptions) (*Library, erro
 
 	if exportedTextures {
 		images = make([]*ebiten.Image, len(doc.Images))
+		async.startPendingTextures(len(doc.Images))
 		for i, gltfImage := range doc.Images {
 
+			if err := async.cancelled(); err != nil {
+				return nil, err
+			}
+
+			async.setProgress(LoadStageImages, i, len(doc.Images))
+
 			imageData, err := modeler.ReadBufferView(doc, doc.BufferViews[*gltfImage.BufferView])
 			if err != nil {
 				return nil, err
@@ -158,12 +206,26 @@ func LoadGLTFData(data []byte, gltfLoadOptions *GLTFLoadOptions) (*Library, erro
 				return nil, err
 			}
 
-			images[i] = ebiten.NewImageFromImage(img)
+			// When loading asynchronously, textures are created on the main thread afterwards
+			if async != nil {
+				async.pendingTextures[i].source = img
+			} else {
+				images[i] = newTextureFromImage(img, gltfLoadOptions.KeepTextureSources)
+			}
 
 		}
 
 	}
 
+	// setTexture sets the target to the texture created from the packed image with the given index.
+	setTexture := func(target **ebiten.Image, imageIndex uint32) {
+		if async != nil {
+			async.pendingTextures[imageIndex].targets = append(async.pendingTextures[imageIndex].targets, target)
+		} else {
+			*target = images[imageIndex]
+		}
+	}
+
 	for _, gltfMat := range doc.Materials {
 
 		newMat := NewMaterial(gltfMat.Name)
@@ -173,12 +235,42 @@ func LoadGLTFData(data []byte, gltfLoadOptions *GLTFLoadOptions) (*Library, erro
 
 		if texture := gltfMat.PBRMetallicRoughness.BaseColorTexture; texture != nil {
 			if exportedTextures {
-				newMat.Texture = images[*doc.Textures[texture.Index].Source]
+				setTexture(&newMat.Texture, *doc.Textures[texture.Index].Source)
 			} else {
 				newMat.TexturePath = doc.Images[*doc.Textures[texture.Index].Source].URI
 			}
 		}
 
+		if texture := gltfMat.NormalTexture; texture != nil && texture.Index != nil {
+			if exportedTextures {
+				setTexture(&newMat.NormalTexture, *doc.Textures[*texture.Index].Source)
+			} else {
+				newMat.NormalTexturePath = doc.Images[*doc.Textures[*texture.Index].Source].URI
+			}
+		}
+
+		if texture := gltfMat.PBRMetallicRoughness.MetallicRoughnessTexture; texture != nil {
+			if exportedTextures {
+				setTexture(&newMat.SpecularTexture, *doc.Textures[texture.Index].Source)
+			} else {
+				newMat.SpecularTexturePath = doc.Images[*doc.Textures[texture.Index].Source].URI
+			}
+		}
+
+		newMat.Roughness = gltfMat.PBRMetallicRoughness.RoughnessFactorOrDefault()
+		newMat.Metallic = gltfMat.PBRMetallicRoughness.MetallicFactorOrDefault()
+
+		if texture := gltfMat.EmissiveTexture; texture != nil {
+			if exportedTextures {
+				setTexture(&newMat.EmissiveTexture, *doc.Textures[texture.Index].Source)
+			} else {
+				newMat.EmissiveTexturePath = doc.Images[*doc.Textures[texture.Index].Source].URI
+			}
+		}
+
+		newMat.EmissiveColor.Set(gltfMat.EmissiveFactor[0], gltfMat.EmissiveFactor[1], gltfMat.EmissiveFactor[2], 1)
+		newMat.EmissiveColor.ConvertTosRGB()
+
 		if gltfMat.Extras != nil {
 			if dataMap, isMap := gltfMat.Extras.(map[string]interface{}); isMap {
 
@@ -194,6 +286,10 @@ func LoadGLTFData(data []byte, gltfLoadOptions *GLTFLoadOptions) (*Library, erro
 					newMat.Shadeless = s.(float64) > 0.5
 				}
 
+				if s, exists := dataMap["t3dMaterialFogless__"]; exists {
+					newMat.Fogless = s.(float64) > 0.5
+				}
+
 				if s, exists := dataMap["t3dCompositeMode__"]; exists {
 					switch int(s.(float64)) {
 					case 0:
@@ -221,6 +317,16 @@ func LoadGLTFData(data []byte, gltfLoadOptions *GLTFLoadOptions) (*Library, erro
 
 				}
 
+				if s, exists := dataMap["t3dLightingMode__"]; exists {
+					switch int(s.(float64)) {
+					case 0:
+						newMat.LightingMode = LightingModeVertex
+					case 1:
+						newMat.LightingMode = LightingModePixel
+					}
+
+				}
+
 				for tagName, data := range dataMap {
 					if !strings.HasPrefix(tagName, "t3d") || !strings.HasSuffix(tagName, "__") {
 						newMat.Tags.Set(tagName, data)
@@ -256,7 +362,13 @@ func LoadGLTFData(data []byte, gltfLoadOptions *GLTFLoadOptions) (*Library, erro
 
 	}
 
-	for _, mesh := range doc.Meshes {
+	for meshIndex, mesh := range doc.Meshes {
+
+		if err := async.cancelled(); err != nil {
+			return nil, err
+		}
+
+		async.setProgress(LoadStageMeshes, meshIndex, len(doc.Meshes))
 
 		// If t3dGrid__ is set on a mesh, then it can be skipped for loading
 		if mesh.Extras != nil {
@@ -267,6 +379,15 @@ func LoadGLTFData(data []byte, gltfLoadOptions *GLTFLoadOptions) (*Library, erro
 					continue
 				}
 
+				// Meshes of Rooms and Portals only define their shapes, so they can be skipped as well
+				if _, exists := dataMap["t3dRoom__"]; exists {
+					continue
+				}
+
+				if _, exists := dataMap["t3dPortal__"]; exists {
+					continue
+				}
+
 			}
 
 		}
@@ -448,7 +569,14 @@ func LoadGLTFData(data []byte, gltfLoadOptions *GLTFLoadOptions) (*Library, erro
 
 	}
 
-	for _, gltfAnim := range doc.Animations {
+	for animIndex, gltfAnim := range doc.Animations {
+
+		if err := async.cancelled(); err != nil {
+			return nil, err
+		}
+
+		async.setProgress(LoadStageAnimations, animIndex, len(doc.Animations))
+
 		anim := NewAnimation(gltfAnim.Name)
 		anim.library = library
 		library.Animations[gltfAnim.Name] = anim
@@ -605,7 +733,13 @@ func LoadGLTFData(data []byte, gltfLoadOptions *GLTFLoadOptions) (*Library, erro
 
 	}
 
-	for _, node := range doc.Nodes {
+	for nodeIndex, node := range doc.Nodes {
+
+		if err := async.cancelled(); err != nil {
+			return nil, err
+		}
+
+		async.setProgress(LoadStageScenes, nodeIndex, len(doc.Nodes))
 
 		var obj INode
 
@@ -621,7 +755,13 @@ func LoadGLTFData(data []byte, gltfLoadOptions *GLTFLoadOptions) (*Library, erro
 
 			gltfCam := doc.Cameras[*node.Camera]
 
-			newCam := NewCamera(gltfLoadOptions.CameraWidth, gltfLoadOptions.CameraHeight)
+			// When loading asynchronously, Cameras' shaders and textures are created on the main thread afterwards
+			var newCam *Camera
+			if async != nil {
+				newCam = newCamera(gltfLoadOptions.CameraWidth, gltfLoadOptions.CameraHeight)
+			} else {
+				newCam = NewCamera(gltfLoadOptions.CameraWidth, gltfLoadOptions.CameraHeight)
+			}
 			newCam.name = node.Name
 			newCam.RenderDepth = gltfLoadOptions.CameraDepth
 
@@ -652,6 +792,16 @@ func LoadGLTFData(data []byte, gltfLoadOptions *GLTFLoadOptions) (*Library, erro
 					pointLight.Distance = float64(*lightData.Range)
 				}
 				obj = pointLight
+			} else if lightData.Type == lightspuntual.TypeSpot {
+				spotLight := NewSpotLight(node.Name, lightData.Color[0], lightData.Color[1], lightData.Color[2], *lightData.Intensity/1000)
+				if !math.IsInf(float64(*lightData.Range), 0) {
+					spotLight.Distance = float64(*lightData.Range)
+				}
+				if lightData.Spot != nil {
+					spotLight.InnerConeAngle = float64(lightData.Spot.InnerConeAngle)
+					spotLight.OuterConeAngle = float64(lightData.Spot.OuterConeAngleOrDefault())
+				}
+				obj = spotLight
 			} else {
 				// Any unsupported light type just gets turned into an ambient light
 				pointLight := NewAmbientLight(node.Name, lightData.Color[0], lightData.Color[1], lightData.Color[2], *lightData.Intensity/1000)
@@ -722,6 +872,34 @@ func LoadGLTFData(data []byte, gltfLoadOptions *GLTFLoadOptions) (*Library, erro
 
 			}
 
+		} else if node.Extras != nil && nodeHasProp(node, "t3dRoomBounds__") {
+
+			extraMap := node.Extras.(map[string]interface{})
+
+			bounds := []float64{}
+			for _, v := range extraMap["t3dRoomBounds__"].([]interface{}) {
+				bounds = append(bounds, v.(float64))
+			}
+
+			dimensions := NewDimensionsFromPoints(
+				vector.Vector{bounds[0], bounds[2], -bounds[1]},
+				vector.Vector{bounds[3], bounds[5], -bounds[4]},
+			)
+
+			obj = NewRoom(node.Name, dimensions)
+
+		} else if node.Extras != nil && nodeHasProp(node, "t3dPortalPoints__") {
+
+			points := []vector.Vector{}
+			extraMap := node.Extras.(map[string]interface{})
+
+			for _, p := range extraMap["t3dPortalPoints__"].([]interface{}) {
+				pointData := p.([]interface{})
+				points = append(points, vector.Vector{pointData[0].(float64), pointData[2].(float64), -pointData[1].(float64)})
+			}
+
+			obj = NewPortal(node.Name, points...)
+
 		} else {
 			obj = NewNode(node.Name)
 		}
@@ -925,8 +1103,6 @@ func LoadGLTFData(data []byte, gltfLoadOptions *GLTFLoadOptions) (*Library, erro
 
 	}
 
-	allBones := []*Node{}
-
 	// We do this again here so we can be sure that all of the nodes can be created first
 	for i, node := range doc.Nodes {
 
@@ -956,7 +1132,6 @@ func LoadGLTFData(data []byte, gltfLoadOptions *GLTFLoadOptions) (*Library, erro
 				// This is incorrect, but it gives us a link to any bone in the armature to establish
 				// the true root after parenting is set below
 				model.SkinRoot = bone
-				allBones = append(allBones, bone)
 				localBones = append(localBones, bone)
 			}
 
@@ -981,7 +1156,7 @@ func LoadGLTFData(data []byte, gltfLoadOptions *GLTFLoadOptions) (*Library, erro
 				model.bones = append(model.bones, []*Node{})
 
 				for _, boneID := range boneIndices {
-					model.bones[vertIndex] = append(model.bones[vertIndex], allBones[boneID])
+					model.bones[vertIndex] = append(model.bones[vertIndex], localBones[boneID])
 				}
 
 				// model.Mesh.VertexBones[i] = append(model.Mesh.VertexBones[i], )
@@ -1143,6 +1318,97 @@ func LoadGLTFData(data []byte, gltfLoadOptions *GLTFLoadOptions) (*Library, erro
 
 	}
 
+	// Set up LOD groups for Models named according to the "_LOD<number>" naming convention (e.g. "Tree_LOD0", "Tree_LOD1", "Tree_LOD2").
+	// The Meshes of sibling Models sharing a name are added as levels to the most detailed (lowest numbered) Model, and the other Models
+	// are removed from the scene hierarchy.
+	type lodKey struct {
+		parent INode
+		name   string
+	}
+
+	lodModels := map[lodKey][]*Model{}
+	lodKeys := []lodKey{}
+	lodNumbers := map[*Model]int{}
+	lodRemoved := map[INode]bool{}
+
+	for _, n := range objects {
+
+		model, isModel := n.(*Model)
+		if !isModel || model.Mesh == nil {
+			continue
+		}
+
+		index := strings.LastIndex(model.name, "_LOD")
+		if index < 0 {
+			continue
+		}
+
+		number, err := strconv.Atoi(model.name[index+4:])
+		if err != nil {
+			continue
+		}
+
+		key := lodKey{model.parent, model.name[:index]}
+		if _, exists := lodModels[key]; !exists {
+			lodKeys = append(lodKeys, key)
+		}
+		lodModels[key] = append(lodModels[key], model)
+		lodNumbers[model] = number
+
+	}
+
+	for _, key := range lodKeys {
+
+		models := lodModels[key]
+
+		if len(models) < 2 {
+			continue
+		}
+
+		sort.SliceStable(models, func(i, j int) bool { return lodNumbers[models[i]] < lodNumbers[models[j]] })
+
+		base := models[0]
+
+		baseData := map[string]interface{}{}
+		if dataMap, isMap := objToNode[base].Extras.(map[string]interface{}); isMap {
+			baseData = dataMap
+		}
+
+		lod := NewLODGroup(getOrDefaultInt(baseData, "t3dLODMode__", LODModeDistance))
+		lod.Hysteresis = getOrDefaultFloat(baseData, "t3dLODHysteresis__", 0)
+
+		for i, model := range models {
+
+			data := map[string]interface{}{}
+			if dataMap, isMap := objToNode[model].Extras.(map[string]interface{}); isMap {
+				data = dataMap
+			}
+
+			// Thresholds that aren't set default to 10 units of distance per level, or to halving the screen size per level.
+			threshold := getOrDefaultFloat(data, "t3dLODThreshold__", 0)
+			if threshold <= 0 {
+				if lod.Mode == LODModeScreenSize {
+					threshold = math.Pow(0.5, float64(i))
+				} else {
+					threshold = float64(i) * 10
+				}
+			}
+
+			lod.AddLevel(model.Mesh, threshold)
+
+			if i > 0 {
+				if model.parent != nil {
+					model.parent.RemoveChildren(model)
+				}
+				lodRemoved[model] = true
+			}
+
+		}
+
+		base.LOD = lod
+
+	}
+
 	for obj, node := range objToNode {
 
 		if node.Extras != nil {
@@ -1258,6 +1524,68 @@ func LoadGLTFData(data []byte, gltfLoadOptions *GLTFLoadOptions) (*Library, erro
 					world.FogRange[1] = float32(fogEnd)
 				}
 
+				if v, exists := props["fog curve"]; exists {
+					switch v.(string) {
+					case "LINEAR":
+						world.FogCurve = FogCurveLinear
+					case "EXPONENTIAL":
+						world.FogCurve = FogCurveExponential
+					case "EXPONENTIAL_SQUARED":
+						world.FogCurve = FogCurveExponentialSquared
+					}
+				}
+
+				if v, exists := props["fog density"]; exists {
+					world.FogDensity = float32(v.(float64))
+				}
+
+				if v, exists := props["fog height"]; exists {
+					world.FogHeight = float32(v.(float64))
+				}
+
+				if v, exists := props["fog height falloff"]; exists {
+					world.FogHeightFalloff = float32(v.(float64))
+				}
+
+				if v, exists := props["sky mode"]; exists {
+					switch v.(string) {
+					case "OFF":
+						world.SkyMode = SkyOff
+					case "GRADIENT":
+						world.SkyMode = SkyGradient
+					case "EQUIRECTANGULAR":
+						world.SkyMode = SkyEquirectangular
+					case "CUBEMAP":
+						world.SkyMode = SkyCubemap
+					}
+				}
+
+				skyColor := func(key string, color *Color) {
+					if v, exists := props[key]; exists {
+						wcc := v.([]interface{})
+						color.Set(float32(wcc[0].(float64)), float32(wcc[1].(float64)), float32(wcc[2].(float64)), 1)
+						color.ConvertTosRGB()
+					}
+				}
+
+				skyColor("sky top color", world.SkyTopColor)
+				skyColor("sky horizon color", world.SkyHorizonColor)
+				skyColor("sky bottom color", world.SkyBottomColor)
+				skyColor("sun color", world.SunColor)
+
+				if v, exists := props["sun direction"]; exists {
+					dir := v.([]interface{})
+					world.SunDirection = vector.Vector{dir[0].(float64), dir[2].(float64), -dir[1].(float64)}.Unit()
+				}
+
+				if v, exists := props["sun size"]; exists {
+					world.SunSize = v.(float64)
+				}
+
+				if v, exists := props["sky texture path"]; exists {
+					world.SkyTexturePath = v.(string)
+				}
+
 				library.Worlds[world.Name] = world
 
 			}
@@ -1273,7 +1601,9 @@ func LoadGLTFData(data []byte, gltfLoadOptions *GLTFLoadOptions) (*Library, erro
 		scene.library = library
 
 		for _, n := range s.Nodes {
-			scene.Root.AddChildren(objects[n])
+			if !lodRemoved[objects[n]] {
+				scene.Root.AddChildren(objects[n])
+			}
 		}
 
 		if s.Extras != nil {
@@ -1315,6 +1645,12 @@ func LoadGLTFData(data []byte, gltfLoadOptions *GLTFLoadOptions) (*Library, erro
 
 	library.ExportedScene = library.Scenes[*doc.Scene]
 
+	if fsys != nil && !exportedTextures {
+		if err := loadTexturePaths(library, fsys, dir, gltfLoadOptions.KeepTextureSources, async); err != nil {
+			return nil, err
+		}
+	}
+
 	return library, nil
 
 }
@@ -0,0 +1,273 @@
+package tetra3d
+
+import (
+	"context"
+	"image"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sync"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// The stages of loading a GLTF file asynchronously, as reported by AsyncLoad.Progress().
+const (
+	LoadStageBuffers    = iota // Reading the file and decoding its buffers
+	LoadStageImages            // Decoding the file's packed images
+	LoadStageMeshes            // Creating Meshes
+	LoadStageAnimations        // Creating Animations
+	LoadStageScenes            // Creating nodes and Scenes
+	LoadStageTextures          // Creating textures from the decoded images, and Cameras' shaders and textures, on the main thread, in AsyncLoad.Update()
+	LoadStageDone              // Loading is finished
+)
+
+// LoadProgress indicates how far along an asynchronous load is.
+type LoadProgress struct {
+	Stage   int // The current stage of loading (LoadStageBuffers, LoadStageImages, etc).
+	Current int // How many items have been loaded in the current stage.
+	Total   int // How many items there are to load in the current stage.
+}
+
+// Percentage returns the overall progress of the load, ranging from 0 to 1.
+func (progress LoadProgress) Percentage() float64 {
+	stageProgress := 1.0
+	if progress.Total > 0 {
+		stageProgress = float64(progress.Current) / float64(progress.Total)
+	}
+	return (float64(progress.Stage) + stageProgress) / (LoadStageDone + 1)
+}
+
+// pendingTexture is a decoded image waiting to be turned into a texture on the main thread, along with the texture fields it should be set to.
+type pendingTexture struct {
+	source  image.Image
+	targets []**ebiten.Image
+}
+
+// AsyncLoad represents a GLTF file being loaded asynchronously, created with LoadGLTFFileAsync(), LoadGLTFFileFSAsync(), or LoadGLTFDataAsync().
+// The file is parsed on a background goroutine, while textures and Cameras' GPU resources are created on the main thread by calling AsyncLoad.Update()
+// each frame (i.e. in your game's Update() function) until it returns true, after which the Library can be retrieved using
+// AsyncLoad.Result().
+type AsyncLoad struct {
+	// TexturesPerUpdate is how many textures (or Cameras) are created each time Update() is called, to spread texture creation across
+	// multiple frames. If TexturesPerUpdate is 0 or less, all textures are created in a single Update() call. Defaults to 4.
+	TexturesPerUpdate int
+
+	ctx             context.Context
+	mutex           sync.Mutex
+	progress        LoadProgress
+	pendingTextures []pendingTexture
+	pendingCameras  []*Camera
+	keepSources     bool
+	parsed          bool
+	done            bool
+	library         *Library
+	err             error
+}
+
+// LoadGLTFFileAsync begins loading a .gltf or .glb file from the filepath given on a background goroutine, using a provided GLTFLoadOptions
+// struct to alter how the file is loaded (see LoadGLTFFile()). Loading can be canceled using the provided Context.
+// LoadGLTFFileAsync returns an AsyncLoad, which should be updated each frame using AsyncLoad.Update() until it's finished.
+func LoadGLTFFileAsync(ctx context.Context, path string, loadOptions *GLTFLoadOptions) *AsyncLoad {
+	return LoadGLTFFileFSAsync(ctx, osDirFS(filepath.Dir(path)), filepath.Base(path), loadOptions)
+}
+
+// LoadGLTFFileFSAsync begins loading a .gltf or .glb file with the given name in the provided filesystem on a background goroutine, using
+// a provided GLTFLoadOptions struct to alter how the file is loaded (see LoadGLTFFileFS()). Loading can be canceled using the provided Context.
+// LoadGLTFFileFSAsync returns an AsyncLoad, which should be updated each frame using AsyncLoad.Update() until it's finished.
+func LoadGLTFFileFSAsync(ctx context.Context, fsys fs.FS, name string, loadOptions *GLTFLoadOptions) *AsyncLoad {
+
+	load := newAsyncLoad(ctx)
+
+	go func() {
+
+		fileData, err := fs.ReadFile(fsys, name)
+
+		if err != nil {
+			load.finishParsing(nil, err)
+			return
+		}
+
+		load.finishParsing(loadGLTFData(fileData, fsys, path.Dir(name), loadOptions, load))
+
+	}()
+
+	return load
+
+}
+
+// LoadGLTFDataAsync begins loading a .gltf or .glb file from the byte data given on a background goroutine, using a provided GLTFLoadOptions
+// struct to alter how the file is loaded (see LoadGLTFData()). Loading can be canceled using the provided Context.
+// LoadGLTFDataAsync returns an AsyncLoad, which should be updated each frame using AsyncLoad.Update() until it's finished.
+func LoadGLTFDataAsync(ctx context.Context, data []byte, loadOptions *GLTFLoadOptions) *AsyncLoad {
+
+	load := newAsyncLoad(ctx)
+
+	go func() {
+		load.finishParsing(loadGLTFData(data, nil, "", loadOptions, load))
+	}()
+
+	return load
+
+}
+
+func newAsyncLoad(ctx context.Context) *AsyncLoad {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return &AsyncLoad{
+		TexturesPerUpdate: 4,
+		ctx:               ctx,
+	}
+}
+
+// finishParsing is called from the background goroutine once the file has been parsed.
+func (load *AsyncLoad) finishParsing(library *Library, err error) {
+
+	load.mutex.Lock()
+	defer load.mutex.Unlock()
+
+	load.library = library
+	load.err = err
+	load.parsed = true
+
+	if err == nil {
+
+		// Images that aren't used by any Materials don't need to be turned into textures
+		used := make([]pendingTexture, 0, len(load.pendingTextures))
+		for _, pending := range load.pendingTextures {
+			if len(pending.targets) > 0 {
+				used = append(used, pending)
+			}
+		}
+		load.pendingTextures = used
+
+		// Cameras (including any cloned from collections) are created without their shaders and textures
+		for _, scene := range library.Scenes {
+			for _, node := range scene.Root.ChildrenRecursive() {
+				if camera, isCamera := node.(*Camera); isCamera && camera.resourcesPending {
+					load.pendingCameras = append(load.pendingCameras, camera)
+				}
+			}
+		}
+
+		load.progress = LoadProgress{Stage: LoadStageTextures, Total: len(used) + len(load.pendingCameras)}
+
+	}
+
+}
+
+// Update creates any textures and Cameras that are waiting to be created (up to AsyncLoad.TexturesPerUpdate) once the file has been parsed.
+// Update should be called from the main thread (e.g. in your game's Update() function) each frame until it returns true, indicating
+// that loading is finished (successfully or not).
+func (load *AsyncLoad) Update() bool {
+
+	load.mutex.Lock()
+	defer load.mutex.Unlock()
+
+	if load.done {
+		return true
+	}
+
+	if !load.parsed {
+		return false
+	}
+
+	if load.err == nil {
+
+		for created := 0; len(load.pendingTextures)+len(load.pendingCameras) > 0 && (load.TexturesPerUpdate <= 0 || created < load.TexturesPerUpdate); created++ {
+
+			if err := load.ctx.Err(); err != nil {
+				load.err = err
+				break
+			}
+
+			if len(load.pendingTextures) > 0 {
+				pending := load.pendingTextures[0]
+				texture := newTextureFromImage(pending.source, load.keepSources)
+				for _, target := range pending.targets {
+					*target = texture
+				}
+				load.pendingTextures = load.pendingTextures[1:]
+			} else {
+				load.pendingCameras[0].createResources()
+				load.pendingCameras = load.pendingCameras[1:]
+			}
+
+			load.progress.Current++
+
+		}
+
+		if load.err == nil && len(load.pendingTextures)+len(load.pendingCameras) > 0 {
+			return false
+		}
+
+	}
+
+	if load.err != nil {
+		load.library = nil
+	}
+
+	load.pendingTextures = nil
+	load.pendingCameras = nil
+	load.progress = LoadProgress{Stage: LoadStageDone, Current: 1, Total: 1}
+	load.done = true
+
+	return true
+
+}
+
+// Progress returns the current progress of the load.
+func (load *AsyncLoad) Progress() LoadProgress {
+	load.mutex.Lock()
+	defer load.mutex.Unlock()
+	return load.progress
+}
+
+// Done returns if the load is finished (successfully or not).
+func (load *AsyncLoad) Done() bool {
+	load.mutex.Lock()
+	defer load.mutex.Unlock()
+	return load.done
+}
+
+// Result returns the loaded Library, and an error if loading failed or was canceled. If the load isn't finished yet, Result returns nil for both.
+func (load *AsyncLoad) Result() (*Library, error) {
+	load.mutex.Lock()
+	defer load.mutex.Unlock()
+	if !load.done {
+		return nil, nil
+	}
+	return load.library, load.err
+}
+
+// The following functions are called by the GLTF loader, and do nothing when loading synchronously (i.e. when the AsyncLoad is nil).
+
+func (load *AsyncLoad) setProgress(stage, current, total int) {
+	if load == nil {
+		return
+	}
+	load.mutex.Lock()
+	load.progress = LoadProgress{Stage: stage, Current: current, Total: total}
+	load.mutex.Unlock()
+}
+
+func (load *AsyncLoad) cancelled() error {
+	if load == nil {
+		return nil
+	}
+	return load.ctx.Err()
+}
+
+func (load *AsyncLoad) startPendingTextures(count int) {
+	if load == nil {
+		return
+	}
+	load.pendingTextures = make([]pendingTexture, count)
+}
+
+// addPendingTexture adds a decoded image to be turned into a texture on the main thread, returning its index.
+func (load *AsyncLoad) addPendingTexture(source image.Image) int {
+	load.pendingTextures = append(load.pendingTextures, pendingTexture{source: source})
+	return len(load.pendingTextures) - 1
+}
@@ -0,0 +1,90 @@
+package tetra3d
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoadGLTFFileAsyncCreatesResourcesInUpdate(t *testing.T) {
+
+	options := DefaultGLTFLoadOptions()
+	options.CameraWidth = 32
+	options.CameraHeight = 24
+
+	load := LoadGLTFFileAsync(nil, "./examples/lighting/lighting.gltf", options)
+
+	// Wait for the background goroutine to finish parsing without calling Update().
+	for {
+		load.mutex.Lock()
+		parsed := load.parsed
+		load.mutex.Unlock()
+		if parsed {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if load.err != nil {
+		t.Fatal(load.err)
+	}
+
+	library := load.library
+
+	cameras := []*Camera{}
+	for _, scene := range library.Scenes {
+		for _, node := range scene.Root.ChildrenRecursive() {
+			if camera, isCamera := node.(*Camera); isCamera {
+				cameras = append(cameras, camera)
+			}
+		}
+	}
+
+	if len(cameras) == 0 {
+		t.Fatalf("expected the test file to have Cameras")
+	}
+
+	if len(load.pendingTextures) == 0 {
+		t.Fatalf("expected the test file to have textures")
+	}
+
+	for _, mat := range library.Materials {
+		if mat.Texture != nil {
+			t.Errorf("Material %s has a texture before AsyncLoad.Update() was called", mat.Name)
+		}
+	}
+
+	for _, camera := range cameras {
+		if !camera.resourcesPending || camera.depthShader != nil || camera.resultColorTexture != nil {
+			t.Errorf("Camera %s has shaders or textures before AsyncLoad.Update() was called", camera.name)
+		}
+	}
+
+	for !load.Update() {
+	}
+
+	if _, err := load.Result(); err != nil {
+		t.Fatal(err)
+	}
+
+	textured := 0
+	for _, mat := range library.Materials {
+		if mat.Texture != nil {
+			textured++
+		}
+	}
+
+	if textured == 0 {
+		t.Errorf("no textures were created by AsyncLoad.Update()")
+	}
+
+	for _, camera := range cameras {
+		if camera.resourcesPending || camera.depthShader == nil || camera.resultColorTexture == nil {
+			t.Errorf("Camera %s's shaders and textures weren't created", camera.name)
+			continue
+		}
+		if w, h := camera.resultColorTexture.Size(); w != options.CameraWidth || h != options.CameraHeight {
+			t.Errorf("Camera %s has a size of %dx%d, expected %dx%d", camera.name, w, h, options.CameraWidth, options.CameraHeight)
+		}
+	}
+
+}
@@ -0,0 +1,1198 @@
+package tetra3d
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/kvartborg/vector"
+	"github.com/qmuntal/gltf"
+	"github.com/qmuntal/gltf/ext/lightspuntual"
+	"github.com/qmuntal/gltf/modeler"
+)
+
+// GLTFExportOptions alters how a Library is exported to GLTF with ExportGLTFData() or ExportGLTFFile().
+type GLTFExportOptions struct {
+	Binary bool // If the Library should be exported as a binary .glb file, or as a .gltf JSON file with its buffers embedded. Defaults to true.
+	// PackTextures indicates if Materials' textures should be packed into the exported file as PNG images; if not, the Materials' texture paths
+	// (i.e. Material.TexturePath) are exported instead. Defaults to true.
+	// Note that Ebitengine images can't be read before the game starts, so textures can only be packed before then if their source images
+	// were registered, either by loading them with the KeepTextureSources load option or with RegisterTextureSource(); exporting
+	// returns an error otherwise.
+	PackTextures bool
+	Scenes       []*Scene // The Scenes to export; if nil, all of the Library's Scenes are exported.
+}
+
+// DefaultGLTFExportOptions creates an instance of GLTFExportOptions with some sensible defaults.
+func DefaultGLTFExportOptions() *GLTFExportOptions {
+	return &GLTFExportOptions{
+		Binary:       true,
+		PackTextures: true,
+	}
+}
+
+// ExportGLTFFile exports the given Library to a .gltf or .glb file at the filepath given, using a provided GLTFExportOptions struct to alter how
+// the Library is exported. Passing nil for exportOptions will export the Library using default export options. Whether the file is exported as
+// binary or not is determined by the file extension (.gltf or .glb) rather than the export options. See ExportGLTFData() for more information.
+func ExportGLTFFile(library *Library, path string, exportOptions *GLTFExportOptions) error {
+
+	options := DefaultGLTFExportOptions()
+	if exportOptions != nil {
+		*options = *exportOptions
+	}
+	options.Binary = !strings.EqualFold(filepath.Ext(path), ".gltf")
+
+	data, err := ExportGLTFData(library, options)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0644)
+
+}
+
+// ExportGLTFData exports the given Library to .gltf or .glb data, using a provided GLTFExportOptions struct to alter how the Library is exported.
+// Passing nil for exportOptions will export the Library using default export options. The Library's Meshes (with their vertex colors, UVs, and
+// skinning), Materials (with their textures), Animations, and Worlds are exported, along with the node hierarchies of its Scenes (including
+// Cameras, Lights, Paths, Grids, Rooms, Portals, bounding objects, LOD groups, and Nodes' tags). Anything Tetra3D-specific is exported using the
+// same extras the Tetra3D Blender add-on exports, so loading the result with LoadGLTFData() gives you back an equivalent Library.
+// Models' Instances and dynamic batches aren't exported. Models with LODGroups are exported as sibling Models named according to the "_LOD<number>"
+// naming convention, so after loading, the Model will be named with a "_LOD0" suffix if it wasn't already.
+func ExportGLTFData(library *Library, exportOptions *GLTFExportOptions) ([]byte, error) {
+
+	if exportOptions == nil {
+		exportOptions = DefaultGLTFExportOptions()
+	}
+
+	scenes := exportOptions.Scenes
+	if scenes == nil {
+		scenes = library.Scenes
+	}
+
+	if len(scenes) == 0 {
+		return nil, errors.New("error exporting GLTF data: no Scenes to export")
+	}
+
+	exporter := &gltfExporter{
+		options:      exportOptions,
+		doc:          &gltf.Document{Asset: gltf.Asset{Generator: "Tetra3D", Version: "2.0"}},
+		materials:    map[*Material]uint32{},
+		meshes:       map[*Mesh]uint32{},
+		textures:     map[*ebiten.Image]uint32{},
+		texturePaths: map[string]uint32{},
+		nodes:        map[INode]uint32{},
+		nodeNames:    map[string]uint32{},
+		names:        map[string]bool{},
+	}
+
+	doc := exporter.doc
+
+	// Materials and Meshes are exported first, including any that are used in the Scenes but aren't in the Library (i.e. created through code).
+
+	materials := []*Material{}
+	meshes := []*Mesh{}
+
+	for _, name := range sortedKeys(library.Materials) {
+		materials = append(materials, library.Materials[name])
+	}
+
+	for _, name := range sortedKeys(library.Meshes) {
+		meshes = append(meshes, library.Meshes[name])
+	}
+
+	for _, scene := range scenes {
+		for _, node := range scene.Root.ChildrenRecursive() {
+			if model, isModel := node.(*Model); isModel && model.Mesh != nil {
+				if model.LOD != nil && len(model.LOD.Levels) > 0 {
+					for _, level := range model.LOD.Levels {
+						meshes = append(meshes, level.Mesh)
+					}
+				} else {
+					meshes = append(meshes, model.Mesh)
+				}
+			}
+		}
+	}
+
+	for _, mesh := range meshes {
+		for _, part := range mesh.MeshParts {
+			if part.Material != nil {
+				materials = append(materials, part.Material)
+			}
+		}
+	}
+
+	for _, mat := range materials {
+		if err := exporter.exportMaterial(mat); err != nil {
+			return nil, err
+		}
+	}
+
+	exporter.names = map[string]bool{}
+
+	for _, mesh := range meshes {
+		exporter.exportMesh(mesh)
+	}
+
+	// Then the node hierarchies of the Scenes.
+
+	worlds := []*World{}
+	worldNames := map[string]bool{}
+
+	for _, name := range sortedKeys(library.Worlds) {
+		worlds = append(worlds, library.Worlds[name])
+		worldNames[name] = true
+	}
+
+	exportedScene := 0
+
+	for sceneIndex, scene := range scenes {
+
+		gltfScene := &gltf.Scene{Name: scene.Name}
+
+		for _, child := range scene.Root.Children() {
+			gltfScene.Nodes = append(gltfScene.Nodes, exporter.exportNode(child)...)
+		}
+
+		if scene.World != nil {
+			gltfScene.Extras = map[string]interface{}{"t3dCurrentWorld__": scene.World.Name}
+			if !worldNames[scene.World.Name] {
+				worlds = append(worlds, scene.World)
+				worldNames[scene.World.Name] = true
+			}
+		}
+
+		if scene == library.ExportedScene {
+			exportedScene = sceneIndex
+		}
+
+		doc.Scenes = append(doc.Scenes, gltfScene)
+
+	}
+
+	doc.Scene = gltf.Index(uint32(exportedScene))
+
+	if err := exporter.exportSkins(); err != nil {
+		return nil, err
+	}
+
+	for _, name := range sortedKeys(library.Animations) {
+		exporter.exportAnimation(library.Animations[name])
+	}
+
+	if len(exporter.lights) > 0 {
+		doc.Extensions = gltf.Extensions{lightspuntual.ExtensionName: map[string]interface{}{"lights": exporter.lights}}
+		doc.ExtensionsUsed = append(doc.ExtensionsUsed, lightspuntual.ExtensionName)
+	}
+
+	// Global settings are stored on the first Scene, as the Tetra3D add-on does.
+
+	settings := map[string]interface{}{}
+	if doc.Scenes[0].Extras != nil {
+		settings = doc.Scenes[0].Extras.(map[string]interface{})
+	}
+
+	if exportOptions.PackTextures {
+		settings["t3dPackTextures__"] = 1
+	} else {
+		settings["t3dPackTextures__"] = 0
+	}
+
+	if exporter.cameraResolution != nil {
+		settings["t3dCameraResolution__"] = exporter.cameraResolution
+	}
+
+	if len(worlds) > 0 {
+		worldData := map[string]interface{}{}
+		for _, world := range worlds {
+			worldData[world.Name] = exportWorld(world)
+		}
+		settings["t3dWorlds__"] = worldData
+	}
+
+	doc.Scenes[0].Extras = settings
+
+	buffer := &bytes.Buffer{}
+
+	encoder := gltf.NewEncoder(buffer)
+	encoder.AsBinary = exportOptions.Binary
+
+	if err := encoder.Encode(doc); err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+
+}
+
+// gltfExporter holds the state of a Library being exported to GLTF.
+type gltfExporter struct {
+	options *GLTFExportOptions
+	doc     *gltf.Document
+
+	materials    map[*Material]uint32
+	meshes       map[*Mesh]uint32
+	textures     map[*ebiten.Image]uint32
+	texturePaths map[string]uint32
+	nodes        map[INode]uint32
+	nodeNames    map[string]uint32
+	names        map[string]bool // Used names for the type of element being exported, as the loader identifies Materials and Meshes by name
+
+	lights           lightspuntual.Lights
+	skinnedModels    []*Model
+	cameraResolution []int
+}
+
+// uniqueName returns the name given, or the name with a numeric suffix if it's already been used.
+func (exporter *gltfExporter) uniqueName(name string) string {
+	unique := name
+	for i := 1; exporter.names[unique]; i++ {
+		unique = fmt.Sprintf("%s.%03d", name, i)
+	}
+	exporter.names[unique] = true
+	return unique
+}
+
+func (exporter *gltfExporter) exportMaterial(mat *Material) error {
+
+	if _, exists := exporter.materials[mat]; exists {
+		return nil
+	}
+
+	color := mat.Color.Clone()
+	color.ConvertToLinear()
+
+	emissive := mat.EmissiveColor.Clone()
+	emissive.ConvertToLinear()
+
+	gltfMat := &gltf.Material{
+		Name:        exporter.uniqueName(mat.Name),
+		DoubleSided: !mat.BackfaceCulling,
+		PBRMetallicRoughness: &gltf.PBRMetallicRoughness{
+			BaseColorFactor: &[4]float32{clamp01(color.R), clamp01(color.G), clamp01(color.B), clamp01(color.A)},
+			MetallicFactor:  gltf.Float(mat.Metallic),
+			RoughnessFactor: gltf.Float(mat.Roughness),
+		},
+		EmissiveFactor: [3]float32{clamp01(emissive.R), clamp01(emissive.G), clamp01(emissive.B)},
+	}
+
+	switch mat.TransparencyMode {
+	case TransparencyModeTransparent:
+		gltfMat.AlphaMode = gltf.AlphaBlend
+	case TransparencyModeAlphaClip:
+		gltfMat.AlphaMode = gltf.AlphaMask
+	default:
+		gltfMat.AlphaMode = gltf.AlphaOpaque
+	}
+
+	texture, exists, err := exporter.exportTexture(mat.Texture, mat.TexturePath)
+	if err != nil {
+		return err
+	} else if exists {
+		gltfMat.PBRMetallicRoughness.BaseColorTexture = &gltf.TextureInfo{Index: texture}
+	}
+
+	texture, exists, err = exporter.exportTexture(mat.NormalTexture, mat.NormalTexturePath)
+	if err != nil {
+		return err
+	} else if exists {
+		gltfMat.NormalTexture = &gltf.NormalTexture{Index: gltf.Index(texture)}
+	}
+
+	texture, exists, err = exporter.exportTexture(mat.SpecularTexture, mat.SpecularTexturePath)
+	if err != nil {
+		return err
+	} else if exists {
+		gltfMat.PBRMetallicRoughness.MetallicRoughnessTexture = &gltf.TextureInfo{Index: texture}
+	}
+
+	texture, exists, err = exporter.exportTexture(mat.EmissiveTexture, mat.EmissiveTexturePath)
+	if err != nil {
+		return err
+	} else if exists {
+		gltfMat.EmissiveTexture = &gltf.TextureInfo{Index: texture}
+	}
+
+	extras := map[string]interface{}{}
+
+	for tagName, data := range mat.Tags.tags {
+		extras[tagName] = data
+	}
+
+	extras["t3dMaterialColor__"] = []float32{color.R, color.G, color.B, color.A}
+	extras["t3dMaterialShadeless__"] = boolToFloat(mat.Shadeless)
+	extras["t3dMaterialFogless__"] = boolToFloat(mat.Fogless)
+
+	switch mat.CompositeMode {
+	case ebiten.CompositeModeLighter:
+		extras["t3dCompositeMode__"] = 1
+	case ebiten.CompositeModeDestinationOut:
+		extras["t3dCompositeMode__"] = 3
+	default:
+		extras["t3dCompositeMode__"] = 0
+	}
+
+	extras["t3dBillboardMode__"] = mat.BillboardMode
+	extras["t3dLightingMode__"] = mat.LightingMode
+
+	gltfMat.Extras = extras
+
+	exporter.materials[mat] = uint32(len(exporter.doc.Materials))
+	exporter.doc.Materials = append(exporter.doc.Materials, gltfMat)
+
+	return nil
+
+}
+
+// exportTexture exports the given texture (if textures are packed) or texture path (if they aren't), returning the index of the GLTF texture,
+// and whether there was a texture to export.
+func (exporter *gltfExporter) exportTexture(texture *ebiten.Image, path string) (uint32, bool, error) {
+
+	doc := exporter.doc
+
+	if exporter.options.PackTextures {
+
+		if texture == nil {
+			return 0, false, nil
+		}
+
+		if index, exists := exporter.textures[texture]; exists {
+			return index, true, nil
+		}
+
+		textureSourcesMutex.Lock()
+		source, exists := textureSources[texture]
+		textureSourcesMutex.Unlock()
+
+		if !exists {
+			pixels, err := readTexture(texture)
+			if err != nil {
+				if path != "" {
+					err = fmt.Errorf("error exporting texture %s: %w", path, err)
+				}
+				return 0, false, err
+			}
+			source = pixels
+		}
+
+		imageData := &bytes.Buffer{}
+		if err := png.Encode(imageData, source); err != nil {
+			return 0, false, err
+		}
+
+		imageIndex, err := modeler.WriteImage(doc, "", "image/png", imageData)
+		if err != nil {
+			return 0, false, err
+		}
+
+		doc.Textures = append(doc.Textures, &gltf.Texture{Source: gltf.Index(imageIndex)})
+		exporter.textures[texture] = uint32(len(doc.Textures) - 1)
+		return exporter.textures[texture], true, nil
+
+	}
+
+	if path == "" {
+		return 0, false, nil
+	}
+
+	if index, exists := exporter.texturePaths[path]; exists {
+		return index, true, nil
+	}
+
+	doc.Images = append(doc.Images, &gltf.Image{URI: path})
+	doc.Textures = append(doc.Textures, &gltf.Texture{Source: gltf.Index(uint32(len(doc.Images) - 1))})
+	exporter.texturePaths[path] = uint32(len(doc.Textures) - 1)
+	return exporter.texturePaths[path], true, nil
+
+}
+
+func (exporter *gltfExporter) exportMesh(mesh *Mesh) {
+
+	if _, exists := exporter.meshes[mesh]; exists {
+		return
+	}
+
+	doc := exporter.doc
+
+	gltfMesh := &gltf.Mesh{Name: exporter.uniqueName(mesh.Name)}
+
+	colorChannelCount := 0
+	skinned := false
+
+	for i := 0; i < mesh.VertexCount; i++ {
+		if len(mesh.VertexColors[i]) > colorChannelCount {
+			colorChannelCount = len(mesh.VertexColors[i])
+		}
+		if len(mesh.VertexBones[i]) > 0 {
+			skinned = true
+		}
+	}
+
+	for _, part := range mesh.MeshParts {
+
+		vertexCount := (part.TriangleEnd - part.TriangleStart) * 3
+
+		if part.TriangleStart < 0 || vertexCount <= 0 {
+			continue
+		}
+
+		positions := make([][3]float32, 0, vertexCount)
+		normals := make([][3]float32, 0, vertexCount)
+		uvs := make([][2]float32, 0, vertexCount)
+		colors := make([][][4]uint16, colorChannelCount)
+		joints := make([][4]uint16, 0, vertexCount)
+		weights := make([][4]float32, 0, vertexCount)
+		indices := make([]uint32, 0, vertexCount)
+
+		for v := part.TriangleStart * 3; v < part.TriangleEnd*3; v++ {
+
+			pos := mesh.VertexPositions[v]
+			positions = append(positions, [3]float32{float32(pos[0]), float32(pos[1]), float32(pos[2])})
+
+			normal := mesh.VertexNormals[v]
+			normals = append(normals, [3]float32{float32(normal[0]), float32(normal[1]), float32(normal[2])})
+
+			uv := mesh.VertexUVs[v]
+			uvs = append(uvs, [2]float32{float32(uv[0]), float32(1 - uv[1])})
+
+			// Vertex colors are displayed as sRGB, but exported from Blender as linear, so we convert them back here
+			for c := 0; c < colorChannelCount; c++ {
+				color := NewColor(1, 1, 1, 1)
+				if c < len(mesh.VertexColors[v]) && mesh.VertexColors[v][c] != nil {
+					color = mesh.VertexColors[v][c].Clone()
+				}
+				color.ConvertToLinear()
+				colors[c] = append(colors[c], [4]uint16{
+					uint16(clamp01(color.R) * math.MaxUint16),
+					uint16(clamp01(color.G) * math.MaxUint16),
+					uint16(clamp01(color.B) * math.MaxUint16),
+					uint16(clamp01(color.A) * math.MaxUint16),
+				})
+			}
+
+			if skinned {
+				vertJoints := [4]uint16{}
+				vertWeights := [4]float32{}
+				for i := 0; i < len(mesh.VertexBones[v]) && i < 4; i++ {
+					vertJoints[i] = mesh.VertexBones[v][i]
+					vertWeights[i] = mesh.VertexWeights[v][i]
+				}
+				joints = append(joints, vertJoints)
+				weights = append(weights, vertWeights)
+			}
+
+			indices = append(indices, uint32(len(indices)))
+
+		}
+
+		primitive := &gltf.Primitive{
+			Attributes: map[string]uint32{
+				gltf.POSITION:   modeler.WritePosition(doc, positions),
+				gltf.NORMAL:     modeler.WriteNormal(doc, normals),
+				gltf.TEXCOORD_0: modeler.WriteTextureCoord(doc, uvs),
+			},
+		}
+
+		for c, channel := range colors {
+			primitive.Attributes["COLOR_"+strconv.Itoa(c)] = modeler.WriteColor(doc, channel)
+		}
+
+		if skinned {
+			primitive.Attributes[gltf.JOINTS_0] = modeler.WriteJoints(doc, joints)
+			primitive.Attributes[gltf.WEIGHTS_0] = modeler.WriteWeights(doc, weights)
+		}
+
+		primitive.Indices = gltf.Index(modeler.WriteIndices(doc, indices))
+
+		if part.Material != nil {
+			primitive.Material = gltf.Index(exporter.materials[part.Material])
+		}
+
+		gltfMesh.Primitives = append(gltfMesh.Primitives, primitive)
+
+	}
+
+	extras := map[string]interface{}{}
+
+	for tagName, data := range mesh.Tags.tags {
+		extras[tagName] = data
+	}
+
+	if len(mesh.VertexColorChannelNames) > 0 {
+		channelNames := make([]string, colorChannelCount)
+		for name, index := range mesh.VertexColorChannelNames {
+			if index >= len(channelNames) {
+				channelNames = append(channelNames, make([]string, index-len(channelNames)+1)...)
+			}
+			channelNames[index] = name
+		}
+		extras["t3dVertexColorNames__"] = channelNames
+	}
+
+	if len(extras) > 0 {
+		gltfMesh.Extras = extras
+	}
+
+	exporter.meshes[mesh] = uint32(len(doc.Meshes))
+	doc.Meshes = append(doc.Meshes, gltfMesh)
+
+}
+
+// exportNode exports the given node and its children, returning the indices of the GLTF nodes created for it (which is usually just one,
+// but Models with LODGroups export a node for each level). Children are exported before their parents, as the loader expects.
+func (exporter *gltfExporter) exportNode(node INode) []uint32 {
+
+	doc := exporter.doc
+
+	gltfNode := &gltf.Node{Name: node.Name()}
+	setGLTFNodeTransform(gltfNode, node)
+
+	extras := map[string]interface{}{}
+	skippedChildren := map[INode]bool{}
+	lodNodes := []*gltf.Node{}
+
+	switch n := node.(type) {
+
+	case *Model:
+
+		if n.Mesh != nil {
+
+			if n.LOD != nil && len(n.LOD.Levels) > 0 {
+
+				baseName := n.name
+				if index := strings.LastIndex(baseName, "_LOD"); index >= 0 {
+					if _, err := strconv.Atoi(baseName[index+4:]); err == nil {
+						baseName = baseName[:index]
+					}
+				}
+
+				gltfNode.Name = baseName + "_LOD0"
+				gltfNode.Mesh = gltf.Index(exporter.meshes[n.LOD.Levels[0].Mesh])
+				extras["t3dLODMode__"] = n.LOD.Mode
+				extras["t3dLODHysteresis__"] = n.LOD.Hysteresis
+				extras["t3dLODThreshold__"] = n.LOD.Levels[0].Threshold
+
+				for i := 1; i < len(n.LOD.Levels); i++ {
+					lodNode := &gltf.Node{
+						Name:   baseName + "_LOD" + strconv.Itoa(i),
+						Mesh:   gltf.Index(exporter.meshes[n.LOD.Levels[i].Mesh]),
+						Extras: map[string]interface{}{"t3dLODThreshold__": n.LOD.Levels[i].Threshold},
+					}
+					setGLTFNodeTransform(lodNode, node)
+					lodNodes = append(lodNodes, lodNode)
+				}
+
+			} else {
+				gltfNode.Mesh = gltf.Index(exporter.meshes[n.Mesh])
+				if n.Skinned {
+					exporter.skinnedModels = append(exporter.skinnedModels, n)
+				}
+			}
+
+		}
+
+	case *Camera:
+
+		camera := &gltf.Camera{Name: n.name}
+
+		if n.Perspective {
+			camera.Perspective = &gltf.Perspective{
+				Yfov:  float32(n.FieldOfView / 360 * math.Pi * 2),
+				Znear: float32(n.Near),
+				Zfar:  gltf.Float(float32(n.Far)),
+			}
+		} else {
+			w, h := n.resultColorTexture.Size()
+			camera.Orthographic = &gltf.Orthographic{
+				Xmag:  float32(n.OrthoScale),
+				Ymag:  float32(n.OrthoScale * float64(h) / float64(w)),
+				Znear: float32(n.Near),
+				Zfar:  float32(n.Far),
+			}
+		}
+
+		if exporter.cameraResolution == nil {
+			w, h := n.resultColorTexture.Size()
+			exporter.cameraResolution = []int{w, h}
+		}
+
+		gltfNode.Camera = gltf.Index(uint32(len(doc.Cameras)))
+		doc.Cameras = append(doc.Cameras, camera)
+
+	case *DirectionalLight:
+		exporter.exportLight(gltfNode, &lightspuntual.Light{
+			Type:      lightspuntual.TypeDirectional,
+			Color:     &[3]float32{n.Color.R, n.Color.G, n.Color.B},
+			Intensity: gltf.Float(n.Energy),
+		})
+
+	case *PointLight:
+		light := &lightspuntual.Light{
+			Type:      lightspuntual.TypePoint,
+			Color:     &[3]float32{n.Color.R, n.Color.G, n.Color.B},
+			Intensity: gltf.Float(n.Energy * 1000),
+		}
+		if n.Distance > 0 {
+			light.Range = gltf.Float(float32(n.Distance))
+		}
+		exporter.exportLight(gltfNode, light)
+
+	case *SpotLight:
+		light := &lightspuntual.Light{
+			Type:      lightspuntual.TypeSpot,
+			Color:     &[3]float32{n.Color.R, n.Color.G, n.Color.B},
+			Intensity: gltf.Float(n.Energy * 1000),
+			Spot: &lightspuntual.Spot{
+				InnerConeAngle: float32(n.InnerConeAngle),
+				OuterConeAngle: gltf.Float(float32(n.OuterConeAngle)),
+			},
+		}
+		if n.Distance > 0 {
+			light.Range = gltf.Float(float32(n.Distance))
+		}
+		exporter.exportLight(gltfNode, light)
+
+	case *AmbientLight:
+		// Ambient lights aren't part of the GLTF spec, but the loader turns any unsupported light type into one.
+		exporter.exportLight(gltfNode, &lightspuntual.Light{
+			Type:      "ambient",
+			Color:     &[3]float32{n.Color.R, n.Color.G, n.Color.B},
+			Intensity: gltf.Float(n.Energy * 1000),
+		})
+
+	case *Path:
+
+		// Path points are recreated from the Path's extras when loaded.
+		points := [][]float64{}
+		for _, child := range n.children {
+			points = append(points, toBlenderCoordinates(child.LocalPosition()))
+			skippedChildren[child] = true
+		}
+		extras["t3dPathPoints__"] = points
+		extras["t3dPathCyclic__"] = boolToFloat(n.Closed)
+
+	case *Grid:
+
+		// As with Paths, GridPoints are recreated from the Grid's extras when loaded.
+		gridPoints := n.Points()
+		pointIndices := map[*GridPoint]string{}
+		entries := []string{}
+
+		for i, point := range gridPoints {
+			pos := toBlenderCoordinates(point.LocalPosition())
+			entries = append(entries, fmt.Sprintf("(%s, %s, %s)",
+				strconv.FormatFloat(pos[0], 'f', -1, 64),
+				strconv.FormatFloat(pos[1], 'f', -1, 64),
+				strconv.FormatFloat(pos[2], 'f', -1, 64),
+			))
+			pointIndices[point] = strconv.Itoa(i)
+			skippedChildren[point] = true
+		}
+
+		connections := map[string][]string{}
+		for _, point := range gridPoints {
+			connected := []string{}
+			for _, other := range point.Connections {
+				if index, exists := pointIndices[other]; exists {
+					connected = append(connected, index)
+				}
+			}
+			connections[pointIndices[point]] = connected
+		}
+
+		extras["t3dGridEntries__"] = entries
+		extras["t3dGridConnections__"] = connections
+
+	case *Room:
+		min := toBlenderCoordinates(n.Dimensions[0])
+		max := toBlenderCoordinates(n.Dimensions[1])
+		extras["t3dRoomBounds__"] = append(min, max...)
+
+	case *Portal:
+		points := [][]float64{}
+		for _, p := range n.Points {
+			points = append(points, toBlenderCoordinates(p))
+		}
+		extras["t3dPortalPoints__"] = points
+
+	}
+
+	// The first bounding object under the node is exported as the node's bounds type; others are exported as plain Nodes.
+	for _, child := range node.Children() {
+
+		if skippedChildren[child] || !child.Type().Is(NodeTypeBoundingObject) {
+			continue
+		}
+
+		exported := true
+
+		switch bounds := child.(type) {
+		case *BoundingAABB:
+			extras["t3dBoundsType__"] = 1
+			extras["t3dAABBCustomEnabled__"] = 1
+			extras["t3dAABBCustomSize__"] = []float64{bounds.internalSize[0], bounds.internalSize[1], bounds.internalSize[2]}
+		case *BoundingCapsule:
+			extras["t3dBoundsType__"] = 2
+			extras["t3dCapsuleCustomEnabled__"] = 1
+			extras["t3dCapsuleCustomHeight__"] = bounds.Height
+			extras["t3dCapsuleCustomRadius__"] = bounds.Radius
+		case *BoundingSphere:
+			extras["t3dBoundsType__"] = 3
+			extras["t3dSphereCustomEnabled__"] = 1
+			extras["t3dSphereCustomRadius__"] = bounds.Radius
+		case *BoundingTriangles:
+			if model, isModel := node.(*Model); !isModel || model.Mesh == nil {
+				exported = false
+				break
+			}
+			extras["t3dBoundsType__"] = 4
+			extras["t3dTrianglesCustomBroadphaseEnabled__"] = 1
+			if bounds.Broadphase != nil && bounds.Broadphase.GridSize > 0 {
+				extras["t3dTrianglesCustomBroadphaseGridSize__"] = bounds.Broadphase.cellSize
+			} else {
+				extras["t3dTrianglesCustomBroadphaseGridSize__"] = 0
+			}
+		default:
+			exported = false
+		}
+
+		if exported {
+			skippedChildren[child] = true
+			break
+		}
+
+	}
+
+	for _, child := range node.Children() {
+		if !skippedChildren[child] {
+			gltfNode.Children = append(gltfNode.Children, exporter.exportNode(child)...)
+		}
+	}
+
+	extras["t3dVisible__"] = boolToFloat(node.Visible())
+
+	if original := node.getOriginalLocalPosition(); original != nil && (original[0] != 0 || original[1] != 0 || original[2] != 0) {
+		extras["t3dOriginalLocalPosition__"] = toBlenderCoordinates(original)
+	}
+
+	exportTags(node.Tags(), extras)
+
+	gltfNode.Extras = extras
+
+	indices := []uint32{uint32(len(doc.Nodes))}
+	exporter.nodes[node] = indices[0]
+	if _, exists := exporter.nodeNames[gltfNode.Name]; !exists {
+		exporter.nodeNames[gltfNode.Name] = indices[0]
+	}
+	doc.Nodes = append(doc.Nodes, gltfNode)
+
+	for _, lodNode := range lodNodes {
+		indices = append(indices, uint32(len(doc.Nodes)))
+		doc.Nodes = append(doc.Nodes, lodNode)
+	}
+
+	return indices
+
+}
+
+func (exporter *gltfExporter) exportLight(gltfNode *gltf.Node, light *lightspuntual.Light) {
+	light.Name = gltfNode.Name
+	gltfNode.Extensions = gltf.Extensions{lightspuntual.ExtensionName: map[string]interface{}{"light": len(exporter.lights)}}
+	exporter.lights = append(exporter.lights, light)
+}
+
+// exportSkins exports the skins of skinned Models; this is done after all nodes have been exported, as skins refer to the bones' nodes.
+func (exporter *gltfExporter) exportSkins() error {
+
+	doc := exporter.doc
+
+	for _, model := range exporter.skinnedModels {
+
+		// The Mesh's vertex bone indices are indices into the skin's joints.
+		joints := []*Node{}
+		used := map[*Node]bool{}
+
+		for vertIndex, bones := range model.bones {
+			for i, bone := range bones {
+				if i >= len(model.Mesh.VertexBones[vertIndex]) {
+					break
+				}
+				boneIndex := int(model.Mesh.VertexBones[vertIndex][i])
+				for len(joints) <= boneIndex {
+					joints = append(joints, nil)
+				}
+				joints[boneIndex] = bone
+				used[bone] = true
+			}
+		}
+
+		// Any bones that don't influence vertices fill in the remaining joints.
+		spareBones := []*Node{}
+		if model.SkinRoot != nil {
+			for _, n := range model.SkinRoot.ChildrenRecursive() {
+				if bone, isNode := n.(*Node); isNode && bone.isBone && !used[bone] {
+					spareBones = append(spareBones, bone)
+				}
+			}
+		}
+
+		skin := &gltf.Skin{Name: model.name}
+		inverseBindMatrices := [][4][4]float32{}
+
+		for _, bone := range joints {
+
+			if bone == nil {
+				if len(spareBones) == 0 {
+					return errors.New("error exporting GLTF data: skinned Model " + model.name + " refers to bones that can't be found")
+				}
+				bone = spareBones[0]
+				spareBones = spareBones[1:]
+			}
+
+			nodeIndex, exists := exporter.nodes[bone]
+			if !exists {
+				return errors.New("error exporting GLTF data: bone " + bone.name + " of skinned Model " + model.name + " isn't in an exported Scene")
+			}
+
+			skin.Joints = append(skin.Joints, nodeIndex)
+
+			matrix := [4][4]float32{}
+			for c := 0; c < 4; c++ {
+				column := bone.inverseBindMatrix.Column(c)
+				matrix[c] = [4]float32{float32(column[0]), float32(column[1]), float32(column[2]), float32(column[3])}
+			}
+			inverseBindMatrices = append(inverseBindMatrices, matrix)
+
+		}
+
+		if model.SkinRoot != nil {
+			if rootIndex, exists := exporter.nodes[model.SkinRoot]; exists {
+				skin.Skeleton = gltf.Index(rootIndex)
+			}
+		}
+
+		skin.InverseBindMatrices = gltf.Index(modeler.WriteAccessor(doc, gltf.TargetNone, inverseBindMatrices))
+
+		doc.Nodes[exporter.nodes[model]].Skin = gltf.Index(uint32(len(doc.Skins)))
+		doc.Skins = append(doc.Skins, skin)
+
+	}
+
+	return nil
+
+}
+
+func (exporter *gltfExporter) exportAnimation(animation *Animation) {
+
+	doc := exporter.doc
+
+	gltfAnim := &gltf.Animation{Name: animation.Name}
+
+	channelNames := sortedKeys(animation.Channels)
+
+	for _, channelName := range channelNames {
+
+		channel := animation.Channels[channelName]
+
+		var target *uint32
+		if nodeIndex, exists := exporter.nodeNames[channelName]; exists && channelName != "root" {
+			target = gltf.Index(nodeIndex)
+		}
+
+		for _, trackType := range []string{TrackTypePosition, TrackTypeScale, TrackTypeRotation} {
+
+			track, exists := channel.Tracks[trackType]
+			if !exists || len(track.Keyframes) == 0 {
+				continue
+			}
+
+			times := make([]float32, 0, len(track.Keyframes))
+			var output interface{}
+			var path gltf.TRSProperty
+
+			if trackType == TrackTypeRotation {
+				path = gltf.TRSRotation
+				values := make([][4]float32, 0, len(track.Keyframes))
+				for _, key := range track.Keyframes {
+					times = append(times, float32(key.Time))
+					q := key.Data.AsQuaternion()
+					values = append(values, [4]float32{float32(q.X), float32(q.Y), float32(q.Z), float32(q.W)})
+				}
+				output = values
+			} else {
+				path = gltf.TRSTranslation
+				if trackType == TrackTypeScale {
+					path = gltf.TRSScale
+				}
+				values := make([][3]float32, 0, len(track.Keyframes))
+				for _, key := range track.Keyframes {
+					times = append(times, float32(key.Time))
+					v := key.Data.AsVector()
+					values = append(values, [3]float32{float32(v[0]), float32(v[1]), float32(v[2])})
+				}
+				output = values
+			}
+
+			input := modeler.WriteAccessor(doc, gltf.TargetNone, times)
+
+			// Animation inputs need to have their minimum and maximum values set
+			min, max := times[0], times[0]
+			for _, t := range times {
+				if t < min {
+					min = t
+				}
+				if t > max {
+					max = t
+				}
+			}
+			doc.Accessors[input].Min = []float32{min}
+			doc.Accessors[input].Max = []float32{max}
+
+			gltfAnim.Samplers = append(gltfAnim.Samplers, &gltf.AnimationSampler{
+				Input:         gltf.Index(input),
+				Output:        gltf.Index(modeler.WriteAccessor(doc, gltf.TargetNone, output)),
+				Interpolation: gltf.Interpolation(track.Interpolation),
+			})
+
+			gltfAnim.Channels = append(gltfAnim.Channels, &gltf.Channel{
+				Sampler: gltf.Index(uint32(len(gltfAnim.Samplers) - 1)),
+				Target:  gltf.ChannelTarget{Node: target, Path: path},
+			})
+
+		}
+
+	}
+
+	if len(animation.Markers) > 0 {
+		markers := []map[string]interface{}{}
+		for _, marker := range animation.Markers {
+			markers = append(markers, map[string]interface{}{"name": marker.Name, "time": marker.Time})
+		}
+		gltfAnim.Extras = map[string]interface{}{"t3dMarkers__": markers}
+	}
+
+	doc.Animations = append(doc.Animations, gltfAnim)
+
+}
+
+// exportWorld returns the properties of the World as they're exported by the Tetra3D add-on.
+func exportWorld(world *World) map[string]interface{} {
+
+	props := map[string]interface{}{}
+
+	linear := func(color *Color) []float32 {
+		c := color.Clone()
+		c.ConvertToLinear()
+		return []float32{c.R, c.G, c.B, c.A}
+	}
+
+	if world.AmbientLight != nil {
+		props["ambient color"] = linear(world.AmbientLight.Color)
+		props["ambient energy"] = world.AmbientLight.Energy
+	}
+
+	props["clear color"] = linear(world.ClearColor)
+
+	switch world.FogMode {
+	case FogOff:
+		props["fog mode"] = "OFF"
+	case FogAdd:
+		props["fog mode"] = "ADDITIVE"
+	case FogMultiply:
+		props["fog mode"] = "MULTIPLY"
+	case FogOverwrite:
+		props["fog mode"] = "OVERWRITE"
+	case FogTransparent:
+		props["fog mode"] = "TRANSPARENT"
+	}
+
+	props["fog color"] = linear(world.FogColor)
+	props["fog range start"] = world.FogRange[0]
+	props["fog range end"] = world.FogRange[1]
+
+	switch world.FogCurve {
+	case FogCurveLinear:
+		props["fog curve"] = "LINEAR"
+	case FogCurveExponential:
+		props["fog curve"] = "EXPONENTIAL"
+	case FogCurveExponentialSquared:
+		props["fog curve"] = "EXPONENTIAL_SQUARED"
+	}
+
+	props["fog density"] = world.FogDensity
+	props["fog height"] = world.FogHeight
+	props["fog height falloff"] = world.FogHeightFalloff
+
+	switch world.SkyMode {
+	case SkyOff:
+		props["sky mode"] = "OFF"
+	case SkyGradient:
+		props["sky mode"] = "GRADIENT"
+	case SkyEquirectangular:
+		props["sky mode"] = "EQUIRECTANGULAR"
+	case SkyCubemap:
+		props["sky mode"] = "CUBEMAP"
+	}
+
+	props["sky top color"] = linear(world.SkyTopColor)
+	props["sky horizon color"] = linear(world.SkyHorizonColor)
+	props["sky bottom color"] = linear(world.SkyBottomColor)
+	props["sun color"] = linear(world.SunColor)
+
+	if world.SunDirection != nil {
+		props["sun direction"] = toBlenderCoordinates(world.SunDirection)
+	}
+
+	props["sun size"] = world.SunSize
+
+	if world.SkyTexturePath != "" {
+		props["sky texture path"] = world.SkyTexturePath
+	}
+
+	return props
+
+}
+
+// exportTags adds the given Tags to the extras map; tags of the types that the Tetra3D add-on's game properties support are
+// exported as game properties, while others are exported as-is.
+func exportTags(tags *Tags, extras map[string]interface{}) {
+
+	gameProperties := []map[string]interface{}{}
+
+	for _, name := range sortedKeys(tags.tags) {
+
+		property := map[string]interface{}{"name": name}
+
+		switch value := tags.tags[name].(type) {
+		case bool:
+			property["valueType"] = 0
+			property["valueBool"] = boolToFloat(value)
+		case int:
+			property["valueType"] = 1
+			property["valueInt"] = value
+		case float64:
+			property["valueType"] = 2
+			property["valueFloat"] = value
+		case string:
+			property["valueType"] = 3
+			property["valueString"] = value
+		case *Color:
+			color := value.Clone()
+			color.ConvertToLinear()
+			property["valueType"] = 5
+			property["valueColor"] = []float32{color.R, color.G, color.B, color.A}
+		case vector.Vector:
+			property["valueType"] = 6
+			property["valueVector3D"] = []float64(value)
+		default:
+			extras[name] = value
+			continue
+		}
+
+		gameProperties = append(gameProperties, property)
+
+	}
+
+	if len(gameProperties) > 0 {
+		extras["t3dGameProperties__"] = gameProperties
+	}
+
+}
+
+// setGLTFNodeTransform sets the GLTF node's transform to the local transform of the given node.
+func setGLTFNodeTransform(gltfNode *gltf.Node, node INode) {
+
+	position := node.LocalPosition()
+	scale := node.LocalScale()
+	rotation := node.LocalRotation().ToQuaternion()
+
+	gltfNode.Matrix = gltf.DefaultMatrix
+	gltfNode.Translation = [3]float32{float32(position[0]), float32(position[1]), float32(position[2])}
+	gltfNode.Scale = [3]float32{float32(scale[0]), float32(scale[1]), float32(scale[2])}
+	gltfNode.Rotation = [4]float32{float32(rotation.X), float32(rotation.Y), float32(rotation.Z), float32(rotation.W)}
+
+}
+
+// toBlenderCoordinates converts the given vector from Tetra3D's Y-up coordinates to Blender's Z-up coordinates, as used in the Tetra3D
+// add-on's extras.
+func toBlenderCoordinates(vec vector.Vector) []float64 {
+	return []float64{vec[0], -vec[2], vec[1]}
+}
+
+func boolToFloat(value bool) float64 {
+	if value {
+		return 1
+	}
+	return 0
+}
+
+func clamp01(value float32) float32 {
+	return float32(math.Min(math.Max(float64(value), 0), 1))
+}
+
+// sortedKeys returns the keys of the given string-keyed map in sorted order, so that exports are deterministic.
+func sortedKeys(m interface{}) []string {
+
+	keys := []string{}
+
+	switch data := m.(type) {
+	case map[string]*Material:
+		for k := range data {
+			keys = append(keys, k)
+		}
+	case map[string]*Mesh:
+		for k := range data {
+			keys = append(keys, k)
+		}
+	case map[string]*Animation:
+		for k := range data {
+			keys = append(keys, k)
+		}
+	case map[string]*World:
+		for k := range data {
+			keys = append(keys, k)
+		}
+	case map[string]*AnimationChannel:
+		for k := range data {
+			keys = append(keys, k)
+		}
+	case map[string]interface{}:
+		for k := range data {
+			keys = append(keys, k)
+		}
+	}
+
+	sort.Strings(keys)
+
+	return keys
+
+}
+
+// readTexture copies the texture's pixels into a new image. Ebitengine can only read images back from the GPU once the game is running,
+// so readTexture returns an error, rather than panicking, if it's called before then.
+func readTexture(texture *ebiten.Image) (img image.Image, err error) {
+
+	defer func() {
+		if r := recover(); r != nil {
+			img = nil
+			err = fmt.Errorf("can't read the texture's pixels before the game is running (%v); load textures with the KeepTextureSources load option or register their source images with RegisterTextureSource() to export them before then", r)
+		}
+	}()
+
+	w, h := texture.Size()
+	pixels := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(pixels, pixels.Bounds(), texture, image.Point{}, draw.Src)
+
+	return pixels, nil
+
+}
@@ -0,0 +1,228 @@
+package tetra3d
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/kvartborg/vector"
+)
+
+func TestExportGLTFDataRoundTrip(t *testing.T) {
+
+	options := DefaultGLTFLoadOptions()
+	options.KeepTextureSources = true
+
+	original, err := LoadGLTFFile("./examples/animations/animations.gltf", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer original.UnregisterTextureSources()
+
+	// Tags of each type that's exported as a game property
+	tagged := original.Scenes[0].Root.Children()[0]
+	tagged.Tags().Set("bool", true)
+	tagged.Tags().Set("int", 3)
+	tagged.Tags().Set("float", 1.5)
+	tagged.Tags().Set("string", "value")
+	tagged.Tags().Set("color", NewColor(1, 0.5, 0.25, 1))
+	tagged.Tags().Set("vector", vector.Vector{1, 2, 3})
+
+	data, err := ExportGLTFData(original, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := LoadGLTFData(data, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reloaded.UnregisterTextureSources()
+
+	if len(reloaded.Scenes) != len(original.Scenes) {
+		t.Fatalf("expected %d Scenes, got %d", len(original.Scenes), len(reloaded.Scenes))
+	}
+
+	skinnedModels := 0
+
+	for sceneIndex, scene := range original.Scenes {
+
+		reloadedNodes := map[string]INode{}
+		for _, node := range reloaded.Scenes[sceneIndex].Root.ChildrenRecursive() {
+			reloadedNodes[node.Path()] = node
+		}
+
+		for _, node := range scene.Root.ChildrenRecursive() {
+
+			path := node.Path()
+
+			reloadedNode, exists := reloadedNodes[path]
+			if !exists {
+				t.Errorf("node %s is missing after reloading", path)
+				continue
+			}
+
+			if reflect.TypeOf(node) != reflect.TypeOf(reloadedNode) {
+				t.Errorf("node %s is a %T, expected a %T", path, reloadedNode, node)
+				continue
+			}
+
+			if !matricesEqual(node.Transform(), reloadedNode.Transform()) {
+				t.Errorf("node %s has a world transform of %v, expected %v", path, reloadedNode.Transform(), node.Transform())
+			}
+
+			compareTags(t, path, node.Tags(), reloadedNode.Tags())
+
+			if model, isModel := node.(*Model); isModel {
+				compareModels(t, model, reloadedNode.(*Model))
+				if model.Skinned {
+					skinnedModels++
+				}
+			}
+
+		}
+
+	}
+
+	if skinnedModels == 0 {
+		t.Errorf("expected the test file to have skinned Models")
+	}
+
+}
+
+func TestExportGLTFDataUnregisteredTexture(t *testing.T) {
+
+	library := NewLibrary()
+	mesh := NewCube()
+	mesh.MeshParts[0].Material.Texture = ebiten.NewImage(4, 4)
+	library.Meshes[mesh.Name] = mesh
+	library.Materials["Cube"] = mesh.MeshParts[0].Material
+	library.AddScene("Scene").Root.AddChildren(NewModel(mesh, "Cube"))
+
+	// The texture's pixels can't be read back before the game is running, and there's no source image to pack instead.
+	if _, err := ExportGLTFData(library, nil); err == nil {
+		t.Errorf("exporting a texture without a registered source before the game is running should return an error")
+	}
+
+	options := DefaultGLTFExportOptions()
+	options.PackTextures = false
+
+	if _, err := ExportGLTFData(library, options); err != nil {
+		t.Errorf("exporting without packing textures returned an error: %s", err)
+	}
+
+}
+
+func compareModels(t *testing.T, model, reloaded *Model) {
+
+	path := model.Path()
+	mesh, reloadedMesh := model.Mesh, reloaded.Mesh
+
+	if mesh.VertexCount != reloadedMesh.VertexCount {
+		t.Errorf("Model %s has %d vertices, expected %d", path, reloadedMesh.VertexCount, mesh.VertexCount)
+		return
+	}
+
+	for i := 0; i < mesh.VertexCount; i++ {
+
+		if !vectorsEqual(mesh.VertexPositions[i], reloadedMesh.VertexPositions[i]) {
+			t.Errorf("vertex %d of Model %s is at %v, expected %v", i, path, reloadedMesh.VertexPositions[i], mesh.VertexPositions[i])
+			return
+		}
+
+		if len(mesh.VertexColors[i]) != len(reloadedMesh.VertexColors[i]) {
+			t.Errorf("vertex %d of Model %s has %d color channels, expected %d", i, path, len(reloadedMesh.VertexColors[i]), len(mesh.VertexColors[i]))
+			return
+		}
+
+		for c, color := range mesh.VertexColors[i] {
+			if !colorsEqual(color, reloadedMesh.VertexColors[i][c]) {
+				t.Errorf("vertex %d of Model %s has color %v in channel %d, expected %v", i, path, reloadedMesh.VertexColors[i][c], c, color)
+				return
+			}
+		}
+
+	}
+
+	if model.Skinned != reloaded.Skinned {
+		t.Errorf("Model %s skinned: %t, expected %t", path, reloaded.Skinned, model.Skinned)
+		return
+	}
+
+	if !model.Skinned {
+		return
+	}
+
+	if model.SkinRoot.Path() != reloaded.SkinRoot.Path() {
+		t.Errorf("Model %s has skin root %s, expected %s", path, reloaded.SkinRoot.Path(), model.SkinRoot.Path())
+	}
+
+	for i, vertexBones := range model.bones {
+		for b, bone := range vertexBones {
+			reloadedBone := reloaded.bones[i][b]
+			if bone.Path() != reloadedBone.Path() || mesh.VertexWeights[i][b] != reloadedMesh.VertexWeights[i][b] {
+				t.Errorf("vertex %d of Model %s is weighted %f to bone %s, expected %f to %s",
+					i, path, reloadedMesh.VertexWeights[i][b], reloadedBone.Path(), mesh.VertexWeights[i][b], bone.Path())
+				return
+			}
+			if !matricesEqual(bone.inverseBindMatrix, reloadedBone.inverseBindMatrix) {
+				t.Errorf("bone %s has inverse bind matrix %v, expected %v", bone.Path(), reloadedBone.inverseBindMatrix, bone.inverseBindMatrix)
+				return
+			}
+		}
+	}
+
+}
+
+func compareTags(t *testing.T, path string, tags, reloaded *Tags) {
+
+	for name, value := range tags.tags {
+
+		// The add-on's internal properties are only used while loading.
+		if strings.HasPrefix(name, "t3d") && strings.HasSuffix(name, "__") {
+			continue
+		}
+
+		reloadedValue, exists := reloaded.tags[name]
+		if !exists {
+			t.Errorf("node %s is missing tag %s", path, name)
+			continue
+		}
+
+		equal := reflect.DeepEqual(value, reloadedValue)
+
+		switch v := value.(type) {
+		case *Color:
+			c, ok := reloadedValue.(*Color)
+			equal = ok && colorsEqual(v, c)
+		case vector.Vector:
+			vec, ok := reloadedValue.(vector.Vector)
+			equal = ok && vectorsEqual(v, vec)
+		}
+
+		if !equal {
+			t.Errorf("node %s has tag %s = %v (%T), expected %v (%T)", path, name, reloadedValue, reloadedValue, value, value)
+		}
+
+	}
+
+}
+
+func matricesEqual(a, b Matrix4) bool {
+	for row := range a {
+		for col := range a[row] {
+			if math.Abs(a[row][col]-b[row][col]) > 1e-4 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func colorsEqual(a, b *Color) bool {
+	m := float32(1.0 / 255)
+	return math.Abs(float64(a.R-b.R)) <= float64(m) && math.Abs(float64(a.G-b.G)) <= float64(m) &&
+		math.Abs(float64(a.B-b.B)) <= float64(m) && math.Abs(float64(a.A-b.A)) <= float64(m)
+}
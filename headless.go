@@ -0,0 +1,614 @@
+package tetra3d
+
+import (
+	"errors"
+	"image"
+	"image/draw"
+	"image/png"
+	"math"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/kvartborg/vector"
+)
+
+// Ebitengine images can't be read until the game is running, so the software renderer samples textures from the source images
+// they were created from instead.
+var textureSources = map[*ebiten.Image]image.Image{}
+var textureSourcesMutex sync.Mutex
+
+// RegisterTextureSource registers the source image a texture was created from, so that Camera.RenderToImage() can sample the texture
+// (and ExportGLTFData() can pack it) before the game is running. Textures loaded from files are only registered if the KeepTextureSources
+// load option is set. Registered sources are kept in memory until they're unregistered with UnregisterTextureSource() (or
+// Library.UnregisterTextureSources()), so they should be unregistered once the texture is no longer needed. Registering a nil source
+// unregisters the texture.
+func RegisterTextureSource(texture *ebiten.Image, source image.Image) {
+	textureSourcesMutex.Lock()
+	defer textureSourcesMutex.Unlock()
+	if source == nil {
+		delete(textureSources, texture)
+	} else {
+		textureSources[texture] = source
+	}
+}
+
+// UnregisterTextureSource unregisters the source image registered for the given texture (see RegisterTextureSource()), if there is one,
+// allowing it to be garbage collected.
+func UnregisterTextureSource(texture *ebiten.Image) {
+	RegisterTextureSource(texture, nil)
+}
+
+// newTextureFromImage creates a new texture from the given image, registering the image as the texture's source if keepSource is true.
+func newTextureFromImage(source image.Image, keepSource bool) *ebiten.Image {
+	texture := ebiten.NewImageFromImage(source)
+	if keepSource {
+		RegisterTextureSource(texture, source)
+	}
+	return texture
+}
+
+// softwareTexture is a texture's source image, converted for sampling.
+type softwareTexture struct {
+	pixels *image.NRGBA
+	width  int
+	height int
+}
+
+func (tex *softwareTexture) sample(u, v float64, repeat bool) (float32, float32, float32, float32) {
+
+	x := int(math.Floor(u * float64(tex.width)))
+	y := int(math.Floor(v * float64(tex.height)))
+
+	if repeat {
+		x %= tex.width
+		if x < 0 {
+			x += tex.width
+		}
+		y %= tex.height
+		if y < 0 {
+			y += tex.height
+		}
+	} else if x < 0 || y < 0 || x >= tex.width || y >= tex.height {
+		return 0, 0, 0, 0
+	}
+
+	i := tex.pixels.PixOffset(x, y)
+	p := tex.pixels.Pix[i : i+4 : i+4]
+	return float32(p[0]) / 255, float32(p[1]) / 255, float32(p[2]) / 255, float32(p[3]) / 255
+
+}
+
+// softwareVertex is a vertex of a triangle being rasterized in software.
+type softwareVertex struct {
+	x, y, depth float64
+	u, v        float64
+	r, g, b, a  float32
+}
+
+// softwareRasterizer rasterizes triangles into color and depth buffers on the CPU.
+type softwareRasterizer struct {
+	width, height int
+	color         []float32 // Non-premultiplied RGBA
+	depth         []float64
+	textures      map[*ebiten.Image]*softwareTexture
+}
+
+func (sr *softwareRasterizer) texture(img *ebiten.Image) *softwareTexture {
+
+	if img == nil {
+		return nil
+	}
+
+	if tex, exists := sr.textures[img]; exists {
+		return tex
+	}
+
+	textureSourcesMutex.Lock()
+	source, exists := textureSources[img]
+	textureSourcesMutex.Unlock()
+
+	var tex *softwareTexture
+
+	if exists {
+		bounds := source.Bounds()
+		pixels := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+		draw.Draw(pixels, pixels.Bounds(), source, bounds.Min, draw.Src)
+		tex = &softwareTexture{pixels: pixels, width: bounds.Dx(), height: bounds.Dy()}
+	}
+
+	sr.textures[img] = tex
+
+	return tex
+
+}
+
+// triangle rasterizes the triangle made up of the given vertices, sampling from the texture (if it's not nil) and blending according to the material.
+func (sr *softwareRasterizer) triangle(verts [3]softwareVertex, tex *softwareTexture, mat *Material, writeDepth bool) {
+
+	v0, v1, v2 := verts[0], verts[1], verts[2]
+
+	area := (v1.x-v0.x)*(v2.y-v0.y) - (v1.y-v0.y)*(v2.x-v0.x)
+	if area == 0 {
+		return
+	}
+
+	minX := int(math.Max(0, math.Floor(math.Min(v0.x, math.Min(v1.x, v2.x)))))
+	maxX := int(math.Min(float64(sr.width-1), math.Ceil(math.Max(v0.x, math.Max(v1.x, v2.x)))))
+	minY := int(math.Max(0, math.Floor(math.Min(v0.y, math.Min(v1.y, v2.y)))))
+	maxY := int(math.Min(float64(sr.height-1), math.Ceil(math.Max(v0.y, math.Max(v1.y, v2.y)))))
+
+	repeat := true
+	compositeMode := ebiten.CompositeModeSourceOver
+	clip := false
+
+	if mat != nil {
+		repeat = mat.TextureWrapMode == ebiten.AddressRepeat
+		compositeMode = mat.CompositeMode
+		clip = mat.TransparencyMode == TransparencyModeAlphaClip
+	}
+
+	for y := minY; y <= maxY; y++ {
+
+		py := float64(y) + 0.5
+
+		for x := minX; x <= maxX; x++ {
+
+			px := float64(x) + 0.5
+
+			w0 := ((v1.x-px)*(v2.y-py) - (v1.y-py)*(v2.x-px)) / area
+			w1 := ((v2.x-px)*(v0.y-py) - (v2.y-py)*(v0.x-px)) / area
+			w2 := 1 - w0 - w1
+
+			if w0 < 0 || w1 < 0 || w2 < 0 {
+				continue
+			}
+
+			index := y*sr.width + x
+
+			depth := v0.depth*w0 + v1.depth*w1 + v2.depth*w2
+
+			if depth > sr.depth[index] {
+				continue
+			}
+
+			r := v0.r*float32(w0) + v1.r*float32(w1) + v2.r*float32(w2)
+			g := v0.g*float32(w0) + v1.g*float32(w1) + v2.g*float32(w2)
+			b := v0.b*float32(w0) + v1.b*float32(w1) + v2.b*float32(w2)
+			a := v0.a*float32(w0) + v1.a*float32(w1) + v2.a*float32(w2)
+
+			if tex != nil {
+				tr, tg, tb, ta := tex.sample(v0.u*w0+v1.u*w1+v2.u*w2, v0.v*w0+v1.v*w1+v2.v*w2, repeat)
+				if clip && ta == 0 {
+					continue
+				}
+				r *= tr
+				g *= tg
+				b *= tb
+				a *= ta
+			}
+
+			r = float32(math.Min(math.Max(float64(r), 0), 1))
+			g = float32(math.Min(math.Max(float64(g), 0), 1))
+			b = float32(math.Min(math.Max(float64(b), 0), 1))
+			a = float32(math.Min(math.Max(float64(a), 0), 1))
+
+			dst := sr.color[index*4 : index*4+4 : index*4+4]
+
+			switch compositeMode {
+			case ebiten.CompositeModeClear:
+				dst[0], dst[1], dst[2], dst[3] = 0, 0, 0, 0
+			case ebiten.CompositeModeLighter:
+				dst[0] = float32(math.Min(float64(dst[0]+r*a), 1))
+				dst[1] = float32(math.Min(float64(dst[1]+g*a), 1))
+				dst[2] = float32(math.Min(float64(dst[2]+b*a), 1))
+				dst[3] = float32(math.Min(float64(dst[3]+a), 1))
+			default:
+				outA := a + dst[3]*(1-a)
+				if outA > 0 {
+					dst[0] = (r*a + dst[0]*dst[3]*(1-a)) / outA
+					dst[1] = (g*a + dst[1]*dst[3]*(1-a)) / outA
+					dst[2] = (b*a + dst[2]*dst[3]*(1-a)) / outA
+				}
+				dst[3] = outA
+			}
+
+			if writeDepth {
+				sr.depth[index] = depth
+			}
+
+		}
+
+	}
+
+}
+
+// RenderToImage renders the Scene through the Camera into a new image.RGBA the size of the Camera's color texture, using a software
+// rasterizer running on the CPU instead of the GPU. As it doesn't use Ebitengine to draw, it works without a display or a running game,
+// which makes it useful for tests comparing renders against reference images, or for generating thumbnails in CI.
+//
+// The software renderer supports textures (as long as their source images are registered; see RegisterTextureSource() and the
+// KeepTextureSources load options), vertex colors, vertex
+// lighting, backface culling, depth testing, transparency, alpha clipping, render layers, LODs, Instances, and dynamic batching. Pixel
+// lighting, fog, skies, custom fragment shaders, and post-processing effects aren't supported. If the Scene has a World, the image is
+// filled with the World's ClearColor first. Note that the Camera's color and depth textures aren't changed.
+func (camera *Camera) RenderToImage(scene *Scene) *image.RGBA {
+
+	width, height := camera.resultColorTexture.Size()
+
+	sr := &softwareRasterizer{
+		width:    width,
+		height:   height,
+		color:    make([]float32, width*height*4),
+		depth:    make([]float64, width*height),
+		textures: map[*ebiten.Image]*softwareTexture{},
+	}
+
+	for i := range sr.depth {
+		sr.depth[i] = math.MaxFloat64
+	}
+
+	if scene.World != nil && scene.World.ClearColor != nil {
+		c := scene.World.ClearColor
+		for i := 0; i < width*height; i++ {
+			sr.color[i*4], sr.color[i*4+1], sr.color[i*4+2], sr.color[i*4+3] = c.R, c.G, c.B, c.A
+		}
+	}
+
+	// Camera.Clear() usually sets the Camera's orientation vectors for frustum culling, but it also clears the Camera's textures.
+	cameraRot := camera.WorldRotation()
+	camera.cameraForward = cameraRot.Forward().Invert()
+	camera.cameraRight = cameraRot.Right()
+	camera.cameraUp = cameraRot.Up()
+
+	sceneLights := []ILight{}
+
+	if scene.World == nil || scene.World.LightingOn {
+
+		for _, l := range scene.Root.ChildrenRecursive() {
+			if light, isLight := l.(ILight); isLight && light.IsOn() {
+				sceneLights = append(sceneLights, light)
+				light.beginRender()
+			}
+		}
+
+		if scene.World != nil && scene.World.AmbientLight != nil && scene.World.AmbientLight.IsOn() {
+			sceneLights = append(sceneLights, scene.World.AmbientLight)
+			scene.World.AmbientLight.beginRender()
+		}
+
+	}
+
+	vpMatrix := camera.ViewMatrix().Mult(camera.Projection())
+
+	// softwarePart is a MeshPart of a Model to render, along with the Material to render it with (which differs for dynamically batched Models).
+	type softwarePart struct {
+		model    *Model
+		meshPart *MeshPart
+		material *Material
+		instance *Instance
+		depth    float64
+	}
+
+	solids := []softwarePart{}
+	transparents := []softwarePart{}
+
+	addPart := func(model *Model, meshPart *MeshPart, mat *Material, instance *Instance, transparent bool) {
+		part := softwarePart{model: model, meshPart: meshPart, material: mat, instance: instance}
+		position := model.WorldPosition()
+		if instance != nil {
+			position = instance.Transform.Mult(model.Transform()).Row(3)[:3]
+		}
+		part.depth = fastVectorDistanceSquared(position, camera.WorldPosition())
+		if transparent {
+			transparents = append(transparents, part)
+		} else {
+			solids = append(solids, part)
+		}
+	}
+
+	for _, node := range scene.Root.ChildrenRecursive() {
+
+		model, isModel := node.(*Model)
+
+		if !isModel || !model.visible || model.DynamicBatchOwner != nil || model.RenderLayers&camera.CullingMask == 0 {
+			continue
+		}
+
+		camera.updateLOD(model)
+
+		if len(model.DynamicBatchModels) > 0 {
+
+			for ownerPart, batched := range model.DynamicBatchModels {
+				for _, child := range batched {
+					if !child.visible || child.Mesh == nil {
+						continue
+					}
+					camera.updateLOD(child)
+					for _, mp := range child.Mesh.MeshParts {
+						addPart(child, mp, ownerPart.Material, nil, model.isTransparent(ownerPart))
+					}
+				}
+			}
+
+		} else if model.Mesh != nil {
+
+			for _, mp := range model.Mesh.MeshParts {
+				if len(model.Instances) > 0 {
+					for _, instance := range model.Instances {
+						if instance != nil && instance.Visible {
+							addPart(model, mp, mp.Material, instance, model.isTransparent(mp))
+						}
+					}
+				} else {
+					addPart(model, mp, mp.Material, nil, model.isTransparent(mp))
+				}
+			}
+
+		}
+
+	}
+
+	sort.SliceStable(transparents, func(i, j int) bool { return transparents[i].depth > transparents[j].depth })
+
+	renderPart := func(part softwarePart, transparent bool) {
+
+		model := part.model
+		meshPart := part.meshPart
+		mat := part.material
+
+		if part.instance != nil {
+			ir := newInstanceRenderer(model)
+			model = ir.begin(part.instance, part.instance.Transform.Mult(ir.transform))
+		}
+
+		model.Transform()
+
+		// The proxy an Instance is rendered through doesn't frustum cull, so the Model's setting is checked instead.
+		if part.model.FrustumCulling {
+
+			if part.instance != nil {
+				transform := model.Transform()
+				_, scale, _ := transform.Decompose()
+				maxScale := math.Max(math.Max(math.Abs(scale[0]), math.Abs(scale[1])), math.Abs(scale[2]))
+				if !camera.sphereInFrustum(transform.MultVec(model.Mesh.Dimensions.Center()), model.Mesh.Dimensions.MaxSpan()/2*maxScale) {
+					return
+				}
+			} else if !camera.SphereInFrustum(model.BoundingSphere) {
+				return
+			}
+
+		}
+
+		lighting := false
+		if scene.World != nil {
+			lighting = scene.World.LightingOn && (mat == nil || !mat.Shadeless)
+		}
+
+		lights := sceneLights
+
+		if lighting {
+			if model.LightGroup != nil && model.LightGroup.Active {
+				lights = model.LightGroup.Lights
+				for _, l := range lights {
+					l.beginRender()
+				}
+			}
+			for _, light := range lights {
+				light.beginModel(model)
+			}
+		}
+
+		model.ProcessVertices(vpMatrix, camera, meshPart, scene)
+
+		mesh := model.Mesh
+
+		var tex *softwareTexture
+		if mat != nil {
+			tex = sr.texture(mat.Texture)
+		}
+
+		mpColor := model.Color.Clone()
+		if mat != nil {
+			mpColor.Multiply(mat.Color)
+		}
+
+		backfaceCulling := mat == nil || mat.BackfaceCulling
+
+		screenPos := vector.Vector{0, 0, 0, 0}
+
+		for _, tri := range meshPart.sortingTriangles {
+
+			if !tri.rendered {
+				continue
+			}
+
+			verts := [3]softwareVertex{}
+			behind := false
+
+			for i := 0; i < 3; i++ {
+
+				vertIndex := tri.ID*3 + i
+				transformed := mesh.vertexTransforms[vertIndex]
+
+				if camera.Perspective && transformed[3] <= 0 {
+					behind = true
+					break
+				}
+
+				screenPos = camera.clipToScreen(transformed, screenPos, vertIndex, model, float64(width), float64(height))
+
+				vert := &verts[i]
+				vert.x = screenPos[0]
+				vert.y = screenPos[1]
+				vert.depth = transformed[2]
+				vert.u = mesh.VertexUVs[vertIndex][0]
+				vert.v = 1 - mesh.VertexUVs[vertIndex][1]
+
+				if activeChannel := mesh.VertexActiveColorChannel[vertIndex]; activeChannel >= 0 {
+					vc := mesh.VertexColors[vertIndex][activeChannel]
+					vert.r, vert.g, vert.b, vert.a = vc.R*mpColor.R, vc.G*mpColor.G, vc.B*mpColor.B, vc.A*mpColor.A
+				} else {
+					vert.r, vert.g, vert.b, vert.a = mpColor.R, mpColor.G, mpColor.B, mpColor.A
+				}
+
+			}
+
+			if behind {
+				continue
+			}
+
+			if backfaceCulling {
+				ax, ay := verts[0].x-verts[1].x, verts[0].y-verts[1].y
+				bx, by := verts[1].x-verts[2].x, verts[1].y-verts[2].y
+				if ax*by-ay*bx > 0 {
+					continue
+				}
+			}
+
+			if lighting {
+
+				light := [9]float32{}
+				for _, l := range lights {
+					result := l.Light(tri.ID, model)
+					for i := range light {
+						light[i] += result[i]
+					}
+				}
+
+				for i := 0; i < 3; i++ {
+					verts[i].r *= light[i*3]
+					verts[i].g *= light[i*3+1]
+					verts[i].b *= light[i*3+2]
+				}
+
+			}
+
+			sr.triangle(verts, tex, mat, !transparent)
+
+		}
+
+	}
+
+	for _, part := range solids {
+		renderPart(part, false)
+	}
+
+	for _, part := range transparents {
+		renderPart(part, true)
+	}
+
+	out := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	// image.RGBA holds premultiplied colors.
+	for i := 0; i < width*height; i++ {
+		a := sr.color[i*4+3]
+		out.Pix[i*4] = uint8(math.Round(float64(sr.color[i*4]*a) * 255))
+		out.Pix[i*4+1] = uint8(math.Round(float64(sr.color[i*4+1]*a) * 255))
+		out.Pix[i*4+2] = uint8(math.Round(float64(sr.color[i*4+2]*a) * 255))
+		out.Pix[i*4+3] = uint8(math.Round(float64(a) * 255))
+	}
+
+	return out
+
+}
+
+// SavePNG saves the given image to a PNG file at the given path.
+func SavePNG(img image.Image, path string) error {
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := png.Encode(file, img); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+
+}
+
+// ImageDifference describes the difference between two images of the same size, as returned by CompareImages().
+type ImageDifference struct {
+	TotalPixels          int // The total number of pixels compared.
+	MismatchedPixels     int // The number of pixels with any color channel differing by more than the tolerance.
+	MaxChannelDifference int // The largest difference found between any color channel of any pixel (from 0 to 255).
+}
+
+// Matches returns true if no more than the given fraction of pixels (from 0 to 1) were mismatched.
+func (diff ImageDifference) Matches(maxMismatchedFraction float64) bool {
+	if diff.TotalPixels == 0 {
+		return true
+	}
+	return float64(diff.MismatchedPixels)/float64(diff.TotalPixels) <= maxMismatchedFraction
+}
+
+// CompareImages compares the given images pixel by pixel, with a pixel being considered mismatched if any of its (non-premultiplied)
+// color channels differ by more than the tolerance (from 0 to 255). An error is returned if the images are different sizes.
+func CompareImages(img, reference image.Image, tolerance int) (ImageDifference, error) {
+
+	diff := ImageDifference{}
+
+	bounds := img.Bounds()
+	refBounds := reference.Bounds()
+
+	if bounds.Dx() != refBounds.Dx() || bounds.Dy() != refBounds.Dy() {
+		return diff, errors.New("images are different sizes")
+	}
+
+	a := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(a, a.Bounds(), img, bounds.Min, draw.Src)
+
+	b := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(b, b.Bounds(), reference, refBounds.Min, draw.Src)
+
+	diff.TotalPixels = bounds.Dx() * bounds.Dy()
+
+	for i := 0; i < len(a.Pix); i += 4 {
+
+		mismatched := false
+
+		for c := 0; c < 4; c++ {
+			d := int(a.Pix[i+c]) - int(b.Pix[i+c])
+			if d < 0 {
+				d = -d
+			}
+			if d > diff.MaxChannelDifference {
+				diff.MaxChannelDifference = d
+			}
+			if d > tolerance {
+				mismatched = true
+			}
+		}
+
+		if mismatched {
+			diff.MismatchedPixels++
+		}
+
+	}
+
+	return diff, nil
+
+}
+
+// CompareImageToPNG compares the given image against the reference PNG image at the given path, as CompareImages() does.
+func CompareImageToPNG(img image.Image, referencePath string, tolerance int) (ImageDifference, error) {
+
+	file, err := os.Open(referencePath)
+	if err != nil {
+		return ImageDifference{}, err
+	}
+
+	defer file.Close()
+
+	reference, err := png.Decode(file)
+	if err != nil {
+		return ImageDifference{}, err
+	}
+
+	return CompareImages(img, reference, tolerance)
+
+}
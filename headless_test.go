@@ -0,0 +1,143 @@
+package tetra3d
+
+import (
+	"flag"
+	"image"
+	"image/color"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "update the golden images in testdata with the current results")
+
+func TestCompareImages(t *testing.T) {
+
+	reference := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	for i := range reference.Pix {
+		reference.Pix[i] = 128
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	copy(img.Pix, reference.Pix)
+
+	diff, err := CompareImages(img, reference, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff.TotalPixels != 16 || diff.MismatchedPixels != 0 || diff.MaxChannelDifference != 0 {
+		t.Errorf("identical images: got %+v", diff)
+	}
+
+	img.SetNRGBA(1, 2, color.NRGBA{128, 138, 128, 128})
+	img.SetNRGBA(3, 3, color.NRGBA{125, 128, 128, 128})
+
+	diff, err = CompareImages(img, reference, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff.MismatchedPixels != 1 || diff.MaxChannelDifference != 10 {
+		t.Errorf("images differing by 10 and 3 with a tolerance of 5: got %+v", diff)
+	}
+	if diff.Matches(0) || !diff.Matches(1.0/16) {
+		t.Errorf("one mismatched pixel out of 16 should match a fraction of 1/16, but not 0")
+	}
+
+	diff, err = CompareImages(img, reference, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff.MismatchedPixels != 0 {
+		t.Errorf("images differing by 10 with a tolerance of 10: got %+v", diff)
+	}
+
+	// Images are compared by their contents, regardless of where their bounds start.
+	offset := image.NewNRGBA(image.Rect(0, 0, 6, 6)).SubImage(image.Rect(2, 2, 6, 6)).(*image.NRGBA)
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			offset.SetNRGBA(x+2, y+2, img.NRGBAAt(x, y))
+		}
+	}
+
+	diff, err = CompareImages(offset, img, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff.MismatchedPixels != 0 {
+		t.Errorf("offset image: got %+v", diff)
+	}
+
+	if _, err := CompareImages(image.NewNRGBA(image.Rect(0, 0, 4, 3)), reference, 0); err == nil {
+		t.Errorf("comparing images of different sizes should return an error")
+	}
+
+}
+
+func TestRenderToImage(t *testing.T) {
+
+	checker := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+	checker.SetNRGBA(0, 0, color.NRGBA{255, 0, 0, 255})
+	checker.SetNRGBA(1, 0, color.NRGBA{0, 255, 0, 255})
+	checker.SetNRGBA(0, 1, color.NRGBA{0, 0, 255, 255})
+	checker.SetNRGBA(1, 1, color.NRGBA{255, 255, 255, 255})
+
+	texture := newTextureFromImage(checker, true)
+	defer UnregisterTextureSource(texture)
+
+	mesh := NewPlane()
+	mat := mesh.MeshParts[0].Material
+	mat.Texture = texture
+	mat.Shadeless = true
+
+	plane := NewModel(mesh, "Plane")
+	plane.Rotate(1, 0, 0, math.Pi/2) // Face the camera; this puts the top of the texture (V = 0) at the bottom
+
+	scene := NewScene("Scene")
+	scene.World = NewWorld("World")
+	scene.World.ClearColor = NewColor(0, 0, 0, 1)
+	scene.Root.AddChildren(plane)
+
+	camera := NewCamera(64, 48)
+	camera.Move(0, 0, 4)
+	scene.Root.AddChildren(camera)
+
+	result := camera.RenderToImage(scene)
+
+	// Each quarter of the plane should show one of the texture's pixels, while the corners of the image are left clear.
+	expected := map[image.Point]color.RGBA{
+		{0, 0}:   {0, 0, 0, 255},
+		{63, 47}: {0, 0, 0, 255},
+		{27, 19}: {0, 0, 255, 255},
+		{36, 19}: {255, 255, 255, 255},
+		{27, 28}: {255, 0, 0, 255},
+		{36, 28}: {0, 255, 0, 255},
+	}
+
+	for point, c := range expected {
+		if got := result.RGBAAt(point.X, point.Y); got != c {
+			t.Errorf("pixel at %v: expected %v, got %v", point, c, got)
+		}
+	}
+
+	goldenPath := filepath.Join("testdata", "render_textured_plane.png")
+
+	if *updateGolden {
+		if err := os.MkdirAll("testdata", 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := SavePNG(result, goldenPath); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	diff, err := CompareImageToPNG(result, goldenPath, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !diff.Matches(0) {
+		t.Errorf("render doesn't match %s: %+v", goldenPath, diff)
+	}
+
+}
@@ -0,0 +1,145 @@
+package tetra3d
+
+import (
+	"math"
+
+	"github.com/kvartborg/vector"
+)
+
+// Instance is a single copy of a Model's Mesh, rendered as part of the Model - see Model.Instances. Instances are much lighter than
+// Models (they aren't Nodes, and so have no hierarchy, bounds, or tags), which makes them useful for rendering thousands of
+// copies of the same Mesh, like for grass, trees, or crowds.
+type Instance struct {
+	Transform Matrix4 // The Instance's transform, relative to the Model's transform.
+	Color     *Color  // The Instance's color, which is multiplied by the Model's Color.
+	Visible   bool    // Whether the Instance is rendered or not.
+}
+
+// NewInstance creates a new, visible Instance with the given transform (relative to the Model it's rendered with).
+func NewInstance(transform Matrix4) *Instance {
+	return &Instance{
+		Transform: transform,
+		Color:     NewColor(1, 1, 1, 1),
+		Visible:   true,
+	}
+}
+
+// Clone clones the Instance.
+func (instance *Instance) Clone() *Instance {
+	newInstance := NewInstance(instance.Transform)
+	newInstance.Color = instance.Color.Clone()
+	newInstance.Visible = instance.Visible
+	return newInstance
+}
+
+// SetTransform sets the Instance's transform using the given position, scale, and rotation (relative to the Model it's rendered with).
+func (instance *Instance) SetTransform(position, scale vector.Vector, rotation Matrix4) {
+	transform := NewMatrix4Scale(scale[0], scale[1], scale[2])
+	transform = transform.Mult(rotation)
+	instance.Transform = transform.Mult(NewMatrix4Translate(position[0], position[1], position[2]))
+}
+
+// Position returns the Instance's position (relative to the Model it's rendered with).
+func (instance *Instance) Position() vector.Vector {
+	return instance.Transform.Row(3)[:3]
+}
+
+// AddInstances adds the given Instances to the Model, and returns the Model for chaining.
+func (model *Model) AddInstances(instances ...*Instance) *Model {
+	model.Instances = append(model.Instances, instances...)
+	return model
+}
+
+// RemoveInstances removes the given Instances from the Model.
+func (model *Model) RemoveInstances(instances ...*Instance) {
+	for _, instance := range instances {
+		for i, existing := range model.Instances {
+			if existing == instance {
+				model.Instances[i] = nil
+				model.Instances = append(model.Instances[:i], model.Instances[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// instanceRenderer renders the Instances of a Model. Rather than modifying the Model, each Instance is rendered through a proxy Model
+// that shares the Model's Mesh and rendering settings, with its transform and color set to the Instance's in turn.
+type instanceRenderer struct {
+	model     *Model
+	proxy     *Model
+	transform Matrix4
+	center    vector.Vector
+	radius    float64
+}
+
+func newInstanceRenderer(model *Model) *instanceRenderer {
+
+	// The proxy is kept on the Model so it isn't recreated each frame. It's created without a Mesh so that it doesn't allocate a
+	// skinning vector pool, as Instances of skinned Models aren't supported.
+	if model.instanceProxy == nil {
+		model.instanceProxy = NewModel(nil, model.name)
+	}
+
+	proxy := model.instanceProxy
+	proxy.Mesh = model.Mesh // The Mesh can change between frames through LOD
+	proxy.FrustumCulling = false
+	proxy.ColorBlendingFunc = model.ColorBlendingFunc
+	proxy.LightGroup = model.LightGroup
+	proxy.RenderLayers = model.RenderLayers
+	proxy.VertexTransformFunction = model.VertexTransformFunction
+	proxy.VertexClipFunction = model.VertexClipFunction
+
+	return &instanceRenderer{
+		model:     model,
+		proxy:     proxy,
+		transform: model.Transform(),
+		center:    model.Mesh.Dimensions.Center(),
+		radius:    model.Mesh.Dimensions.MaxSpan() / 2,
+	}
+
+}
+
+// visibleInstances returns the visible Instances of the Model that are within the Camera's frustum (if the Model has FrustumCulling on),
+// along with their world transforms.
+func (ir *instanceRenderer) visibleInstances(camera *Camera) ([]*Instance, []Matrix4) {
+
+	instances := make([]*Instance, 0, len(ir.model.Instances))
+	transforms := make([]Matrix4, 0, len(ir.model.Instances))
+
+	for _, instance := range ir.model.Instances {
+
+		if instance == nil || !instance.Visible {
+			continue
+		}
+
+		transform := instance.Transform.Mult(ir.transform)
+
+		if ir.model.FrustumCulling {
+
+			_, scale, _ := transform.Decompose()
+			maxScale := math.Max(math.Max(math.Abs(scale[0]), math.Abs(scale[1])), math.Abs(scale[2]))
+
+			if !camera.sphereInFrustum(transform.MultVec(ir.center), ir.radius*maxScale) {
+				continue
+			}
+
+		}
+
+		instances = append(instances, instance)
+		transforms = append(transforms, transform)
+
+	}
+
+	return instances, transforms
+
+}
+
+// begin sets the proxy's transform and color to be the given Instance's (with the transform being the Instance's world transform),
+// and returns the proxy to render.
+func (ir *instanceRenderer) begin(instance *Instance, transform Matrix4) *Model {
+	ir.proxy.SetWorldTransform(transform)
+	ir.proxy.Color.Set(ir.model.Color.R, ir.model.Color.G, ir.model.Color.B, ir.model.Color.A)
+	ir.proxy.Color.Multiply(instance.Color)
+	return ir.proxy
+}
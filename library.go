@@ -1,5 +1,7 @@
 package tetra3d
 
+import "github.com/hajimehoshi/ebiten/v2"
+
 // Library represents a collection of Scenes, Meshes, Animations, etc., as loaded from an intermediary file format (.dae or .gltf / .glb).
 type Library struct {
 	Scenes        []*Scene              // A slice of Scenes
@@ -50,3 +52,35 @@ func (lib *Library) FindNode(objectName string) INode {
 	}
 	return nil
 }
+
+// UnregisterTextureSources unregisters the source images registered for the textures used by the Library's Materials and Worlds (see
+// RegisterTextureSource()), allowing them to be garbage collected. This should be called when you're done with a Library that was loaded
+// with the KeepTextureSources load option.
+func (lib *Library) UnregisterTextureSources() {
+	for texture := range lib.textures() {
+		UnregisterTextureSource(texture)
+	}
+}
+
+// textures returns the set of textures used by the Library's Materials and Worlds.
+func (lib *Library) textures() map[*ebiten.Image]bool {
+
+	textures := map[*ebiten.Image]bool{}
+
+	for _, mat := range lib.Materials {
+		for _, texture := range []*ebiten.Image{mat.Texture, mat.NormalTexture, mat.SpecularTexture, mat.EmissiveTexture} {
+			if texture != nil {
+				textures[texture] = true
+			}
+		}
+	}
+
+	for _, world := range lib.Worlds {
+		if world.SkyTexture != nil {
+			textures[world.SkyTexture] = true
+		}
+	}
+
+	return textures
+
+}
@@ -0,0 +1,407 @@
+package tetra3d
+
+import (
+	"log"
+	"os"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// watchedLibrary is a Library being watched by a LibraryWatcher, along with the GLTF file it was loaded from.
+type watchedLibrary struct {
+	path    string
+	library *Library
+
+	loadedModTime time.Time // The modification time and size of the file when it was last loaded
+	loadedSize    int64
+	seenModTime   time.Time // The modification time and size of the file when it was last polled
+	seenSize      int64
+
+	textures map[*ebiten.Image]bool // The textures loaded for the Library, which are disposed of once reloading replaces them
+}
+
+// LibraryWatcher is a development tool that watches the GLTF files that Libraries were loaded from, reloading them when they change
+// (e.g. when re-exported from Blender) and patching the Libraries and any live Scenes in place, so changes can be seen without
+// restarting the game.
+//
+// When a file is reloaded, the Library's Meshes, Materials, Animations, and Worlds are replaced by name with the newly loaded data, so
+// anything referencing them (Models, BoundingTriangles, AnimationPlayers, Scenes, etc) picks up the changes. Nodes themselves are left
+// alone - nodes aren't added or removed, and their transforms, properties, and data (Node.Data()) are kept as they are, so any changes
+// made to nodes by the game are preserved. Meshes, Materials, Animations, and Worlds that are new in the reloaded file are added to the Library.
+// Textures loaded from the file that are replaced by reloading it are disposed of, so they shouldn't be used elsewhere in the game.
+type LibraryWatcher struct {
+	// PollInterval is how often the watched files are checked for changes. Defaults to one second.
+	PollInterval time.Duration
+	// LoadOptions are the options used to reload the watched files; if nil, the default options are used (see LoadGLTFFile()).
+	LoadOptions *GLTFLoadOptions
+	// Scenes are the live Scenes (i.e. clones of the watched Libraries' Scenes) that should be patched when a Library is reloaded,
+	// in addition to the Libraries' own Scenes.
+	Scenes []*Scene
+	// OnReload is called after a watched file is reloaded, with the path of the file and the Library that was patched. If reloading
+	// failed, err is the error that occurred, and the Library is left as it was.
+	OnReload func(path string, library *Library, err error)
+
+	libraries []*watchedLibrary
+	lastPoll  time.Time
+}
+
+// NewLibraryWatcher creates a new LibraryWatcher.
+func NewLibraryWatcher() *LibraryWatcher {
+	return &LibraryWatcher{
+		PollInterval: time.Second,
+		Scenes:       []*Scene{},
+		libraries:    []*watchedLibrary{},
+	}
+}
+
+// Watch starts watching the GLTF file at the given path, which the provided Library was loaded from. The Library is reloaded and
+// patched when the file changes after Watch() is called.
+func (watcher *LibraryWatcher) Watch(path string, library *Library) error {
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	watcher.libraries = append(watcher.libraries, &watchedLibrary{
+		path:          path,
+		library:       library,
+		loadedModTime: info.ModTime(),
+		loadedSize:    info.Size(),
+		seenModTime:   info.ModTime(),
+		seenSize:      info.Size(),
+		textures:      library.textures(),
+	})
+
+	return nil
+
+}
+
+// Unwatch stops watching the file the provided Library was loaded from.
+func (watcher *LibraryWatcher) Unwatch(library *Library) {
+	for i, watched := range watcher.libraries {
+		if watched.library == library {
+			watcher.libraries = append(watcher.libraries[:i], watcher.libraries[i+1:]...)
+			return
+		}
+	}
+}
+
+// Update checks the watched files for changes once every PollInterval, reloading and patching any Libraries whose files have changed.
+// Update should be called from the main thread each frame (i.e. in your game's Update() function), as reloading creates textures.
+// A file is only reloaded once it has stopped changing between polls, so files that are still being written aren't loaded.
+// Update returns true if any Libraries were reloaded.
+func (watcher *LibraryWatcher) Update() bool {
+
+	if time.Since(watcher.lastPoll) < watcher.PollInterval {
+		return false
+	}
+
+	watcher.lastPoll = time.Now()
+
+	reloaded := false
+
+	for _, watched := range watcher.libraries {
+
+		info, err := os.Stat(watched.path)
+		if err != nil {
+			continue // The file may be in the middle of being replaced
+		}
+
+		modTime, size := info.ModTime(), info.Size()
+
+		stable := modTime.Equal(watched.seenModTime) && size == watched.seenSize
+		watched.seenModTime, watched.seenSize = modTime, size
+
+		if !stable || (modTime.Equal(watched.loadedModTime) && size == watched.loadedSize) {
+			continue
+		}
+
+		watched.loadedModTime, watched.loadedSize = modTime, size
+
+		watcher.reload(watched)
+		reloaded = true
+
+	}
+
+	return reloaded
+
+}
+
+// Reload immediately reloads the file the provided Library was loaded from and patches it, regardless of whether the file has changed.
+func (watcher *LibraryWatcher) Reload(library *Library) error {
+	for _, watched := range watcher.libraries {
+		if watched.library == library {
+			return watcher.reload(watched)
+		}
+	}
+	return nil
+}
+
+func (watcher *LibraryWatcher) reload(watched *watchedLibrary) error {
+
+	loaded, err := LoadGLTFFile(watched.path, watcher.LoadOptions)
+
+	if err == nil {
+
+		for texture := range loaded.textures() {
+			watched.textures[texture] = true
+		}
+
+		watcher.patchLibrary(watched.library, loaded)
+
+		// Textures that are no longer used after patching have been replaced, so they're disposed of, rather than left for the game
+		// to leak each time the file is reloaded.
+		used := watched.library.textures()
+
+		for texture := range watched.textures {
+			if !used[texture] {
+				UnregisterTextureSource(texture)
+				texture.Dispose()
+				delete(watched.textures, texture)
+			}
+		}
+
+	}
+
+	if watcher.OnReload != nil {
+		watcher.OnReload(watched.path, watched.library, err)
+	}
+
+	return err
+
+}
+
+// patchLibrary patches the library in place with the data from the newly loaded Library, updating the Models and BoundingTriangles
+// in the library's Scenes and the watcher's Scenes that use patched Meshes.
+func (watcher *LibraryWatcher) patchLibrary(library, loaded *Library) {
+
+	for name, newMat := range loaded.Materials {
+
+		newMat.library = library
+
+		mat, exists := library.Materials[name]
+		if !exists {
+			library.Materials[name] = newMat
+			continue
+		}
+
+		// Shaders and render targets are set up by the game rather than in the modeler, so they're kept
+		newMat.fragmentShader = mat.fragmentShader
+		newMat.fragmentSrc = mat.fragmentSrc
+		newMat.FragmentShaderOn = mat.FragmentShaderOn
+		newMat.FragmentShaderOptions = mat.FragmentShaderOptions
+		if newMat.RenderTarget == nil {
+			newMat.RenderTarget = mat.RenderTarget
+		}
+
+		keepTexture(&newMat.Texture, mat.Texture, newMat.TexturePath, mat.TexturePath)
+		keepTexture(&newMat.NormalTexture, mat.NormalTexture, newMat.NormalTexturePath, mat.NormalTexturePath)
+		keepTexture(&newMat.SpecularTexture, mat.SpecularTexture, newMat.SpecularTexturePath, mat.SpecularTexturePath)
+		keepTexture(&newMat.EmissiveTexture, mat.EmissiveTexture, newMat.EmissiveTexturePath, mat.EmissiveTexturePath)
+
+		*mat = *newMat
+
+	}
+
+	// The MeshParts the patched Meshes had before patching, to remap Models' dynamic batches
+	patchedMeshes := map[*Mesh][]*MeshPart{}
+
+	for name, newMesh := range loaded.Meshes {
+
+		newMesh.library = library
+
+		mesh, exists := library.Meshes[name]
+		if !exists {
+			mesh = newMesh
+			library.Meshes[name] = mesh
+		} else {
+			patchedMeshes[mesh] = mesh.MeshParts
+			*mesh = *newMesh
+		}
+
+		for _, part := range mesh.MeshParts {
+			part.Mesh = mesh
+			if part.Material != nil {
+				if mat, exists := library.Materials[part.Material.Name]; exists {
+					part.Material = mat
+				}
+			}
+		}
+
+	}
+
+	for name, newAnim := range loaded.Animations {
+
+		newAnim.library = library
+
+		anim, exists := library.Animations[name]
+		if !exists {
+			library.Animations[name] = newAnim
+			continue
+		}
+
+		// Bumping the revision makes any AnimationPlayers playing the Animation reassign its channels
+		newAnim.revision = anim.revision + 1
+		*anim = *newAnim
+
+	}
+
+	for name, newWorld := range loaded.Worlds {
+
+		world, exists := library.Worlds[name]
+		if !exists {
+			library.Worlds[name] = newWorld
+			continue
+		}
+
+		keepTexture(&newWorld.SkyTexture, world.SkyTexture, newWorld.SkyTexturePath, world.SkyTexturePath)
+
+		*world = *newWorld
+
+	}
+
+	// Skinned Models need their bones from the reloaded file, as the bones each vertex is weighted to can change
+	loadedModels := map[string]*Model{}
+	loadedModelsByMesh := map[string]*Model{}
+
+	for _, scene := range loaded.Scenes {
+		for _, node := range scene.Root.ChildrenRecursive() {
+			if model, isModel := node.(*Model); isModel && model.Mesh != nil {
+				loadedModels[model.name] = model
+				if _, exists := loadedModelsByMesh[model.Mesh.Name]; !exists {
+					loadedModelsByMesh[model.Mesh.Name] = model
+				}
+			}
+		}
+	}
+
+	visited := map[*Scene]bool{}
+
+	for _, scene := range append(append([]*Scene{}, library.Scenes...), watcher.Scenes...) {
+
+		if scene == nil || visited[scene] {
+			continue
+		}
+
+		visited[scene] = true
+
+		for _, node := range scene.Root.ChildrenRecursive() {
+
+			switch n := node.(type) {
+
+			case *Model:
+
+				oldParts, patched := patchedMeshes[n.Mesh]
+				if !patched {
+					continue
+				}
+
+				loadedModel, exists := loadedModels[n.name]
+				if !exists || loadedModel.Mesh.Name != n.Mesh.Name {
+					loadedModel = loadedModelsByMesh[n.Mesh.Name]
+				}
+
+				patchModel(n, oldParts, loadedModel)
+
+			case *BoundingTriangles:
+
+				if _, patched := patchedMeshes[n.Mesh]; patched {
+					patchBoundingTriangles(n)
+				}
+
+			}
+
+		}
+
+	}
+
+}
+
+// patchModel updates a Model after its Mesh has been patched. oldParts are the MeshParts the Mesh had before patching, and loadedModel
+// is the corresponding Model from the reloaded file (if there is one), used to reassign bones.
+func patchModel(model *Model, oldParts []*MeshPart, loadedModel *Model) {
+
+	model.skinVectorPool = NewVectorPool(model.Mesh.VertexCount*2, true)
+	model.BoundingSphere.Radius = model.Mesh.Dimensions.MaxSpan() / 2
+
+	for i, part := range oldParts {
+		if batched, exists := model.DynamicBatchModels[part]; exists {
+			delete(model.DynamicBatchModels, part)
+			if i < len(model.Mesh.MeshParts) {
+				model.DynamicBatchModels[model.Mesh.MeshParts[i]] = batched
+			}
+		}
+	}
+
+	if model.SkinRoot != nil {
+
+		if loadedModel == nil || len(loadedModel.bones) == 0 {
+			model.bones = nil
+			model.Skinned = false
+		} else {
+
+			boneMap := map[string]*Node{}
+
+			for _, b := range model.SkinRoot.ChildrenRecursive() {
+				if b.IsBone() {
+					boneMap[b.Name()] = b.(*Node)
+				}
+			}
+
+			bones := make([][]*Node, len(loadedModel.bones))
+
+			for vertexIndex, vertexBones := range loadedModel.bones {
+
+				bones[vertexIndex] = make([]*Node, len(vertexBones))
+
+				for i, loadedBone := range vertexBones {
+
+					bone, exists := boneMap[loadedBone.name]
+					if !exists {
+						log.Println("Warning: skinned Model " + model.Path() + " is weighted to bone " + loadedBone.name + ", which doesn't exist in its armature; disabling skinning until it's reloaded")
+						model.bones = nil
+						model.Skinned = false
+						model.dirtyTransform()
+						return
+					}
+
+					bone.inverseBindMatrix = loadedBone.inverseBindMatrix.Clone()
+					bones[vertexIndex][i] = bone
+
+				}
+
+			}
+
+			model.bones = bones
+			model.Skinned = loadedModel.Skinned
+
+		}
+
+	}
+
+	model.dirtyTransform()
+
+}
+
+// patchBoundingTriangles updates a BoundingTriangles object's bounds and broadphase after its Mesh has been patched.
+func patchBoundingTriangles(bt *BoundingTriangles) {
+
+	margin := 0.25 // Matches the margin in NewBoundingTriangles()
+	bt.BoundingAABB.SetDimensions(bt.Mesh.Dimensions.Width()+margin, bt.Mesh.Dimensions.Height()+margin, bt.Mesh.Dimensions.Depth()+margin)
+
+	if bt.Broadphase != nil {
+		bt.Broadphase.Resize(bt.Broadphase.GridSize)
+	}
+
+	bt.dirtyTransform()
+
+}
+
+// keepTexture keeps the previous texture if the reloaded one couldn't be loaded from the same (external) path.
+func keepTexture(target **ebiten.Image, previous *ebiten.Image, path, previousPath string) {
+	if *target == nil && path != "" && path == previousPath {
+		*target = previous
+	}
+}
@@ -0,0 +1,186 @@
+package tetra3d
+
+import (
+	"image"
+	"path/filepath"
+	"testing"
+
+	"github.com/kvartborg/vector"
+)
+
+func TestLibraryWatcherDisposesReplacedTextures(t *testing.T) {
+
+	mesh := NewCube()
+	mat := mesh.MeshParts[0].Material
+	mat.Texture = newTextureFromImage(image.NewNRGBA(image.Rect(0, 0, 2, 2)), true)
+	defer UnregisterTextureSource(mat.Texture)
+
+	library := NewLibrary()
+	library.Meshes[mesh.Name] = mesh
+	library.Materials[mat.Name] = mat
+	library.AddScene("Scene").Root.AddChildren(NewModel(mesh, "Cube"))
+
+	path := filepath.Join(t.TempDir(), "cube.glb")
+
+	if err := ExportGLTFFile(library, path, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	options := DefaultGLTFLoadOptions()
+	options.KeepTextureSources = true
+
+	loaded, err := LoadGLTFFile(path, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer loaded.UnregisterTextureSources()
+
+	watcher := NewLibraryWatcher()
+	watcher.LoadOptions = options
+
+	if err := watcher.Watch(path, loaded); err != nil {
+		t.Fatal(err)
+	}
+
+	previous := loaded.Materials[mat.Name].Texture
+
+	if err := watcher.Reload(loaded); err != nil {
+		t.Fatal(err)
+	}
+
+	current := loaded.Materials[mat.Name].Texture
+
+	if current == nil || current == previous {
+		t.Fatalf("expected the Material's texture to be replaced by reloading")
+	}
+
+	textureSourcesMutex.Lock()
+	_, previousRegistered := textureSources[previous]
+	_, currentRegistered := textureSources[current]
+	textureSourcesMutex.Unlock()
+
+	if previousRegistered {
+		t.Errorf("the replaced texture's source is still registered")
+	}
+
+	if !currentRegistered {
+		t.Errorf("the reloaded texture's source isn't registered")
+	}
+
+	if watched := watcher.libraries[0].textures; watched[previous] || !watched[current] {
+		t.Errorf("the watcher is tracking the wrong textures")
+	}
+
+}
+
+// exportWatchedLibrary exports a Library with a cube Model, and a Node moved by an Animation from the origin to moveTo, to the given path.
+func exportWatchedLibrary(t *testing.T, path string, cubeSize float64, color *Color, moveTo vector.Vector) {
+
+	t.Helper()
+
+	mesh := NewCube()
+	mesh.MeshParts[0].ApplyMatrix(NewMatrix4Scale(cubeSize, cubeSize, cubeSize))
+	mesh.UpdateBounds()
+	mat := mesh.MeshParts[0].Material
+	mat.Color = color
+
+	anim := NewAnimation("Move")
+	track := anim.AddChannel("Mover").AddTrack(TrackTypePosition)
+	track.AddKeyframe(0, vector.Vector{0, 0, 0})
+	track.AddKeyframe(1, moveTo)
+	anim.Length = 1
+
+	library := NewLibrary()
+	library.Meshes[mesh.Name] = mesh
+	library.Materials[mat.Name] = mat
+	library.Animations[anim.Name] = anim
+	library.AddScene("Scene").Root.AddChildren(NewModel(mesh, "Cube"), NewNode("Mover"))
+
+	if err := ExportGLTFFile(library, path, nil); err != nil {
+		t.Fatal(err)
+	}
+
+}
+
+func TestLibraryWatcherPatchesInPlace(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "scene.glb")
+
+	exportWatchedLibrary(t, path, 1, NewColor(1, 0, 0, 1), vector.Vector{2, 0, 0})
+
+	loaded, err := LoadGLTFFile(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	watcher := NewLibraryWatcher()
+
+	if err := watcher.Watch(path, loaded); err != nil {
+		t.Fatal(err)
+	}
+
+	root := loaded.Scenes[0].Root
+	cube := root.Get("Cube").(*Model)
+	mover := root.Get("Mover")
+
+	mesh := loaded.Meshes[cube.Mesh.Name]
+	mat := mesh.MeshParts[0].Material
+	anim := loaded.Animations["Move"]
+
+	// Changes made by the game to nodes in the Library's Scenes should survive reloading.
+	cube.SetLocalPosition(3, 4, 5)
+	cube.SetData("game data")
+
+	player := NewAnimationPlayer(root)
+	player.Play(anim)
+	// Each update applies the Animation at the playhead before advancing it.
+	player.Update(0.25)
+	player.Update(0.25)
+
+	if position := mover.LocalPosition(); !vectorsEqual(position, vector.Vector{0.5, 0, 0}) {
+		t.Fatalf("expected the Animation to move Mover to {0.5, 0, 0} before reloading, got %v", position)
+	}
+
+	exportWatchedLibrary(t, path, 2, NewColor(0, 0, 1, 1), vector.Vector{0, 4, 0})
+
+	if err := watcher.Reload(loaded); err != nil {
+		t.Fatal(err)
+	}
+
+	if loaded.Meshes[mesh.Name] != mesh || loaded.Materials[mat.Name] != mat || loaded.Animations["Move"] != anim {
+		t.Fatalf("the Library's Meshes, Materials, and Animations should be patched in place rather than replaced")
+	}
+
+	if root.Get("Cube") != cube || cube.Mesh != mesh || mesh.MeshParts[0].Material != mat {
+		t.Fatalf("the Library's nodes should still refer to the patched Mesh and Material")
+	}
+
+	if !vectorsEqual(mesh.Dimensions[1], vector.Vector{2, 2, 2}) {
+		t.Errorf("expected the reloaded Mesh to extend to {2, 2, 2}, got %v", mesh.Dimensions[1])
+	}
+
+	if !colorsEqual(mat.Color, NewColor(0, 0, 1, 1)) {
+		t.Errorf("expected the reloaded Material's color to be (0, 0, 1, 1), got %v", mat.Color)
+	}
+
+	if position := cube.LocalPosition(); !vectorsEqual(position, vector.Vector{3, 4, 5}) {
+		t.Errorf("the Cube's position was reset to %v by reloading", position)
+	}
+
+	if cube.Data() != "game data" {
+		t.Errorf("the Cube's data was reset to %v by reloading", cube.Data())
+	}
+
+	// The running AnimationPlayer picks up the reloaded Animation's channels, continuing from where it was.
+	revision := player.channelsRevision
+	player.Update(0.25)
+
+	if player.channelsRevision == revision || player.channelsRevision != anim.revision {
+		t.Errorf("the AnimationPlayer didn't reassign the reloaded Animation's channels")
+	}
+
+	if position := mover.LocalPosition(); !vectorsEqual(position, vector.Vector{0, 2, 0}) {
+		t.Errorf("expected the reloaded Animation to move Mover to {0, 2, 0}, got %v", position)
+	}
+
+}
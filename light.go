@@ -118,7 +118,6 @@ type PointLight struct {
 
 	distanceSquared float64
 	workingPosition vector.Vector
-	out             [9]float32
 }
 
 // NewPointLight creates a new Point light.
@@ -128,7 +127,6 @@ func NewPointLight(name string, r, g, b, energy float32) *PointLight {
 		Energy: energy,
 		Color:  NewColor(r, g, b, 1),
 		On:     true,
-		out:    [9]float32{},
 	}
 }
 
@@ -190,13 +188,13 @@ func (point *PointLight) Light(triIndex int, model *Model) [9]float32 {
 		triCenter = model.Mesh.Triangles[triIndex].Center
 	}
 
+	// The results are stored in a local array rather than on the light so that triangles can be lit across multiple goroutines.
+	out := [9]float32{}
+
 	dist := fastVectorDistanceSquared(point.workingPosition, triCenter)
 
 	if point.Distance > 0 && dist > point.distanceSquared+model.Mesh.Triangles[triIndex].MaxSpan {
-		for i := 0; i < 9; i++ {
-			point.out[i] = 0
-		}
-		return point.out
+		return out
 	}
 
 	// If you're on the other side of the plane, just assume it's not visible.
@@ -216,8 +214,8 @@ func (point *PointLight) Light(triIndex int, model *Model) [9]float32 {
 			vertNormal = model.Mesh.VertexNormals[triIndex*3+i]
 		}
 
-		lightVec := vector.In(fastVectorSub(point.workingPosition, vertPos)).Unit()
-		diffuse := dot(vertNormal, vector.Vector(lightVec))
+		lightVec := fastVectorSubUnit(point.workingPosition, vertPos)
+		diffuse := dot(vertNormal, lightVec[:])
 
 		if diffuse < 0 {
 			diffuse = 0
@@ -232,13 +230,13 @@ func (point *PointLight) Light(triIndex int, model *Model) [9]float32 {
 			diffuseFactor = diffuse * math.Max(math.Min(1.0-(math.Pow((distance/point.distanceSquared), 4)), 1), 0)
 		}
 
-		point.out[(i * 3)] = point.Color.R * float32(diffuseFactor) * point.Energy
-		point.out[(i*3)+1] = point.Color.G * float32(diffuseFactor) * point.Energy
-		point.out[(i*3)+2] = point.Color.B * float32(diffuseFactor) * point.Energy
+		out[(i * 3)] = point.Color.R * float32(diffuseFactor) * point.Energy
+		out[(i*3)+1] = point.Color.G * float32(diffuseFactor) * point.Energy
+		out[(i*3)+2] = point.Color.B * float32(diffuseFactor) * point.Energy
 
 	}
 
-	return point.out
+	return out
 
 }
 
@@ -281,8 +279,6 @@ type DirectionalLight struct {
 
 	workingForward       vector.Vector // Internal forward vector so we don't have to calculate it for every triangle for every model using this light.
 	workingModelRotation Matrix4       // Similarly, this is an internal rotational transform (without the transformation row) for the Model being lit.
-
-	out [9]float32
 }
 
 // NewDirectionalLight creates a new Directional Light with the specified RGB color and energy (assuming 1.0 energy is standard / "100%" lighting).
@@ -292,7 +288,6 @@ func NewDirectionalLight(name string, r, g, b, energy float32) *DirectionalLight
 		Color:  NewColor(r, g, b, 1),
 		Energy: energy,
 		On:     true,
-		out:    [9]float32{},
 	}
 }
 
@@ -325,6 +320,8 @@ func (sun *DirectionalLight) beginModel(model *Model) {
 // Light returns the R, G, and B values for the DirectionalLight for each vertex of the provided Triangle.
 func (sun *DirectionalLight) Light(triIndex int, model *Model) [9]float32 {
 
+	out := [9]float32{}
+
 	for i := 0; i < 3; i++ {
 
 		var normal vector.Vector
@@ -340,13 +337,13 @@ func (sun *DirectionalLight) Light(triIndex int, model *Model) [9]float32 {
 			diffuseFactor = 0
 		}
 
-		sun.out[i*3] = sun.Color.R * float32(diffuseFactor) * sun.Energy
-		sun.out[i*3+1] = sun.Color.G * float32(diffuseFactor) * sun.Energy
-		sun.out[i*3+2] = sun.Color.B * float32(diffuseFactor) * sun.Energy
+		out[i*3] = sun.Color.R * float32(diffuseFactor) * sun.Energy
+		out[i*3+1] = sun.Color.G * float32(diffuseFactor) * sun.Energy
+		out[i*3+2] = sun.Color.B * float32(diffuseFactor) * sun.Energy
 
 	}
 
-	return sun.out
+	return out
 
 }
 
@@ -376,6 +373,203 @@ func (sun *DirectionalLight) Type() NodeType {
 	return NodeTypeDirectionalLight
 }
 
+//---------------//
+
+// SpotLight represents a light that shines in a cone from its position, facing down its local -Z axis (like a flashlight or stage light).
+type SpotLight struct {
+	*Node
+	// Distance represents the distance after which the light fully attenuates. If this is 0 (the default),
+	// it falls off using something akin to the inverse square law.
+	Distance float64
+	// Color is the color of the SpotLight.
+	Color *Color
+	// Energy is the overall energy of the Light, with 1.0 being full brightness. Internally, technically there's no
+	// difference between a brighter color and a higher energy, but this is here for convenience / adherance to the
+	// GLTF spec and 3D modelers.
+	Energy float32
+	// If the light is on and contributing to the scene.
+	On bool
+	// InnerConeAngle is the angle (in radians) from the center of the cone at which the light starts to fall off.
+	// Triangles within the inner cone are fully lit.
+	InnerConeAngle float64
+	// OuterConeAngle is the angle (in radians) from the center of the cone at which the light fully falls off.
+	// Triangles outside of the outer cone are unlit. The default is Pi / 4 (45 degrees), which matches the GLTF spec.
+	OuterConeAngle float64
+
+	distanceSquared float64
+	cosInner        float64
+	cosOuter        float64
+	workingPosition vector.Vector
+	workingForward  vector.Vector
+}
+
+// NewSpotLight creates a new SpotLight with the specified RGB color and energy (assuming 1.0 energy is standard / "100%" lighting).
+func NewSpotLight(name string, r, g, b, energy float32) *SpotLight {
+	return &SpotLight{
+		Node:           NewNode(name),
+		Energy:         energy,
+		Color:          NewColor(r, g, b, 1),
+		On:             true,
+		InnerConeAngle: 0,
+		OuterConeAngle: math.Pi / 4,
+	}
+}
+
+// Clone returns a new clone of the given SpotLight.
+func (spot *SpotLight) Clone() INode {
+
+	clone := NewSpotLight(spot.name, spot.Color.R, spot.Color.G, spot.Color.B, spot.Energy)
+	clone.On = spot.On
+	clone.Distance = spot.Distance
+	clone.InnerConeAngle = spot.InnerConeAngle
+	clone.OuterConeAngle = spot.OuterConeAngle
+
+	clone.Node = spot.Node.Clone().(*Node)
+	for _, child := range spot.children {
+		child.setParent(clone)
+	}
+
+	return clone
+
+}
+
+func (spot *SpotLight) beginRender() {
+	spot.distanceSquared = spot.Distance * spot.Distance
+	spot.cosInner = math.Cos(spot.InnerConeAngle)
+	spot.cosOuter = math.Cos(spot.OuterConeAngle)
+}
+
+func (spot *SpotLight) beginModel(model *Model) {
+
+	p, _, r := model.Transform().Inverted().Decompose()
+
+	// Like with the PointLight, we transform the light's position (and direction, in this case) by the inversion of the model's
+	// transform rather than transforming all of the vertices of the model.
+
+	// Forward() points towards +Z, and the SpotLight shines down -Z, so this is the vector pointing back towards the light.
+	forward := spot.WorldRotation().Forward()
+
+	if model.Skinned {
+		spot.workingPosition = spot.WorldPosition()
+		spot.workingForward = forward
+	} else {
+		spot.workingPosition = r.MultVec(spot.WorldPosition()).Add(p)
+		spot.workingForward = r.MultVec(forward).Unit()
+	}
+
+}
+
+// Light returns the R, G, and B values for the SpotLight for all vertices of a given Triangle.
+func (spot *SpotLight) Light(triIndex int, model *Model) [9]float32 {
+
+	out := [9]float32{}
+
+	var triCenter vector.Vector
+
+	if model.Skinned {
+		v0 := model.Mesh.vertexSkinnedPositions[triIndex*3].Clone()
+		v1 := model.Mesh.vertexSkinnedPositions[triIndex*3+1]
+		v2 := model.Mesh.vertexSkinnedPositions[triIndex*3+2]
+		triCenter = vector.Vector(vector.In(v0).Add(v1).Add(v2).Scale(1.0 / 3.0))
+	} else {
+		triCenter = model.Mesh.Triangles[triIndex].Center
+	}
+
+	if spot.Distance > 0 && fastVectorDistanceSquared(spot.workingPosition, triCenter) > spot.distanceSquared+model.Mesh.Triangles[triIndex].MaxSpan {
+		return out
+	}
+
+	var vertPos, vertNormal vector.Vector
+
+	for i := 0; i < 3; i++ {
+
+		if model.Skinned {
+			vertPos = model.Mesh.vertexSkinnedPositions[triIndex*3+i]
+			vertNormal = model.Mesh.vertexSkinnedNormals[triIndex*3+i]
+		} else {
+			vertPos = model.Mesh.VertexPositions[triIndex*3+i]
+			vertNormal = model.Mesh.VertexNormals[triIndex*3+i]
+		}
+
+		lightVec := fastVectorSubUnit(spot.workingPosition, vertPos)
+
+		// The cone factor is 1 inside of the inner cone, 0 outside of the outer cone, and smoothly interpolated in-between.
+		cone := spot.coneFactor(dot(lightVec[:], spot.workingForward))
+
+		if cone <= 0 {
+			continue
+		}
+
+		diffuse := dot(vertNormal, lightVec[:])
+
+		if diffuse < 0 {
+			diffuse = 0
+		}
+
+		var diffuseFactor float64
+		distance := fastVectorDistanceSquared(spot.workingPosition, vertPos)
+
+		if spot.Distance == 0 {
+			diffuseFactor = diffuse * (1.0 / (1.0 + (0.1 * distance))) * 2
+		} else {
+			diffuseFactor = diffuse * math.Max(math.Min(1.0-(math.Pow((distance/spot.distanceSquared), 4)), 1), 0)
+		}
+
+		diffuseFactor *= cone
+
+		out[(i * 3)] = spot.Color.R * float32(diffuseFactor) * spot.Energy
+		out[(i*3)+1] = spot.Color.G * float32(diffuseFactor) * spot.Energy
+		out[(i*3)+2] = spot.Color.B * float32(diffuseFactor) * spot.Energy
+
+	}
+
+	return out
+
+}
+
+// coneFactor returns how much a position is lit by the SpotLight's cone, given the cosine of the angle between the
+// cone's center and the direction from the position to the light.
+func (spot *SpotLight) coneFactor(cosAngle float64) float64 {
+
+	if cosAngle <= spot.cosOuter {
+		return 0
+	}
+
+	if cosAngle >= spot.cosInner || spot.cosInner <= spot.cosOuter {
+		return 1
+	}
+
+	t := (cosAngle - spot.cosOuter) / (spot.cosInner - spot.cosOuter)
+	return t * t * (3 - 2*t)
+
+}
+
+// AddChildren parents the provided children Nodes to the passed parent Node, inheriting its transformations and being under it in the scenegraph
+// hierarchy. If the children are already parented to other Nodes, they are unparented before doing so.
+func (spot *SpotLight) AddChildren(children ...INode) {
+	spot.addChildren(spot, children...)
+}
+
+// Unparent unparents the SpotLight from its parent, removing it from the scenegraph.
+func (spot *SpotLight) Unparent() {
+	if spot.parent != nil {
+		spot.parent.RemoveChildren(spot)
+	}
+}
+
+func (spot *SpotLight) IsOn() bool {
+	return spot.On && spot.Energy > 0
+}
+
+func (spot *SpotLight) SetOn(on bool) {
+	spot.On = on
+}
+
+// Type returns the NodeType for this object.
+func (spot *SpotLight) Type() NodeType {
+	return NodeTypeSpotLight
+}
+
 // CubeLight represents an AABB volume that lights triangles.
 type CubeLight struct {
 	*Node
@@ -392,8 +586,6 @@ type CubeLight struct {
 	workingPosition        vector.Vector
 	workingAngle           vector.Vector
 	workingDistanceSquared float64
-
-	out [9]float32
 }
 
 // NewCubeLight creates a new CubeLight with the given dimensions.
@@ -407,7 +599,6 @@ func NewCubeLight(name string, dimensions Dimensions) *CubeLight {
 		Color:         NewColor(1, 1, 1, 1),
 		On:            true,
 		LightingAngle: vector.Vector{0, -1, 0},
-		out:           [9]float32{},
 	}
 	return cube
 }
@@ -570,9 +761,7 @@ func (cube *CubeLight) Light(triIndex int, model *Model) [9]float32 {
 
 	var vertPos, vertNormal vector.Vector
 
-	for i := 0; i < 9; i++ {
-		cube.out[i] = 0
-	}
+	out := [9]float32{}
 
 	for i := 0; i < 3; i++ {
 
@@ -623,13 +812,13 @@ func (cube *CubeLight) Light(triIndex int, model *Model) [9]float32 {
 			continue
 		}
 
-		cube.out[(i * 3)] = cube.Color.R * float32(diffuseFactor) * cube.Energy
-		cube.out[(i*3)+1] = cube.Color.G * float32(diffuseFactor) * cube.Energy
-		cube.out[(i*3)+2] = cube.Color.B * float32(diffuseFactor) * cube.Energy
+		out[(i * 3)] = cube.Color.R * float32(diffuseFactor) * cube.Energy
+		out[(i*3)+1] = cube.Color.G * float32(diffuseFactor) * cube.Energy
+		out[(i*3)+2] = cube.Color.B * float32(diffuseFactor) * cube.Energy
 
 	}
 
-	return cube.out
+	return out
 
 }
 
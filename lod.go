@@ -0,0 +1,137 @@
+package tetra3d
+
+import "math"
+
+const (
+	LODModeDistance   = iota // LOD levels are chosen by the distance from the Camera to the Model.
+	LODModeScreenSize        // LOD levels are chosen by the Model's size on screen (the height of its bounding sphere relative to the screen's height).
+)
+
+// LODLevel is a level of detail for a Model - a Mesh, and the threshold at which the Mesh starts being used.
+type LODLevel struct {
+	Mesh *Mesh
+	// Threshold is the distance from the Camera at or beyond which the level is used for LODModeDistance, or the size on screen (with 1 being
+	// the full height of the screen) at or below which the level is used for LODModeScreenSize.
+	Threshold float64
+}
+
+// LODGroup is a group of Meshes at varying levels of detail for a Model; when a Camera renders the Model, it picks the Mesh to render
+// according to the LODGroup's Levels. Note that skinned Models don't switch levels, as their skinning data is tied to their original Mesh.
+//
+// The chosen level is set as the Model's Mesh, and the LODGroup keeps a single current level (which the Hysteresis margin is relative to).
+// Because of this, when the same Model is rendered by multiple Cameras (including Cameras rendering RenderTargets), each Camera chooses
+// the level again, overwriting the last Camera's choice, and the Model's Mesh is left as the level chosen by the last Camera to render it.
+// The Hysteresis margin also only works as expected when one Camera renders the Model.
+type LODGroup struct {
+	Mode   int         // The LOD mode, used to determine how to choose a level (LODModeDistance or LODModeScreenSize).
+	Levels []*LODLevel // The levels of detail, ordered from most to least detailed. The first level is used when no other level's Threshold is met.
+
+	// Hysteresis is a margin (in the units of the Levels' Thresholds) that the Model has to pass a Threshold by to switch levels; this keeps
+	// Models near a Threshold from switching back and forth between levels constantly. Defaults to 0.
+	Hysteresis float64
+
+	current int
+}
+
+// NewLODGroup creates a new, empty LODGroup that uses the given LOD mode (LODModeDistance or LODModeScreenSize).
+func NewLODGroup(mode int) *LODGroup {
+	return &LODGroup{
+		Mode:   mode,
+		Levels: []*LODLevel{},
+	}
+}
+
+// Clone clones the LODGroup. The Meshes referenced by the Levels are not cloned.
+func (lod *LODGroup) Clone() *LODGroup {
+	newLOD := NewLODGroup(lod.Mode)
+	for _, level := range lod.Levels {
+		newLOD.Levels = append(newLOD.Levels, &LODLevel{Mesh: level.Mesh, Threshold: level.Threshold})
+	}
+	newLOD.Hysteresis = lod.Hysteresis
+	newLOD.current = lod.current
+	return newLOD
+}
+
+// AddLevel adds a level of detail to the LODGroup using the Mesh and threshold provided, and returns the LODGroup for chaining.
+// Levels should be added in order from most detailed to least detailed.
+func (lod *LODGroup) AddLevel(mesh *Mesh, threshold float64) *LODGroup {
+	lod.Levels = append(lod.Levels, &LODLevel{Mesh: mesh, Threshold: threshold})
+	return lod
+}
+
+// CurrentLevel returns the index of the level of detail last chosen for rendering.
+func (lod *LODGroup) CurrentLevel() int {
+	return lod.current
+}
+
+// choose chooses a level of detail for the given measurement (either a distance or a screen size, depending on the LODGroup's Mode),
+// sets it as the current level, and returns it.
+func (lod *LODGroup) choose(measurement float64) *LODLevel {
+
+	if len(lod.Levels) == 0 {
+		return nil
+	}
+
+	chosen := 0
+
+	for i := 1; i < len(lod.Levels); i++ {
+
+		// Switching to a less detailed level requires passing its threshold by the hysteresis margin; switching back to a more detailed
+		// level requires passing back the other way by the same margin.
+		margin := lod.Hysteresis
+		if i <= lod.current {
+			margin = -margin
+		}
+
+		if lod.Mode == LODModeScreenSize {
+			if measurement <= lod.Levels[i].Threshold-margin {
+				chosen = i
+			}
+		} else if measurement >= lod.Levels[i].Threshold+margin {
+			chosen = i
+		}
+
+	}
+
+	lod.current = chosen
+
+	return lod.Levels[chosen]
+
+}
+
+// updateLOD sets the Model's Mesh to the level of detail appropriate for rendering it through the given Camera. This overwrites the
+// level chosen by any other Camera that rendered the Model before (see LODGroup).
+func (camera *Camera) updateLOD(model *Model) {
+
+	if model.LOD == nil || model.Skinned {
+		return
+	}
+
+	center := model.BoundingSphere.WorldPosition()
+	distance := center.Sub(camera.WorldPosition()).Magnitude()
+
+	measurement := distance
+
+	if model.LOD.Mode == LODModeScreenSize {
+
+		w, h := camera.resultColorTexture.Size()
+		aspectRatio := float64(h) / float64(w)
+		radius := model.BoundingSphere.WorldRadius()
+
+		if camera.Perspective {
+			if distance <= radius {
+				measurement = math.Inf(1)
+			} else {
+				measurement = radius / (distance * math.Tan(camera.FieldOfView/2*math.Pi/180))
+			}
+		} else {
+			measurement = radius / (camera.OrthoScale * aspectRatio)
+		}
+
+	}
+
+	if level := model.LOD.choose(measurement); level != nil && level.Mesh != nil {
+		model.Mesh = level.Mesh
+	}
+
+}
@@ -0,0 +1,161 @@
+package tetra3d
+
+import (
+	"math"
+	"testing"
+)
+
+func TestLODGroupChoose(t *testing.T) {
+
+	distance := NewLODGroup(LODModeDistance)
+	distance.AddLevel(nil, 0).AddLevel(nil, 10).AddLevel(nil, 20)
+	distance.Hysteresis = 2
+
+	screenSize := NewLODGroup(LODModeScreenSize)
+	screenSize.AddLevel(nil, 0).AddLevel(nil, 0.5).AddLevel(nil, 0.2)
+	screenSize.Hysteresis = 0.05
+
+	tests := []struct {
+		name        string
+		lod         *LODGroup
+		current     int
+		measurement float64
+		expected    int
+	}{
+		{"distance: nearest", distance, 0, 5, 0},
+		{"distance: within the margin past a threshold", distance, 0, 11, 0},
+		{"distance: past a threshold by the margin", distance, 0, 12.5, 1},
+		{"distance: within the margin back from a threshold", distance, 1, 9, 1},
+		{"distance: back past a threshold by the margin", distance, 1, 7.5, 0},
+		{"distance: within the margin past the next threshold", distance, 1, 21, 1},
+		{"distance: past the next threshold by the margin", distance, 1, 25, 2},
+		{"distance: within the margin back from the last threshold", distance, 2, 19, 2},
+		{"distance: back past the last threshold by the margin", distance, 2, 17, 1},
+		{"distance: skipping levels", distance, 0, 30, 2},
+		{"screen size: largest", screenSize, 0, 0.8, 0},
+		{"screen size: within the margin below a threshold", screenSize, 0, 0.47, 0},
+		{"screen size: below a threshold by the margin", screenSize, 0, 0.44, 1},
+		{"screen size: within the margin back above a threshold", screenSize, 1, 0.53, 1},
+		{"screen size: back above a threshold by the margin", screenSize, 1, 0.56, 0},
+		{"screen size: below the next threshold by the margin", screenSize, 1, 0.1, 2},
+		{"screen size: within the margin back above the last threshold", screenSize, 2, 0.24, 2},
+		{"screen size: back above the last threshold by the margin", screenSize, 2, 0.26, 1},
+	}
+
+	for _, test := range tests {
+
+		test.lod.current = test.current
+
+		if level := test.lod.choose(test.measurement); level != test.lod.Levels[test.expected] || test.lod.CurrentLevel() != test.expected {
+			t.Errorf("%s: going from level %d with a measurement of %f chose level %d, expected %d",
+				test.name, test.current, test.measurement, test.lod.CurrentLevel(), test.expected)
+		}
+
+	}
+
+	if NewLODGroup(LODModeDistance).choose(5) != nil {
+		t.Errorf("an LODGroup without levels should choose nothing")
+	}
+
+}
+
+func TestCameraUpdateLOD(t *testing.T) {
+
+	meshes := []*Mesh{NewCube(), NewCube(), NewCube()}
+
+	newLODModel := func(mode int, thresholds ...float64) *Model {
+		model := NewModel(meshes[0], "Model")
+		model.LOD = NewLODGroup(mode)
+		for i, threshold := range thresholds {
+			model.LOD.AddLevel(meshes[i], threshold)
+		}
+		return model
+	}
+
+	perspective := NewCamera(100, 50)
+
+	orthographic := NewCamera(100, 50)
+	orthographic.SetOrthographic(10)
+
+	radius := NewModel(NewCube(), "Cube").BoundingSphere.WorldRadius()
+	tanHalfFOV := math.Tan(perspective.FieldOfView / 2 * math.Pi / 180)
+
+	// The distance from the Camera at which the Model's bounding sphere is the given size on screen.
+	distanceForSize := func(size float64) float64 {
+		return radius / (size * tanHalfFOV)
+	}
+
+	tests := []struct {
+		name     string
+		camera   *Camera
+		model    *Model
+		distance float64
+		scale    float64 // The orthographic Camera's OrthoScale
+		expected int
+	}{
+		{"distance: near", perspective, newLODModel(LODModeDistance, 0, 10, 20), 5, 0, 0},
+		{"distance: middle", perspective, newLODModel(LODModeDistance, 0, 10, 20), 15, 0, 1},
+		{"distance: far", perspective, newLODModel(LODModeDistance, 0, 10, 20), 25, 0, 2},
+		{"distance: orthographic", orthographic, newLODModel(LODModeDistance, 0, 10, 20), 15, 10, 1},
+		{"screen size: inside the bounding sphere", perspective, newLODModel(LODModeScreenSize, 0, 0.5, 0.2), radius / 2, 0, 0},
+		{"screen size: large", perspective, newLODModel(LODModeScreenSize, 0, 0.5, 0.2), distanceForSize(0.8), 0, 0},
+		{"screen size: medium", perspective, newLODModel(LODModeScreenSize, 0, 0.5, 0.2), distanceForSize(0.3), 0, 1},
+		{"screen size: small", perspective, newLODModel(LODModeScreenSize, 0, 0.5, 0.2), distanceForSize(0.1), 0, 2},
+		// Orthographic sizes don't depend on the distance, but on the scale and the aspect ratio (0.5 for a 100x50 Camera).
+		{"screen size: orthographic large", orthographic, newLODModel(LODModeScreenSize, 0, 0.5, 0.2), 100, radius / (0.8 * 0.5), 0},
+		{"screen size: orthographic small", orthographic, newLODModel(LODModeScreenSize, 0, 0.5, 0.2), 5, radius / (0.1 * 0.5), 2},
+	}
+
+	for _, test := range tests {
+
+		test.camera.SetLocalPosition(0, 0, test.distance)
+		if !test.camera.Perspective {
+			test.camera.OrthoScale = test.scale
+		}
+
+		test.camera.updateLOD(test.model)
+
+		if test.model.Mesh != meshes[test.expected] || test.model.LOD.CurrentLevel() != test.expected {
+			t.Errorf("%s: chose level %d, expected %d", test.name, test.model.LOD.CurrentLevel(), test.expected)
+		}
+
+	}
+
+	// Moving back towards the Model, the less detailed level is kept until the Camera is within the threshold by the hysteresis margin.
+	model := newLODModel(LODModeDistance, 0, 10)
+	model.LOD.Hysteresis = 2
+
+	for _, step := range []struct {
+		distance float64
+		expected int
+	}{{11, 0}, {13, 1}, {9, 1}, {7, 0}} {
+		perspective.SetLocalPosition(0, 0, step.distance)
+		perspective.updateLOD(model)
+		if model.LOD.CurrentLevel() != step.expected {
+			t.Errorf("hysteresis: at a distance of %f, chose level %d, expected %d", step.distance, model.LOD.CurrentLevel(), step.expected)
+		}
+	}
+
+	// Each Camera rendering a Model sets its Mesh, so the last Camera to render it decides which level it's left with.
+	near, far := NewCamera(100, 50), NewCamera(100, 50)
+	near.SetLocalPosition(0, 0, 5)
+	far.SetLocalPosition(0, 0, 25)
+
+	model = newLODModel(LODModeDistance, 0, 10, 20)
+	near.updateLOD(model)
+	far.updateLOD(model)
+
+	if model.Mesh != meshes[2] {
+		t.Errorf("expected the Model to be left with the level chosen by the last Camera")
+	}
+
+	// Skinned Models keep their Mesh.
+	model = newLODModel(LODModeDistance, 0, 10, 20)
+	model.Skinned = true
+	far.updateLOD(model)
+
+	if model.Mesh != meshes[0] {
+		t.Errorf("a skinned Model's Mesh shouldn't be switched")
+	}
+
+}
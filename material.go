@@ -30,6 +30,15 @@ const (
 	BillboardModeAll  // Billboards on all axes
 )
 
+const (
+	// LightingModeVertex means lighting is calculated on the CPU for each vertex and interpolated across triangles. This is the default.
+	LightingModeVertex = iota
+
+	// LightingModePixel means point, spot, and directional lights are calculated for each fragment on the GPU, which shades
+	// smoothly even on low-poly meshes. Up to 8 lights are lit per-pixel; any others (and all CubeLights) fall back to vertex lighting.
+	LightingModePixel
+)
+
 type Material struct {
 	library           *Library             // library is a reference to the Library that this Material came from.
 	Name              string               // Name is the name of the Material.
@@ -42,8 +51,23 @@ type Material struct {
 	BackfaceCulling   bool                 // If backface culling is enabled (which it is by default), faces turned away from the camera aren't rendered.
 	TriangleSortMode  int                  // TriangleSortMode influences how triangles with this Material are sorted.
 	Shadeless         bool                 // If the material should be shadeless (unlit) or not
+	Fogless           bool                 // If the material should be unaffected by the World's fog
 	CompositeMode     ebiten.CompositeMode // Blend mode to use when rendering the material (i.e. additive, multiplicative, etc)
 	BillboardMode     int                  // Billboard mode
+	RenderTarget      *RenderTarget        // If set, the Material's Texture is set to the view of the RenderTarget's Camera when rendered.
+	LightingMode      int                  // LightingMode indicates whether lighting is calculated per-vertex or per-pixel; defaults to LightingModeVertex.
+
+	// The textures below are only used when the Material's LightingMode is set to LightingModePixel.
+
+	NormalTexture       *ebiten.Image // The tangent-space normal map applied to the Material.
+	NormalTexturePath   string        // The path to the normal map, if it was not packed into the exporter.
+	SpecularTexture     *ebiten.Image // The specular texture applied to the Material; roughness is read from the green channel, and metalness from the blue channel (like GLTF's metallic-roughness textures).
+	SpecularTexturePath string        // The path to the specular texture, if it was not packed into the exporter.
+	Roughness           float32       // How rough the Material is, multiplied by the SpecularTexture. 0 gives small, sharp highlights, while 1 (the default) gives no highlights.
+	Metallic            float32       // How metallic the Material is, multiplied by the SpecularTexture. Metallic highlights are tinted by the Material's color. Defaults to 0.
+	EmissiveTexture     *ebiten.Image // The emissive texture applied to the Material, multiplied by EmissiveColor.
+	EmissiveTexturePath string        // The path to the emissive texture, if it was not packed into the exporter.
+	EmissiveColor       *Color        // The color the Material emits, regardless of lighting. Defaults to black (no emission).
 
 	// fragmentShader represents a shader used to render the material with. This shader is activated after rendering
 	// to the depth texture, but before compositing the finished render to the screen after fog.
@@ -75,6 +99,8 @@ func NewMaterial(name string) *Material {
 		FragmentShaderOptions: &ebiten.DrawTrianglesShaderOptions{},
 		FragmentShaderOn:      true,
 		CompositeMode:         ebiten.CompositeModeSourceOver,
+		Roughness:             1,
+		EmissiveColor:         NewColor(0, 0, 0, 1),
 	}
 }
 
@@ -88,12 +114,24 @@ func (material *Material) Clone() *Material {
 	newMat.BackfaceCulling = material.BackfaceCulling
 	newMat.TriangleSortMode = material.TriangleSortMode
 	newMat.Shadeless = material.Shadeless
+	newMat.Fogless = material.Fogless
 	newMat.TransparencyMode = material.TransparencyMode
 	newMat.TextureFilterMode = material.TextureFilterMode
 	newMat.TextureWrapMode = material.TextureWrapMode
 	newMat.CompositeMode = material.CompositeMode
 
 	newMat.BillboardMode = material.BillboardMode
+	newMat.RenderTarget = material.RenderTarget
+	newMat.LightingMode = material.LightingMode
+	newMat.NormalTexture = material.NormalTexture
+	newMat.NormalTexturePath = material.NormalTexturePath
+	newMat.SpecularTexture = material.SpecularTexture
+	newMat.SpecularTexturePath = material.SpecularTexturePath
+	newMat.Roughness = material.Roughness
+	newMat.Metallic = material.Metallic
+	newMat.EmissiveTexture = material.EmissiveTexture
+	newMat.EmissiveTexturePath = material.EmissiveTexturePath
+	newMat.EmissiveColor = material.EmissiveColor.Clone()
 	newMat.SetShader(material.fragmentSrc)
 	newMat.FragmentShaderOn = material.FragmentShaderOn
 
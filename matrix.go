@@ -124,10 +124,10 @@ func NewMatrix4Rotate(x, y, z, angle float64) Matrix4 {
 // ToQuaternion returns a Quaternion representative of the Matrix4's rotation (assuming it is just a purely rotational Matrix4).
 func (matrix Matrix4) ToQuaternion() *Quaternion {
 
-	sqrt := math.Sqrt(1 + matrix[0][0] + matrix[1][1] + matrix[2][2])
+	trace := matrix[0][0] + matrix[1][1] + matrix[2][2]
 
-	if sqrt != 0 {
-		qw := sqrt / 2
+	if trace > 0 {
+		qw := math.Sqrt(1+trace) / 2
 
 		return NewQuaternion(
 			(matrix[1][2]-matrix[2][1])/(4*qw),
@@ -138,7 +138,35 @@ func (matrix Matrix4) ToQuaternion() *Quaternion {
 
 	}
 
-	return NewQuaternion(0, 1, 0, 0)
+	// When the trace isn't positive, we use the largest diagonal component to avoid dividing by a number near 0.
+
+	if matrix[0][0] > matrix[1][1] && matrix[0][0] > matrix[2][2] {
+		s := math.Sqrt(1+matrix[0][0]-matrix[1][1]-matrix[2][2]) * 2
+		return NewQuaternion(
+			s/4,
+			(matrix[1][0]+matrix[0][1])/s,
+			(matrix[2][0]+matrix[0][2])/s,
+			(matrix[1][2]-matrix[2][1])/s,
+		)
+	}
+
+	if matrix[1][1] > matrix[2][2] {
+		s := math.Sqrt(1+matrix[1][1]-matrix[0][0]-matrix[2][2]) * 2
+		return NewQuaternion(
+			(matrix[1][0]+matrix[0][1])/s,
+			s/4,
+			(matrix[2][1]+matrix[1][2])/s,
+			(matrix[2][0]-matrix[0][2])/s,
+		)
+	}
+
+	s := math.Sqrt(1+matrix[2][2]-matrix[0][0]-matrix[1][1]) * 2
+	return NewQuaternion(
+		(matrix[2][0]+matrix[0][2])/s,
+		(matrix[2][1]+matrix[1][2])/s,
+		s/4,
+		(matrix[0][1]-matrix[1][0])/s,
+	)
 
 }
 
@@ -1011,6 +1011,43 @@ func calculateNormal(p1, p2, p3 vector.Vector) vector.Vector {
 
 }
 
+// triangleTangents calculates the tangent and bitangent of the triangle with the given ID using the vertex positions provided and the Mesh's UV values.
+// They're zero-length if the triangle's UV values are degenerate.
+func triangleTangents(mesh *Mesh, triID int, positions []vector.Vector) (vector.Vector, vector.Vector) {
+
+	p0, p1, p2 := positions[triID*3], positions[triID*3+1], positions[triID*3+2]
+	uv0, uv1, uv2 := mesh.VertexUVs[triID*3], mesh.VertexUVs[triID*3+1], mesh.VertexUVs[triID*3+2]
+
+	e1 := p1.Sub(p0)
+	e2 := p2.Sub(p0)
+
+	du1, dv1 := uv1[0]-uv0[0], uv1[1]-uv0[1]
+	du2, dv2 := uv2[0]-uv0[0], uv2[1]-uv0[1]
+
+	det := du1*dv2 - du2*dv1
+
+	if det == 0 {
+		return vector.Vector{0, 0, 0}, vector.Vector{0, 0, 0}
+	}
+
+	r := 1 / det
+
+	tangent := vector.Vector{
+		(e1[0]*dv2 - e2[0]*dv1) * r,
+		(e1[1]*dv2 - e2[1]*dv1) * r,
+		(e1[2]*dv2 - e2[2]*dv1) * r,
+	}
+
+	bitangent := vector.Vector{
+		(e2[0]*du1 - e1[0]*du2) * r,
+		(e2[1]*du1 - e1[1]*du2) * r,
+		(e2[2]*du1 - e1[2]*du2) * r,
+	}
+
+	return tangent.Unit(), bitangent.Unit()
+
+}
+
 // MeshPart represents a collection of vertices and triangles, which are all rendered at once, as a single part, with a single material.
 // Depth testing is done between mesh parts or objects, so splitting an object up into different materials can be effective to help with depth sorting.
 type MeshPart struct {
@@ -10,6 +10,17 @@ import (
 	"github.com/kvartborg/vector"
 )
 
+const (
+	RenderLayerDefault uint32 = 1          // The default render layer (layer 0), which Models are on when created.
+	RenderLayerAll     uint32 = 0xFFFFFFFF // All render layers; Cameras render all layers by default.
+)
+
+// RenderLayer returns the bitmask for the render layer of the given index (from 0 to 31), for use with Model.RenderLayers and
+// Camera.CullingMask. Bitmasks for multiple layers can be combined using bitwise OR (e.g. RenderLayer(0) | RenderLayer(2)).
+func RenderLayer(index int) uint32 {
+	return 1 << uint(index)
+}
+
 // Model represents a singular visual instantiation of a Mesh. A Mesh contains the vertex information (what to draw); a Model references the Mesh to draw it with a specific
 // Position, Rotation, and/or Scale (where and how to draw).
 type Model struct {
@@ -23,9 +34,8 @@ type Model struct {
 	DynamicBatchModels map[*MeshPart][]*Model // Models that are dynamically merged into this one.
 	DynamicBatchOwner  *Model
 
-	Skinned        bool  // If the model is skinned and this is enabled, the model will tranform its vertices to match the skinning armature (Model.SkinRoot).
-	SkinRoot       INode // The root node of the armature skinning this Model.
-	skinMatrix     Matrix4
+	Skinned        bool      // If the model is skinned and this is enabled, the model will tranform its vertices to match the skinning armature (Model.SkinRoot).
+	SkinRoot       INode     // The root node of the armature skinning this Model.
 	bones          [][]*Node // The bones (nodes) of the Model, assuming it has been skinned. A Mesh's bones slice will point to indices indicating bones in the Model.
 	skinVectorPool *VectorPool
 
@@ -33,6 +43,22 @@ type Model struct {
 	// If a Model has no LightGroup, the Model is lit by the lights present in the Scene.
 	LightGroup *LightGroup
 
+	// LOD is the Model's group of levels of detail; if set, Cameras switch the Model's Mesh between the LODGroup's Levels when rendering it.
+	// If LOD is nil (the default), the Model's Mesh is left as-is. Note that the Mesh is set by whichever Camera rendered the Model last.
+	LOD *LODGroup
+
+	// RenderLayers is a bitmask of the render layers the Model is on; a Camera only renders the Model if it's on at least one of the layers
+	// in the Camera's CullingMask. Defaults to RenderLayerDefault.
+	RenderLayers uint32
+
+	// Instances are copies of the Model's Mesh, each with its own transform (relative to the Model) and color. If a Model has any Instances,
+	// its Mesh is rendered once for each visible Instance rather than once for the Model itself, with the Instances batched together into as
+	// few draw calls as possible (except for MeshParts using LightingModePixel, which are drawn once per Instance). If the Model has
+	// FrustumCulling on, each Instance is culled separately. Note that Instances of skinned Models aren't supported, as skinned vertices
+	// follow their bones rather than the Model's transform.
+	Instances     []*Instance
+	instanceProxy *Model // The Model each of the Instances is rendered through; see instanceRenderer.
+
 	// VertexTransformFunction is a function that runs on the world position of each vertex position rendered with the material.
 	// It accepts the vertex position as an argument, along with the index of the vertex in the mesh.
 	// One can use this to simply transform vertices of the mesh on CPU (note that this is, of course, not as performant as
@@ -56,8 +82,8 @@ func NewModel(mesh *Mesh, name string) *Model {
 		Mesh:               mesh,
 		FrustumCulling:     true,
 		Color:              NewColor(1, 1, 1, 1),
-		skinMatrix:         NewMatrix4(),
 		DynamicBatchModels: map[*MeshPart][]*Model{},
+		RenderLayers:       RenderLayerDefault,
 	}
 
 	model.Node.onTransformUpdate = model.TransformUpdate
@@ -83,6 +109,7 @@ func (model *Model) Clone() INode {
 	newModel.FrustumCulling = model.FrustumCulling
 	newModel.visible = model.visible
 	newModel.Color = model.Color.Clone()
+	newModel.RenderLayers = model.RenderLayers
 
 	for k := range model.DynamicBatchModels {
 		newModel.DynamicBatchModels[k] = append([]*Model{}, model.DynamicBatchModels[k]...)
@@ -108,6 +135,14 @@ func (model *Model) Clone() INode {
 		newModel.LightGroup = model.LightGroup.Clone()
 	}
 
+	if model.LOD != nil {
+		newModel.LOD = model.LOD.Clone()
+	}
+
+	for _, instance := range model.Instances {
+		newModel.Instances = append(newModel.Instances, instance.Clone())
+	}
+
 	newModel.VertexClipFunction = model.VertexClipFunction
 	newModel.VertexTransformFunction = model.VertexTransformFunction
 
@@ -363,8 +398,9 @@ func (model *Model) ReassignBones(armatureRoot INode) {
 
 func (model *Model) skinVertex(vertID int, transformNormal bool) (vector.Vector, vector.Vector) {
 
-	// Avoid reallocating a new matrix for every vertex; that's wasteful
-	model.skinMatrix.Clear()
+	// Each vertex has its own position and normal vectors in the skinning pool, and the skinning matrix is local, so vertices
+	// can be skinned across multiple goroutines.
+	var skinMatrix Matrix4
 
 	var normal vector.Vector
 
@@ -377,27 +413,28 @@ func (model *Model) skinVertex(vertID int, transformNormal bool) (vector.Vector,
 		}
 
 		// We don't actually have to calculate the bone influence; it's automatically
-		// cached in the bone (Node) when the transform changes.
-		bone.Transform()
+		// cached in the bone (Node) when the transform changes (which ProcessVertices() does beforehand).
 
 		if weightPerc == 1 {
-			model.skinMatrix = bone.boneInfluence
+			skinMatrix = bone.boneInfluence
 			break // I think we can end here if the weight percentage is 100%, right?
 		} else {
-			model.skinMatrix = model.skinMatrix.Add(bone.boneInfluence.ScaleByScalar(weightPerc))
+			skinMatrix = skinMatrix.Add(bone.boneInfluence.ScaleByScalar(weightPerc))
 		}
 
 	}
 
-	vertOut := model.skinVectorPool.MultVecW(model.skinMatrix, model.Mesh.VertexPositions[vertID])
+	vertOut := model.skinVectorPool.Vectors[vertID*2]
+	vertOut[0], vertOut[1], vertOut[2], vertOut[3] = fastMatrixMultVecW(skinMatrix, model.Mesh.VertexPositions[vertID])
 
 	if transformNormal {
-		model.skinMatrix[3][0] = 0
-		model.skinMatrix[3][1] = 0
-		model.skinMatrix[3][2] = 0
-		model.skinMatrix[3][3] = 1
+		skinMatrix[3][0] = 0
+		skinMatrix[3][1] = 0
+		skinMatrix[3][2] = 0
+		skinMatrix[3][3] = 1
 
-		normal = model.skinVectorPool.MultVecW(model.skinMatrix, model.Mesh.VertexNormals[vertID])
+		normal = model.skinVectorPool.Vectors[vertID*2+1]
+		normal[0], normal[1], normal[2], normal[3] = fastMatrixMultVecW(skinMatrix, model.Mesh.VertexNormals[vertID])
 	}
 
 	return vertOut, normal
@@ -440,53 +477,72 @@ func (model *Model) ProcessVertices(vpMatrix Matrix4, camera *Camera, meshPart *
 			}
 		}
 
-		model.skinVectorPool.Reset()
-
 		t := time.Now()
 
+		// Bones cache their transforms (and so their influence), so they're updated ahead of time, as vertices may be skinned across
+		// multiple goroutines.
+		for _, bones := range model.bones {
+			for _, bone := range bones {
+				bone.Transform()
+			}
+		}
+
 		// If we're skinning a model, it will automatically copy the armature's position, scale, and rotation by copying its bones
-		for i := 0; i < len(meshPart.sortingTriangles); i++ {
+		skin := func(start, end int) {
 
-			tri := meshPart.sortingTriangles[i]
+			for i := start; i < end; i++ {
 
-			depth := math.MaxFloat32
+				tri := meshPart.sortingTriangles[i]
 
-			outOfBounds := true
+				depth := math.MaxFloat32
 
-			for v := 0; v < 3; v++ {
+				meshPart.sortingTriangles[i].rendered = true
+
+				outOfBounds := true
+
+				for v := 0; v < 3; v++ {
+
+					vertPos, vertNormal := model.skinVertex(tri.ID*3+v, lightingOn)
+					if transformFunc != nil {
+						vertPos = transformFunc(vertPos, tri.ID*3+v)
+					}
+					if vertNormal != nil {
+						model.Mesh.vertexSkinnedNormals[tri.ID*3+v] = vertNormal
+						model.Mesh.vertexSkinnedPositions[tri.ID*3+v] = vertPos
+					}
+					transformed := model.Mesh.vertexTransforms[tri.ID*3+v]
+					x, y, z, w := fastMatrixMultVecW(vpMatrix, vertPos)
+					transformed[0] = x
+					transformed[1] = y
+					transformed[2] = z
+					transformed[3] = w
+
+					if w >= 0 && z < far {
+						outOfBounds = false
+					}
+
+					if w < depth {
+						depth = w
+					}
 
-				vertPos, vertNormal := model.skinVertex(tri.ID*3+v, lightingOn)
-				if transformFunc != nil {
-					vertPos = transformFunc(vertPos, tri.ID*3+v)
-				}
-				if vertNormal != nil {
-					model.Mesh.vertexSkinnedNormals[tri.ID*3+v] = vertNormal
-					model.Mesh.vertexSkinnedPositions[tri.ID*3+v] = vertPos
-				}
-				transformed := model.Mesh.vertexTransforms[tri.ID*3+v]
-				x, y, z, w := fastMatrixMultVecW(vpMatrix, vertPos)
-				transformed[0] = x
-				transformed[1] = y
-				transformed[2] = z
-				transformed[3] = w
-
-				if w >= 0 && z < far {
-					outOfBounds = false
 				}
 
-				if w < depth {
-					depth = w
+				if outOfBounds {
+					meshPart.sortingTriangles[i].rendered = false
+					continue
 				}
 
-			}
+				meshPart.sortingTriangles[i].depth = float32(depth)
 
-			if outOfBounds {
-				meshPart.sortingTriangles[i].rendered = false
-				continue
 			}
 
-			meshPart.sortingTriangles[i].depth = float32(depth)
+		}
 
+		// VertexTransformFunctions aren't necessarily safe to call from multiple goroutines, so they're only called from this one.
+		if transformFunc != nil {
+			skin(0, len(meshPart.sortingTriangles))
+		} else {
+			camera.processInParallel(len(meshPart.sortingTriangles), skin)
 		}
 
 		camera.DebugInfo.animationTime += time.Since(t)
@@ -521,50 +577,61 @@ func (model *Model) ProcessVertices(vpMatrix Matrix4, camera *Camera, meshPart *
 
 		mvp := fastMatrixMult(base, vpMatrix)
 
-		for i := 0; i < len(meshPart.sortingTriangles); i++ {
+		transform := func(start, end int) {
 
-			triID := meshPart.sortingTriangles[i].ID
-			depth := math.MaxFloat64
+			for i := start; i < end; i++ {
 
-			// triRef := model.Mesh.Triangles[triID]
-			// TODO: Replace this distance check with a broadphase check; we could also use it to easily reject
-			// triangles that lie outside of the camera frustum.
-			// if fastVectorDistanceSquared(camPos, triRef.Center) > (camFarSquared)+triRef.MaxSpan {
-			// 	meshPart.sortingTriangles[i].rendered = false
-			// 	continue
-			// }
+				triID := meshPart.sortingTriangles[i].ID
+				depth := math.MaxFloat64
 
-			meshPart.sortingTriangles[i].rendered = true
+				// triRef := model.Mesh.Triangles[triID]
+				// TODO: Replace this distance check with a broadphase check; we could also use it to easily reject
+				// triangles that lie outside of the camera frustum.
+				// if fastVectorDistanceSquared(camPos, triRef.Center) > (camFarSquared)+triRef.MaxSpan {
+				// 	meshPart.sortingTriangles[i].rendered = false
+				// 	continue
+				// }
 
-			outOfBounds := true
+				meshPart.sortingTriangles[i].rendered = true
 
-			for i := 0; i < 3; i++ {
-				v0 := model.Mesh.VertexPositions[triID*3+i]
+				outOfBounds := true
 
-				if transformFunc != nil {
-					v0 = transformFunc(v0.Clone(), triID*3+i)
-				}
+				for v := 0; v < 3; v++ {
+					v0 := model.Mesh.VertexPositions[triID*3+v]
 
-				transformed := model.Mesh.vertexTransforms[triID*3+i]
-				transformed[0], transformed[1], transformed[2], transformed[3] = fastMatrixMultVecW(mvp, v0)
+					if transformFunc != nil {
+						v0 = transformFunc(v0.Clone(), triID*3+v)
+					}
+
+					transformed := model.Mesh.vertexTransforms[triID*3+v]
+					transformed[0], transformed[1], transformed[2], transformed[3] = fastMatrixMultVecW(mvp, v0)
+
+					if transformed[3] < depth {
+						depth = transformed[3]
+					}
+
+					if transformed[3] >= 0 && transformed[2] < far {
+						outOfBounds = false
+					}
 
-				if transformed[3] < depth {
-					depth = transformed[3]
 				}
 
-				if transformed[3] >= 0 && transformed[2] < far {
-					outOfBounds = false
+				if outOfBounds {
+					meshPart.sortingTriangles[i].rendered = false
+					continue
 				}
 
-			}
+				meshPart.sortingTriangles[i].depth = float32(depth)
 
-			if outOfBounds {
-				meshPart.sortingTriangles[i].rendered = false
-				continue
 			}
 
-			meshPart.sortingTriangles[i].depth = float32(depth)
+		}
 
+		// VertexTransformFunctions aren't necessarily safe to call from multiple goroutines, so they're only called from this one.
+		if transformFunc != nil {
+			transform(0, len(meshPart.sortingTriangles))
+		} else {
+			camera.processInParallel(len(meshPart.sortingTriangles), transform)
 		}
 
 	}
@@ -0,0 +1,67 @@
+package tetra3d
+
+import (
+	"math"
+	"testing"
+)
+
+func TestProcessVertices(t *testing.T) {
+
+	model := NewModel(NewIcosphere(2), "Icosphere")
+	meshPart := model.Mesh.MeshParts[0]
+
+	camera := NewCamera(640, 360)
+	camera.Move(0, 0, 5)
+	vpMatrix := camera.ViewMatrix().Mult(camera.Projection())
+
+	// Processing twice makes sure the results are right once the triangles are out of order from sorting
+	for pass := 0; pass < 2; pass++ {
+
+		model.ProcessVertices(vpMatrix, camera, meshPart, nil)
+
+		for i, tri := range meshPart.sortingTriangles {
+
+			depth := math.MaxFloat64
+			for v := 0; v < 3; v++ {
+				_, _, _, w := fastMatrixMultVecW(vpMatrix, model.Mesh.VertexPositions[tri.ID*3+v])
+				depth = math.Min(depth, w)
+				if transformed := model.Mesh.vertexTransforms[tri.ID*3+v]; transformed[3] != w {
+					t.Fatalf("vertex %d of triangle %d transformed to w = %f, expected %f", v, tri.ID, transformed[3], w)
+				}
+			}
+
+			if !tri.rendered {
+				t.Fatalf("triangle %d should be rendered", tri.ID)
+			}
+
+			if tri.depth != float32(depth) {
+				t.Fatalf("triangle %d has depth %f, expected %f", tri.ID, tri.depth, depth)
+			}
+
+			if i > 0 && tri.depth > meshPart.sortingTriangles[i-1].depth {
+				t.Fatalf("triangles aren't sorted back to front")
+			}
+
+		}
+
+	}
+
+}
+
+func BenchmarkProcessVertices(b *testing.B) {
+
+	model := NewModel(NewIcosphere(4), "Icosphere")
+	meshPart := model.Mesh.MeshParts[0]
+
+	camera := NewCamera(640, 360)
+	camera.Move(0, 0, 5)
+	vpMatrix := camera.ViewMatrix().Mult(camera.Projection())
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		model.ProcessVertices(vpMatrix, camera, meshPart, nil)
+	}
+
+}
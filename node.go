@@ -21,6 +21,9 @@ const (
 
 	NodeTypeGridPoint NodeType = "Node_GridPoint" // NodeTypeGrid represents specifically a GridPoint (note the extra underscore to ensure !NodeTypeGridPoint.Is(NodeTypeGrid))
 
+	NodeTypeRoom   NodeType = "NodeRoom"   // NodeTypeRoom represents specifically a Room
+	NodeTypePortal NodeType = "NodePortal" // NodeTypePortal represents specifically a Portal
+
 	NodeTypeBoundingObject    NodeType = "NodeBounding"          // NodeTypeBoundingObject represents any generic bounding object
 	NodeTypeBoundingAABB      NodeType = "NodeBoundingAABB"      // NodeTypeBoundingAABB represents specifically a BoundingAABB
 	NodeTypeBoundingCapsule   NodeType = "NodeBoundingCapsule"   // NodeTypeBoundingCapsule represents specifically a BoundingCapsule
@@ -32,6 +35,7 @@ const (
 	NodeTypePointLight       NodeType = "NodeLightPoint"       // NodeTypePointLight represents specifically a point light
 	NodeTypeDirectionalLight NodeType = "NodeLightDirectional" // NodeTypeDirectionalLight represents specifically a directional (sun) light
 	NodeTypeCubeLight        NodeType = "NodeLightCube"        // NodeTypeCubeLight represents, specifically, a cube light
+	NodeTypeSpotLight        NodeType = "NodeLightSpot"        // NodeTypeSpotLight represents specifically a spot light
 )
 
 // Is returns true if a NodeType satisfies another NodeType category. A specific node type can be said to
@@ -184,6 +188,7 @@ type INode interface {
 	AnimationPlayer() *AnimationPlayer
 
 	setOriginalLocalPosition(vector.Vector)
+	getOriginalLocalPosition() vector.Vector
 }
 
 // Tags is an unordered set of string tags to values, representing a means of identifying Nodes or carrying data on Nodes.
@@ -397,6 +402,10 @@ func (node *Node) setOriginalLocalPosition(position vector.Vector) {
 	node.originalLocalPosition = position
 }
 
+func (node *Node) getOriginalLocalPosition() vector.Vector {
+	return node.originalLocalPosition
+}
+
 // Name returns the object's name.
 func (node *Node) Name() string {
 	return node.name
@@ -921,6 +930,10 @@ func (node *Node) HierarchyAsString() string {
 				prefix = "GRID"
 			} else if nodeType.Is(NodeTypeGridPoint) {
 				prefix = "GPOINT"
+			} else if nodeType.Is(NodeTypeRoom) {
+				prefix = "ROOM"
+			} else if nodeType.Is(NodeTypePortal) {
+				prefix = "PORTAL"
 			} else if nodeType.Is(NodeTypeAmbientLight) {
 				prefix = "AMB"
 			} else if nodeType.Is(NodeTypeDirectionalLight) {
@@ -929,6 +942,8 @@ func (node *Node) HierarchyAsString() string {
 				prefix = "POINT"
 			} else if nodeType.Is(NodeTypeCubeLight) {
 				prefix = "CUBE"
+			} else if nodeType.Is(NodeTypeSpotLight) {
+				prefix = "SPOT"
 			} else if nodeType.Is(NodeTypeBoundingSphere) {
 				prefix = "BS"
 			} else if nodeType.Is(NodeTypeBoundingAABB) {
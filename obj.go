@@ -0,0 +1,533 @@
+package tetra3d
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"log"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// OBJLoadOptions represents options one can use to tweak how .obj files are loaded into Tetra3D.
+type OBJLoadOptions struct {
+	CorrectYUp bool // Whether to correct Z being up (for OBJ files exported with Z up); OBJ files are usually Y-up already, so this defaults to false.
+
+	// MaterialLibraryResolver is a function that takes the path to a .mtl material library referenced by the OBJ file (with "mtllib"), and returns
+	// the contents of the material library. If it's nil or returns an error, the materials used by the OBJ file will be created with default settings.
+	// LoadOBJFile() and LoadOBJFileFS() set this to read material libraries relative to the OBJ file if it's nil.
+	MaterialLibraryResolver func(path string) ([]byte, error)
+
+	// TextureResolver is a function that takes the path to a texture referenced by a material library, and returns the loaded texture.
+	// If it's nil or returns nil, the texture isn't loaded, though the Material's TexturePath (or NormalTexturePath, etc) is still set.
+	// LoadOBJFile() and LoadOBJFileFS() set this to load textures relative to the OBJ file if it's nil.
+	TextureResolver func(path string) *ebiten.Image
+
+	// KeepTextureSources indicates whether the images that textures are created from by the default TextureResolver of LoadOBJFile() and
+	// LoadOBJFileFS() should be registered as the textures' sources (see RegisterTextureSource()). Defaults to false.
+	KeepTextureSources bool
+}
+
+// DefaultOBJLoadOptions returns a default instance of OBJLoadOptions.
+func DefaultOBJLoadOptions() *OBJLoadOptions {
+	return &OBJLoadOptions{}
+}
+
+// LoadOBJFile takes a filepath to a .obj model file, and returns a *Library populated with the .obj file's objects, meshes, and materials
+// (from any .mtl material libraries referenced by the OBJ file). Unless options.MaterialLibraryResolver or options.TextureResolver are set,
+// material libraries and textures are loaded relative to the OBJ file, including from other directories (like "../textures/wall.png"; see
+// LoadOBJFileFS()). If the call couldn't complete for any reason, like due to a malformed OBJ file, it will return an error.
+func LoadOBJFile(path string, options *OBJLoadOptions) (*Library, error) {
+	return LoadOBJFileFS(osDirFS(filepath.Dir(path)), filepath.Base(path), options)
+}
+
+// objFace is a polygon from an OBJ file; each corner has indices into the position, UV, and normal lists (or -1 if unspecified).
+type objFace [][3]int
+
+// objPart is a collection of faces from an OBJ file that belong to the same group and use the same material.
+type objPart struct {
+	group    string
+	material string
+	faces    []objFace
+}
+
+// objObject is an object from an OBJ file.
+type objObject struct {
+	name  string
+	parts []*objPart
+}
+
+// LoadOBJData takes a []byte consisting of the contents of an OBJ file, and returns a *Library populated with the OBJ file's objects and meshes
+// in a single Scene. Each object ("o") in the OBJ file becomes a Model with its own Mesh, and each group ("g") or material ("usemtl") within an object
+// becomes a MeshPart. Materials are loaded from .mtl material libraries using options.MaterialLibraryResolver. Polygons with more than three sides
+// are triangulated as fans. If the call couldn't complete for any reason, like due to a malformed OBJ file, it will return an error.
+func LoadOBJData(data []byte, options *OBJLoadOptions) (*Library, error) {
+
+	if options == nil {
+		options = DefaultOBJLoadOptions()
+	}
+
+	library := NewLibrary()
+	scene := library.AddScene("Scene")
+	scene.library = library
+	library.ExportedScene = scene
+
+	positions := [][3]float64{}
+	colors := []*Color{}
+	uvs := [][2]float64{}
+	normals := [][3]float64{}
+
+	objects := []*objObject{}
+	var object *objObject
+	var part *objPart
+
+	group := ""
+	material := ""
+
+	// setPart sets the part faces are added to, creating the object and part as necessary.
+	setPart := func() {
+
+		if object == nil {
+			object = &objObject{name: "Object"}
+			objects = append(objects, object)
+		}
+
+		for _, existing := range object.parts {
+			if existing.group == group && existing.material == material {
+				part = existing
+				return
+			}
+		}
+
+		part = &objPart{group: group, material: material}
+		object.parts = append(object.parts, part)
+
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	lineNumber := 0
+
+	for scanner.Scan() {
+
+		lineNumber++
+
+		fields := strings.Fields(scanner.Text())
+
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		switch fields[0] {
+
+		case "v":
+
+			values, err := parseOBJFloats(fields[1:], 3, lineNumber)
+			if err != nil {
+				return nil, err
+			}
+
+			positions = append(positions, [3]float64{values[0], values[1], values[2]})
+
+			// Some exporters write vertex colors after positions.
+			if len(values) >= 6 {
+				colors = append(colors, NewColor(float32(values[3]), float32(values[4]), float32(values[5]), 1))
+			} else {
+				colors = append(colors, nil)
+			}
+
+		case "vt":
+
+			values, err := parseOBJFloats(fields[1:], 1, lineNumber)
+			if err != nil {
+				return nil, err
+			}
+
+			uv := [2]float64{values[0], 0}
+			if len(values) > 1 {
+				uv[1] = values[1]
+			}
+
+			uvs = append(uvs, uv)
+
+		case "vn":
+
+			values, err := parseOBJFloats(fields[1:], 3, lineNumber)
+			if err != nil {
+				return nil, err
+			}
+
+			normals = append(normals, [3]float64{values[0], values[1], values[2]})
+
+		case "f":
+
+			if len(fields) < 4 {
+				return nil, fmt.Errorf("error parsing OBJ data: face with fewer than three vertices on line %d", lineNumber)
+			}
+
+			face := objFace{}
+
+			for _, corner := range fields[1:] {
+
+				indices := [3]int{-1, -1, -1}
+				counts := [3]int{len(positions), len(uvs), len(normals)}
+
+				for i, indexStr := range strings.Split(corner, "/") {
+
+					if i > 2 {
+						break
+					}
+
+					if indexStr == "" {
+						continue
+					}
+
+					index, err := strconv.Atoi(indexStr)
+					if err != nil {
+						return nil, fmt.Errorf("error parsing OBJ data: invalid face index on line %d: %w", lineNumber, err)
+					}
+
+					// OBJ indices start at 1, and negative indices are relative to the end of the list so far.
+					if index < 0 {
+						index += counts[i]
+					} else {
+						index--
+					}
+
+					if index < 0 || index >= counts[i] {
+						return nil, fmt.Errorf("error parsing OBJ data: face index out of range on line %d", lineNumber)
+					}
+
+					indices[i] = index
+
+				}
+
+				if indices[0] < 0 {
+					return nil, fmt.Errorf("error parsing OBJ data: face vertex without a position on line %d", lineNumber)
+				}
+
+				face = append(face, indices)
+
+			}
+
+			if part == nil {
+				setPart()
+			}
+
+			part.faces = append(part.faces, face)
+
+		case "o":
+			object = &objObject{name: strings.Join(fields[1:], " ")}
+			objects = append(objects, object)
+			group = ""
+			part = nil
+
+		case "g":
+			group = strings.Join(fields[1:], " ")
+			part = nil
+
+		case "usemtl":
+			material = strings.Join(fields[1:], " ")
+			part = nil
+
+		case "mtllib":
+
+			if options.MaterialLibraryResolver == nil {
+				continue
+			}
+
+			// A single material library path can contain spaces, so we try the whole remainder of the line first, and then
+			// each field separately (as multiple libraries can be referenced on one line).
+			libraries := [][]byte{}
+
+			if mtlData, err := options.MaterialLibraryResolver(strings.Join(fields[1:], " ")); err == nil {
+				libraries = append(libraries, mtlData)
+			} else if len(fields) > 2 {
+				for _, path := range fields[1:] {
+					if mtlData, err := options.MaterialLibraryResolver(path); err == nil {
+						libraries = append(libraries, mtlData)
+					} else {
+						log.Println("Warning: couldn't load material library " + path + ": " + err.Error())
+					}
+				}
+			} else {
+				log.Println("Warning: couldn't load material library " + strings.Join(fields[1:], " ") + ": " + err.Error())
+			}
+
+			for _, mtlData := range libraries {
+				if err := loadMTLData(mtlData, library, options); err != nil {
+					return nil, err
+				}
+			}
+
+		}
+
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	meshNames := map[string]int{}
+
+	for _, obj := range objects {
+
+		name := obj.name
+		if count := meshNames[name]; count > 0 {
+			name = fmt.Sprintf("%s.%03d", obj.name, count)
+		}
+		meshNames[obj.name]++
+
+		mesh := NewMesh(name)
+		mesh.library = library
+
+		hasColors := false
+		hasNormals := true
+
+		for _, p := range obj.parts {
+
+			if len(p.faces) == 0 {
+				continue
+			}
+
+			mat, exists := library.Materials[p.material]
+			if !exists && p.material != "" {
+				mat = NewMaterial(p.material)
+				mat.library = library
+				library.Materials[p.material] = mat
+			}
+
+			verts := []VertexInfo{}
+
+			for _, face := range p.faces {
+
+				for i := 1; i < len(face)-1; i++ {
+
+					for _, corner := range []int{0, i, i + 1} {
+
+						indices := face[corner]
+
+						pos := positions[indices[0]]
+						vert := NewVertex(pos[0], pos[1], pos[2], 0, 0)
+
+						if options.CorrectYUp {
+							vert.Y, vert.Z = pos[2], -pos[1]
+						}
+
+						if indices[1] >= 0 {
+							vert.U = uvs[indices[1]][0]
+							vert.V = uvs[indices[1]][1]
+						}
+
+						if indices[2] >= 0 {
+							n := normals[indices[2]]
+							vert.NormalX, vert.NormalY, vert.NormalZ = n[0], n[1], n[2]
+							if options.CorrectYUp {
+								vert.NormalY, vert.NormalZ = n[2], -n[1]
+							}
+						} else {
+							hasNormals = false
+						}
+
+						if color := colors[indices[0]]; color != nil {
+							vert.Colors = append(vert.Colors, color.Clone())
+							vert.ActiveColorChannel = 0
+							hasColors = true
+						}
+
+						verts = append(verts, vert)
+
+					}
+
+				}
+
+			}
+
+			mesh.AddMeshPart(mat).AddTriangles(verts...)
+
+		}
+
+		if len(mesh.MeshParts) == 0 {
+			continue
+		}
+
+		// Vertices that don't have colors when others do get white, so every vertex has the same number of color channels.
+		if hasColors {
+			for i := 0; i < mesh.VertexCount; i++ {
+				if len(mesh.VertexColors[i]) == 0 {
+					mesh.VertexColors[i] = append(mesh.VertexColors[i], NewColor(1, 1, 1, 1))
+					mesh.VertexActiveColorChannel[i] = 0
+				}
+			}
+		}
+
+		mesh.UpdateBounds()
+
+		if !hasNormals {
+			mesh.AutoNormal()
+		}
+
+		library.Meshes[name] = mesh
+
+		model := NewModel(mesh, name)
+		model.setLibrary(library)
+		scene.Root.AddChildren(model)
+
+	}
+
+	return library, nil
+
+}
+
+// parseOBJFloats parses the given fields as floats, returning an error if there are fewer than minimum values.
+func parseOBJFloats(fields []string, minimum int, lineNumber int) ([]float64, error) {
+
+	if len(fields) < minimum {
+		return nil, fmt.Errorf("error parsing OBJ data: expected at least %d values on line %d", minimum, lineNumber)
+	}
+
+	values := make([]float64, 0, len(fields))
+
+	for _, field := range fields {
+		value, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing OBJ data: invalid number on line %d: %w", lineNumber, err)
+		}
+		values = append(values, value)
+	}
+
+	return values, nil
+
+}
+
+// loadMTLData parses the contents of an MTL material library, adding the Materials within to the Library given.
+func loadMTLData(data []byte, library *Library, options *OBJLoadOptions) error {
+
+	var mat *Material
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	lineNumber := 0
+
+	for scanner.Scan() {
+
+		lineNumber++
+
+		fields := strings.Fields(scanner.Text())
+
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		if fields[0] == "newmtl" {
+			mat = NewMaterial(strings.Join(fields[1:], " "))
+			mat.library = library
+			library.Materials[mat.Name] = mat
+			continue
+		}
+
+		if mat == nil {
+			continue
+		}
+
+		switch strings.ToLower(fields[0]) {
+
+		case "kd":
+			values, err := parseOBJFloats(fields[1:], 3, lineNumber)
+			if err != nil {
+				return err
+			}
+			mat.Color.R = float32(values[0])
+			mat.Color.G = float32(values[1])
+			mat.Color.B = float32(values[2])
+
+		case "ke":
+			values, err := parseOBJFloats(fields[1:], 3, lineNumber)
+			if err != nil {
+				return err
+			}
+			mat.EmissiveColor = NewColor(float32(values[0]), float32(values[1]), float32(values[2]), 1)
+
+		case "d", "tr":
+			values, err := parseOBJFloats(fields[len(fields)-1:], 1, lineNumber)
+			if err != nil {
+				return err
+			}
+			alpha := values[0]
+			if strings.ToLower(fields[0]) == "tr" {
+				alpha = 1 - alpha
+			}
+			mat.Color.A = float32(math.Max(0, math.Min(1, alpha)))
+			if mat.Color.A < 1 {
+				mat.TransparencyMode = TransparencyModeTransparent
+			}
+
+		case "ns":
+			// Convert the Phong specular exponent (0 - 1000) to a rough approximation of roughness.
+			values, err := parseOBJFloats(fields[1:], 1, lineNumber)
+			if err != nil {
+				return err
+			}
+			mat.Roughness = float32(1 - math.Sqrt(math.Max(0, math.Min(1000, values[0]))/1000))
+
+		case "illum":
+			// Illumination model 0 is a constant color, without lighting.
+			if fields[len(fields)-1] == "0" {
+				mat.Shadeless = true
+			}
+
+		case "map_kd":
+			mat.TexturePath = parseMTLTexturePath(fields[1:])
+			if options.TextureResolver != nil {
+				mat.Texture = options.TextureResolver(mat.TexturePath)
+			}
+
+		case "map_d":
+			mat.TransparencyMode = TransparencyModeTransparent
+
+		case "map_bump", "bump", "norm":
+			mat.NormalTexturePath = parseMTLTexturePath(fields[1:])
+			if options.TextureResolver != nil {
+				mat.NormalTexture = options.TextureResolver(mat.NormalTexturePath)
+			}
+
+		case "map_ke":
+			mat.EmissiveTexturePath = parseMTLTexturePath(fields[1:])
+			if options.TextureResolver != nil {
+				mat.EmissiveTexture = options.TextureResolver(mat.EmissiveTexturePath)
+			}
+			// Emissive textures are multiplied by the emissive color, so it shouldn't be black if it wasn't set.
+			if mat.EmissiveColor.R == 0 && mat.EmissiveColor.G == 0 && mat.EmissiveColor.B == 0 {
+				mat.EmissiveColor = NewColor(1, 1, 1, 1)
+			}
+
+		}
+
+	}
+
+	return scanner.Err()
+
+}
+
+// parseMTLTexturePath returns the path from the fields of a texture map statement in an MTL file, skipping any options (like "-s 1 1 1") before it.
+func parseMTLTexturePath(fields []string) string {
+
+	i := 0
+
+	for i < len(fields) && strings.HasPrefix(fields[i], "-") {
+		i++
+		// Skip the option's arguments, which are numbers or on / off.
+		for i < len(fields)-1 {
+			if _, err := strconv.ParseFloat(fields[i], 64); err != nil && fields[i] != "on" && fields[i] != "off" {
+				break
+			}
+			i++
+		}
+	}
+
+	return strings.Join(fields[i:], " ")
+
+}
@@ -0,0 +1,221 @@
+package tetra3d
+
+import (
+	"errors"
+	"image"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/kvartborg/vector"
+)
+
+// loadOBJMesh loads the given OBJ data, returning the Mesh of its only object.
+func loadOBJMesh(t *testing.T, data string, options *OBJLoadOptions) *Mesh {
+
+	t.Helper()
+
+	library, err := LoadOBJData([]byte(data), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(library.Meshes) != 1 {
+		t.Fatalf("expected 1 Mesh, got %d", len(library.Meshes))
+	}
+
+	for _, mesh := range library.Meshes {
+		return mesh
+	}
+
+	return nil
+
+}
+
+func TestLoadOBJDataNegativeIndices(t *testing.T) {
+
+	// The second face refers to the same vertices as the first using indices relative to the end of each list.
+	mesh := loadOBJMesh(t, `
+v 0 0 0
+v 1 0 0
+v 0 1 0
+vt 0 0
+vt 1 0
+vt 0 1
+vn 0 0 1
+f 1/1/1 2/2/1 3/3/1
+f -3/-3/-1 -2/-2/-1 -1/-1/-1
+`, nil)
+
+	if len(mesh.Triangles) != 2 {
+		t.Fatalf("expected 2 triangles, got %d", len(mesh.Triangles))
+	}
+
+	for i := 0; i < 3; i++ {
+		if !vectorsEqual(mesh.VertexPositions[i], mesh.VertexPositions[i+3]) || mesh.VertexUVs[i][0] != mesh.VertexUVs[i+3][0] || mesh.VertexUVs[i][1] != mesh.VertexUVs[i+3][1] {
+			t.Errorf("vertex %d of the face using negative indices is %v (UV %v), expected %v (UV %v)",
+				i, mesh.VertexPositions[i+3], mesh.VertexUVs[i+3], mesh.VertexPositions[i], mesh.VertexUVs[i])
+		}
+		if !vectorsEqual(mesh.VertexNormals[i+3], vector.Vector{0, 0, 1}) {
+			t.Errorf("vertex %d of the face using negative indices has normal %v", i, mesh.VertexNormals[i+3])
+		}
+	}
+
+	// Negative indices are relative to the vertices defined before the face, not the whole file.
+	mesh = loadOBJMesh(t, `
+v 0 0 0
+v 1 0 0
+v 0 1 0
+f -3 -2 -1
+v 5 5 5
+`, nil)
+
+	if !vectorsEqual(mesh.VertexPositions[2], vector.Vector{0, 1, 0}) {
+		t.Errorf("vertex -1 resolved to %v, expected the last vertex before the face", mesh.VertexPositions[2])
+	}
+
+	if _, err := LoadOBJData([]byte("v 0 0 0\nv 1 0 0\nf -1 -2 -3\n"), nil); err == nil {
+		t.Errorf("a negative index past the start of the vertex list should return an error")
+	}
+
+}
+
+func TestLoadOBJDataFanTriangulation(t *testing.T) {
+
+	mesh := loadOBJMesh(t, `
+v 0 0 0
+v 1 0 0
+v 2 1 0
+v 1 2 0
+v 0 1 0
+f 1 2 3 4 5
+`, nil)
+
+	if len(mesh.Triangles) != 3 {
+		t.Fatalf("expected a pentagon to be split into 3 triangles, got %d", len(mesh.Triangles))
+	}
+
+	// Each triangle of the fan starts at the face's first vertex.
+	expected := [][3]int{{0, 1, 2}, {0, 2, 3}, {0, 3, 4}}
+	corners := []vector.Vector{{0, 0, 0}, {1, 0, 0}, {2, 1, 0}, {1, 2, 0}, {0, 1, 0}}
+
+	for triIndex, tri := range expected {
+		for v, corner := range tri {
+			if position := mesh.VertexPositions[triIndex*3+v]; !vectorsEqual(position, corners[corner]) {
+				t.Errorf("vertex %d of triangle %d is %v, expected %v", v, triIndex, position, corners[corner])
+			}
+		}
+	}
+
+	// Faces without normals get generated ones; the fan keeps the face's winding, so they all face the same way.
+	for i := 0; i < mesh.VertexCount; i++ {
+		if !vectorsEqual(mesh.VertexNormals[i], vector.Vector{0, 0, 1}) {
+			t.Errorf("vertex %d has normal %v, expected {0, 0, 1}", i, mesh.VertexNormals[i])
+		}
+	}
+
+}
+
+func TestLoadMTLTextureOptions(t *testing.T) {
+
+	mtl := `
+newmtl Wall
+Kd 0.5 0.25 1
+map_Kd -s 1 1 1 -o 0.5 0.5 -clamp on -bm 0.2 textures/wall 2.png
+map_Bump -bm 1.5 textures/wall_normal.png
+`
+
+	resolved := []string{}
+	texture := ebiten.NewImage(1, 1)
+
+	options := &OBJLoadOptions{
+		MaterialLibraryResolver: func(path string) ([]byte, error) {
+			if path != "wall.mtl" {
+				return nil, errors.New("unknown material library " + path)
+			}
+			return []byte(mtl), nil
+		},
+		TextureResolver: func(path string) *ebiten.Image {
+			resolved = append(resolved, path)
+			return texture
+		},
+	}
+
+	mesh := loadOBJMesh(t, `
+mtllib wall.mtl
+v 0 0 0
+v 1 0 0
+v 0 1 0
+usemtl Wall
+f 1 2 3
+`, options)
+
+	mat := mesh.MeshParts[0].Material
+
+	if mat == nil || mat.Name != "Wall" {
+		t.Fatalf("expected the Mesh to use the Wall material, got %v", mat)
+	}
+
+	if mat.Color.R != 0.5 || mat.Color.G != 0.25 || mat.Color.B != 1 {
+		t.Errorf("expected a diffuse color of (0.5, 0.25, 1), got %v", mat.Color)
+	}
+
+	if mat.TexturePath != "textures/wall 2.png" {
+		t.Errorf("expected a texture path of %q, got %q", "textures/wall 2.png", mat.TexturePath)
+	}
+
+	if mat.NormalTexturePath != "textures/wall_normal.png" {
+		t.Errorf("expected a normal texture path of %q, got %q", "textures/wall_normal.png", mat.NormalTexturePath)
+	}
+
+	if mat.Texture != texture || mat.NormalTexture != texture {
+		t.Errorf("textures weren't assigned from the TextureResolver")
+	}
+
+	if len(resolved) != 2 || resolved[0] != mat.TexturePath || resolved[1] != mat.NormalTexturePath {
+		t.Errorf("TextureResolver was called with %q", resolved)
+	}
+
+}
+
+func TestLoadOBJFileRelativePaths(t *testing.T) {
+
+	root := t.TempDir()
+
+	for _, dir := range []string{"models", "materials", "textures"} {
+		if err := os.Mkdir(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := SavePNG(image.NewNRGBA(image.Rect(0, 0, 2, 2)), filepath.Join(root, "textures", "wall.png")); err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]string{
+		"materials/wall.mtl": "newmtl Wall\nmap_Kd ../textures/wall.png\n",
+		"models/wall.obj":    "mtllib ../materials/wall.mtl\nv 0 0 0\nv 1 0 0\nv 0 1 0\nusemtl Wall\nf 1 2 3\n",
+	}
+
+	for name, contents := range files {
+		if err := os.WriteFile(filepath.Join(root, filepath.FromSlash(name)), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	library, err := LoadOBJFile(filepath.Join(root, "models", "wall.obj"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mat, exists := library.Materials["Wall"]
+	if !exists {
+		t.Fatalf("the material library in a sibling directory wasn't loaded")
+	}
+
+	if mat.Texture == nil {
+		t.Errorf("the texture %s wasn't loaded", mat.TexturePath)
+	}
+
+}
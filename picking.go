@@ -0,0 +1,181 @@
+package tetra3d
+
+import (
+	"math"
+
+	"github.com/kvartborg/vector"
+)
+
+// PickResult holds the result of picking what's under a position on screen with Camera.Pick().
+type PickResult struct {
+	Model         *Model        // The Model under the screen position.
+	Instance      *Instance     // The Instance of the Model under the screen position, if the Model has Instances.
+	MeshPart      *MeshPart     // The MeshPart of the Model's Mesh under the screen position.
+	TriangleIndex int           // The index of the triangle under the screen position in the Model's Mesh.Triangles slice.
+	Position      vector.Vector // The world position under the screen position.
+	Normal        vector.Vector // The world normal of the triangle under the screen position.
+	Distance      float64       // The distance from the Camera (or, for orthographic Cameras, the Camera's plane) to Position.
+}
+
+// Pick returns what's under the given position on screen (in pixels, relative to the Camera's color texture) of the Models under the rootNode
+// (including the rootNode itself), or nil if there's nothing there. Picking works by casting a ray through the screen position into the scene
+// and testing it against the triangles of visible Models on render layers the Camera renders; triangles facing away from the Camera are skipped
+// for Materials with backface culling on. Note that Models' VertexTransformFunctions and billboarding aren't taken into account.
+func (camera *Camera) Pick(x, y float64, rootNode INode) *PickResult {
+
+	origin, dir := camera.ScreenRay(x, y)
+
+	models := []*Model{}
+
+	if model, isModel := rootNode.(*Model); isModel {
+		models = append(models, model)
+	}
+
+	for _, node := range rootNode.ChildrenRecursive() {
+		if model, isModel := node.(*Model); isModel {
+			models = append(models, model)
+		}
+	}
+
+	var result *PickResult
+
+	for _, model := range models {
+
+		if !model.visible || model.Mesh == nil || model.RenderLayers&camera.CullingMask == 0 {
+			continue
+		}
+
+		if model.DynamicBatchOwner != nil && !model.DynamicBatchOwner.visible {
+			continue
+		}
+
+		// Owners of dynamic batches aren't rendered themselves; only the Models batched into them are.
+		if len(model.DynamicBatchModels) > 0 {
+			continue
+		}
+
+		if len(model.Instances) > 0 {
+
+			transform := model.Transform()
+
+			for _, instance := range model.Instances {
+				if instance == nil || !instance.Visible {
+					continue
+				}
+				if hit := camera.pickMesh(model, instance.Transform.Mult(transform), origin, dir); hit != nil && (result == nil || hit.Distance < result.Distance) {
+					hit.Instance = instance
+					result = hit
+				}
+			}
+
+		} else if hit := camera.pickMesh(model, model.Transform(), origin, dir); hit != nil && (result == nil || hit.Distance < result.Distance) {
+			result = hit
+		}
+
+	}
+
+	return result
+
+}
+
+// pickMesh returns the closest triangle of the Model's Mesh (transformed by the given transform) hit by the given ray, or nil if none were hit.
+func (camera *Camera) pickMesh(model *Model, transform Matrix4, origin, dir vector.Vector) *PickResult {
+
+	mesh := model.Mesh
+
+	// Check the ray against the Mesh's bounding sphere first.
+	_, scale, _ := transform.Decompose()
+	maxScale := math.Max(math.Max(math.Abs(scale[0]), math.Abs(scale[1])), math.Abs(scale[2]))
+	radius := mesh.Dimensions.MaxSpan() / 2 * maxScale
+
+	if !model.Skinned {
+		center := transform.MultVec(mesh.Dimensions.Center())
+		toCenter := center.Sub(origin)
+		along := toCenter.Dot(dir)
+		if toCenter.Dot(toCenter)-along*along > radius*radius || along < -radius {
+			return nil
+		}
+	}
+
+	var result *PickResult
+
+	positions := [3]vector.Vector{}
+
+	for _, tri := range mesh.Triangles {
+
+		for i := 0; i < 3; i++ {
+			if model.Skinned {
+				pos, _ := model.skinVertex(tri.ID*3+i, false)
+				positions[i] = pos.Clone()
+			} else {
+				positions[i] = transform.MultVec(mesh.VertexPositions[tri.ID*3+i])
+			}
+		}
+
+		normal := calculateNormal(positions[0], positions[1], positions[2])
+
+		if (tri.MeshPart.Material == nil || tri.MeshPart.Material.BackfaceCulling) && normal.Dot(dir) > 0 {
+			continue
+		}
+
+		dist, hit := rayTriangleIntersection(origin, dir, positions[0], positions[1], positions[2])
+
+		if !hit || dist > camera.Far || (result != nil && dist >= result.Distance) {
+			continue
+		}
+
+		result = &PickResult{
+			Model:         model,
+			MeshPart:      tri.MeshPart,
+			TriangleIndex: tri.ID,
+			Position:      origin.Add(dir.Scale(dist)),
+			Normal:        normal,
+			Distance:      dist,
+		}
+
+	}
+
+	return result
+
+}
+
+// rayTriangleIntersection returns the distance along the ray (with a normalized direction) to where it intersects the given triangle,
+// and whether it intersects the triangle at all, using the Möller-Trumbore algorithm.
+func rayTriangleIntersection(origin, dir, p0, p1, p2 vector.Vector) (float64, bool) {
+
+	const epsilon = 0.0000001
+
+	edge1 := p1.Sub(p0)
+	edge2 := p2.Sub(p0)
+
+	h, _ := dir.Cross(edge2)
+	a := edge1.Dot(h)
+
+	if a > -epsilon && a < epsilon {
+		return 0, false // The ray is parallel to the triangle
+	}
+
+	f := 1 / a
+	s := origin.Sub(p0)
+	u := f * s.Dot(h)
+
+	if u < 0 || u > 1 {
+		return 0, false
+	}
+
+	q, _ := s.Cross(edge1)
+	v := f * dir.Dot(q)
+
+	if v < 0 || u+v > 1 {
+		return 0, false
+	}
+
+	t := f * edge2.Dot(q)
+
+	if t < 0 {
+		return 0, false
+	}
+
+	return t, true
+
+}
@@ -0,0 +1,180 @@
+package tetra3d
+
+import (
+	"math"
+	"testing"
+
+	"github.com/kvartborg/vector"
+)
+
+// newPickQuad creates a Model of a 2x2 quad centered on the given position, facing +Z (towards a Camera at the origin).
+func newPickQuad(name string, x, y, z float64) *Model {
+
+	mesh := NewMesh(name)
+	part := mesh.AddMeshPart(NewMaterial(name))
+	part.AddTriangles(
+		NewVertex(-1, -1, 0, 0, 1),
+		NewVertex(1, -1, 0, 1, 1),
+		NewVertex(1, 1, 0, 1, 0),
+
+		NewVertex(-1, -1, 0, 0, 1),
+		NewVertex(1, 1, 0, 1, 0),
+		NewVertex(-1, 1, 0, 0, 0),
+	)
+	mesh.UpdateBounds()
+	mesh.AutoNormal()
+
+	model := NewModel(mesh, name)
+	model.SetLocalPosition(x, y, z)
+	return model
+
+}
+
+func TestRayTriangleIntersection(t *testing.T) {
+
+	// A triangle 5 units down -Z from the origin.
+	p0, p1, p2 := vector.Vector{-1, -1, -5}, vector.Vector{1, -1, -5}, vector.Vector{0, 1, -5}
+
+	tests := []struct {
+		name     string
+		origin   vector.Vector
+		dir      vector.Vector
+		hit      bool
+		distance float64
+	}{
+		{"straight through the middle", vector.Vector{0, 0, 0}, vector.Vector{0, 0, -1}, true, 5},
+		{"from behind the triangle", vector.Vector{0, 0, -10}, vector.Vector{0, 0, 1}, true, 5},
+		{"at an angle", vector.Vector{0, 0, 0}, vector.Vector{0, -0.5, -5}.Unit(), true, math.Sqrt(25 + 0.25)},
+		{"on a corner", vector.Vector{-1, -1, 0}, vector.Vector{0, 0, -1}, true, 5},
+		{"off to the side", vector.Vector{2, 0, 0}, vector.Vector{0, 0, -1}, false, 0},
+		{"above the slanted edge", vector.Vector{0.75, 0.75, 0}, vector.Vector{0, 0, -1}, false, 0},
+		{"pointing away", vector.Vector{0, 0, 0}, vector.Vector{0, 0, 1}, false, 0},
+		{"parallel", vector.Vector{0, 0, 0}, vector.Vector{0, 1, 0}, false, 0},
+	}
+
+	for _, test := range tests {
+
+		distance, hit := rayTriangleIntersection(test.origin, test.dir, p0, p1, p2)
+
+		if hit != test.hit {
+			t.Errorf("%s: expected a hit: %t", test.name, test.hit)
+		} else if hit && math.Abs(distance-test.distance) > 1e-6 {
+			t.Errorf("%s: hit at a distance of %f, expected %f", test.name, distance, test.distance)
+		}
+
+	}
+
+}
+
+func TestCameraPick(t *testing.T) {
+
+	scene := NewScene("Picking")
+
+	near := newPickQuad("Near", 0, 0, -5)
+	far := newPickQuad("Far", 0, 0, -10)
+	side := newPickQuad("Side", 3, 0, -10)
+	scene.Root.AddChildren(far, near, side)
+
+	camera := NewCamera(320, 180)
+	scene.Root.AddChildren(camera)
+
+	result := camera.Pick(160, 90, scene.Root)
+
+	if result == nil || result.Model != near {
+		t.Fatalf("expected to pick the nearest Model of the two in the middle of the screen, got %+v", result)
+	}
+
+	if result.MeshPart != near.Mesh.MeshParts[0] || result.Instance != nil {
+		t.Errorf("expected to pick the Model's MeshPart, without an Instance")
+	}
+
+	if math.Abs(result.Distance-5) > 1e-6 || !vectorsEqual(result.Position, vector.Vector{0, 0, -5}) || !vectorsEqual(result.Normal, vector.Vector{0, 0, 1}) {
+		t.Errorf("expected a hit at a distance of 5 at {0, 0, -5} facing +Z, got a distance of %f at %v facing %v", result.Distance, result.Position, result.Normal)
+	}
+
+	// The quad off to the side is only hit through its own position on screen.
+	sidePos := camera.WorldToScreen(vector.Vector{3.5, 0.5, -10})
+
+	if result := camera.Pick(sidePos[0], sidePos[1], scene.Root); result == nil || result.Model != side || !vectorsEqual(result.Position, vector.Vector{3.5, 0.5, -10}) {
+		t.Errorf("expected to pick the Model off to the side at {3.5, 0.5, -10}, got %+v", result)
+	}
+
+	if result := camera.Pick(0, 0, scene.Root); result != nil {
+		t.Errorf("expected nothing in the corner of the screen, got %+v", result)
+	}
+
+	// Picking is limited to the given node and its children.
+	if result := camera.Pick(160, 90, far); result == nil || result.Model != far {
+		t.Errorf("expected to pick the only Model under the root node given, got %+v", result)
+	}
+
+	// Hidden Models are skipped.
+	near.SetVisible(false, false)
+
+	if result := camera.Pick(160, 90, scene.Root); result == nil || result.Model != far {
+		t.Errorf("expected to pick the far Model once the near one is hidden, got %+v", result)
+	}
+
+	near.SetVisible(true, false)
+
+	// Turning the near quad around, it faces away from the Camera, so it's skipped with backface culling on.
+	near.SetLocalRotation(NewMatrix4Rotate(0, 1, 0, math.Pi))
+
+	if result := camera.Pick(160, 90, scene.Root); result == nil || result.Model != far {
+		t.Errorf("expected the back of the near Model to be skipped, got %+v", result)
+	}
+
+	near.Mesh.MeshParts[0].Material.BackfaceCulling = false
+
+	if result := camera.Pick(160, 90, scene.Root); result == nil || result.Model != near || !vectorsEqual(result.Normal, vector.Vector{0, 0, -1}) {
+		t.Errorf("expected the back of the near Model to be picked with backface culling off, got %+v", result)
+	}
+
+}
+
+func TestCameraPickInstance(t *testing.T) {
+
+	scene := NewScene("Picking")
+
+	model := newPickQuad("Instanced", 0, 0, -10)
+	scene.Root.AddChildren(model)
+
+	left := NewInstance(NewMatrix4Translate(-3, 0, 0))
+	middle := NewInstance(NewMatrix4Translate(0, 0, 0))
+	right := NewInstance(NewMatrix4Translate(3, 0, 0))
+	front := NewInstance(NewMatrix4Translate(0, 0, 5))
+	front.Visible = false
+	model.Instances = []*Instance{left, middle, right, front}
+
+	camera := NewCamera(320, 180)
+	scene.Root.AddChildren(camera)
+
+	for _, test := range []struct {
+		name     string
+		position vector.Vector
+		instance *Instance
+	}{
+		{"left", vector.Vector{-3, 0, -10}, left},
+		{"middle", vector.Vector{0, 0, -10}, middle},
+		{"right", vector.Vector{3, 0, -10}, right},
+	} {
+
+		screenPos := camera.WorldToScreen(test.position)
+		result := camera.Pick(screenPos[0], screenPos[1], scene.Root)
+
+		if result == nil || result.Model != model || result.Instance != test.instance {
+			t.Errorf("expected to pick the %s Instance, got %+v", test.name, result)
+		} else if !vectorsEqual(result.Position, test.position) {
+			t.Errorf("expected to pick the %s Instance at %v, got %v", test.name, test.position, result.Position)
+		}
+
+	}
+
+	// Showing the Instance in front of the middle one, it's nearer, and so picked instead.
+	front.Visible = true
+
+	if result := camera.Pick(160, 90, scene.Root); result == nil || result.Instance != front || math.Abs(result.Distance-5) > 1e-6 {
+		t.Errorf("expected to pick the nearer Instance at a distance of 5, got %+v", result)
+	}
+
+}
@@ -0,0 +1,426 @@
+package tetra3d
+
+import (
+	"math"
+
+	"github.com/kvartborg/vector"
+)
+
+// Room represents a volume of space (like a room in a building) used for portal culling. When a Camera inside of a Room renders, only
+// Models in Rooms visible from the Camera's Room (through Portals) are rendered. Models outside of all Rooms are always rendered.
+type Room struct {
+	*Node
+	Dimensions Dimensions // The volume of the Room, relative to the Room's transform.
+}
+
+// NewRoom creates a new Room with the given name and dimensions (relative to the Room's transform).
+func NewRoom(name string, dimensions Dimensions) *Room {
+	return &Room{
+		Node:       NewNode(name),
+		Dimensions: dimensions,
+	}
+}
+
+// Clone creates a clone of the Room.
+func (room *Room) Clone() INode {
+	newRoom := &Room{
+		Node:       room.Node.Clone().(*Node),
+		Dimensions: room.Dimensions.Clone(),
+	}
+	for _, child := range newRoom.children {
+		child.setParent(newRoom)
+	}
+	return newRoom
+}
+
+// ContainsPoint returns if the given world position lies within the Room's volume.
+func (room *Room) ContainsPoint(position vector.Vector) bool {
+	return room.Dimensions.Inside(room.Transform().Inverted().MultVec(position))
+}
+
+// AddChildren parents the provided children Nodes to the passed parent Node, inheriting its transformations and being under it in the scenegraph
+// hierarchy. If the children are already parented to other Nodes, they are unparented before doing so.
+func (room *Room) AddChildren(children ...INode) {
+	// We do this manually so that addChildren() parents the children to the Room, rather than to the Room.NodeBase.
+	room.addChildren(room, children...)
+}
+
+// Unparent unparents the Room from its parent, removing it from the scenegraph.
+func (room *Room) Unparent() {
+	if room.parent != nil {
+		room.parent.RemoveChildren(room)
+	}
+}
+
+// Type returns the NodeType for this object.
+func (room *Room) Type() NodeType {
+	return NodeTypeRoom
+}
+
+// Portal represents an opening (like a doorway or window) between Rooms, through which a Camera in one Room can see into the others.
+// A Portal is a flat, convex polygon, and connects the Rooms whose volumes contain its center; because of this, Portals should be placed
+// where the Rooms they connect touch or overlap.
+type Portal struct {
+	*Node
+	Points []vector.Vector // The points making up the Portal's polygon, relative to the Portal's transform.
+}
+
+// NewPortal creates a new Portal with the given name and polygon points (relative to the Portal's transform).
+func NewPortal(name string, points ...vector.Vector) *Portal {
+	return &Portal{
+		Node:   NewNode(name),
+		Points: points,
+	}
+}
+
+// Clone creates a clone of the Portal.
+func (portal *Portal) Clone() INode {
+	newPortal := &Portal{
+		Node: portal.Node.Clone().(*Node),
+	}
+	for _, point := range portal.Points {
+		newPortal.Points = append(newPortal.Points, point.Clone())
+	}
+	for _, child := range newPortal.children {
+		child.setParent(newPortal)
+	}
+	return newPortal
+}
+
+// WorldPoints returns the points making up the Portal's polygon in world space.
+func (portal *Portal) WorldPoints() []vector.Vector {
+	transform := portal.Transform()
+	points := make([]vector.Vector, 0, len(portal.Points))
+	for _, point := range portal.Points {
+		points = append(points, transform.MultVec(point))
+	}
+	return points
+}
+
+// Center returns the center of the Portal's polygon in world space.
+func (portal *Portal) Center() vector.Vector {
+	center := vector.Vector{0, 0, 0}
+	points := portal.WorldPoints()
+	for _, point := range points {
+		center = center.Add(point)
+	}
+	if len(points) > 0 {
+		center = center.Scale(1.0 / float64(len(points)))
+	}
+	return center
+}
+
+// AddChildren parents the provided children Nodes to the passed parent Node, inheriting its transformations and being under it in the scenegraph
+// hierarchy. If the children are already parented to other Nodes, they are unparented before doing so.
+func (portal *Portal) AddChildren(children ...INode) {
+	// We do this manually so that addChildren() parents the children to the Portal, rather than to the Portal.NodeBase.
+	portal.addChildren(portal, children...)
+}
+
+// Unparent unparents the Portal from its parent, removing it from the scenegraph.
+func (portal *Portal) Unparent() {
+	if portal.parent != nil {
+		portal.parent.RemoveChildren(portal)
+	}
+}
+
+// Type returns the NodeType for this object.
+func (portal *Portal) Type() NodeType {
+	return NodeTypePortal
+}
+
+// screenRect is a rectangle in normalized screen space (as clip coordinates divided by W).
+type screenRect struct {
+	MinX, MinY, MaxX, MaxY float64
+}
+
+var fullScreenRect = screenRect{math.Inf(-1), math.Inf(-1), math.Inf(1), math.Inf(1)}
+
+func (rect screenRect) empty() bool {
+	return rect.MinX >= rect.MaxX || rect.MinY >= rect.MaxY
+}
+
+func (rect screenRect) intersection(other screenRect) screenRect {
+	return screenRect{
+		math.Max(rect.MinX, other.MinX),
+		math.Max(rect.MinY, other.MinY),
+		math.Min(rect.MaxX, other.MaxX),
+		math.Min(rect.MaxY, other.MaxY),
+	}
+}
+
+func (rect screenRect) union(other screenRect) screenRect {
+	return screenRect{
+		math.Min(rect.MinX, other.MinX),
+		math.Min(rect.MinY, other.MinY),
+		math.Max(rect.MaxX, other.MaxX),
+		math.Max(rect.MaxY, other.MaxY),
+	}
+}
+
+// portalRoom holds a Room's state for a portal culling pass.
+type portalRoom struct {
+	room      *Room
+	inverse   Matrix4
+	portals   []*portalLink
+	rect      screenRect
+	visible   bool
+	traversed bool
+}
+
+// portalLink is a Portal connecting Rooms, along with the portion of the screen it covers.
+type portalLink struct {
+	portal  *Portal
+	rooms   []*portalRoom
+	rect    screenRect
+	visible bool
+}
+
+// portalVisibility holds the Rooms visible to a Camera, and the portions of the screen they're visible through.
+type portalVisibility struct {
+	camera *Camera
+	view   Matrix4
+	rooms  []*portalRoom
+}
+
+// portalRoomMargin is how far outside of a Room's volume a Portal's center can be while still being considered to connect to the Room.
+const portalRoomMargin = 0.01
+
+// portalCulling determines which Rooms in the Scene are visible from the Camera through Portals. It returns nil if the Scene has no
+// Rooms, or the Camera isn't in any of them, in which case no Models are culled.
+func (camera *Camera) portalCulling(scene *Scene) *portalVisibility {
+
+	if scene == nil || scene.Root == nil {
+		return nil
+	}
+
+	vis := &portalVisibility{
+		camera: camera,
+		view:   camera.ViewMatrix(),
+	}
+
+	portals := []*Portal{}
+
+	for _, node := range scene.Root.ChildrenRecursive() {
+		if room, isRoom := node.(*Room); isRoom {
+			vis.rooms = append(vis.rooms, &portalRoom{room: room, inverse: room.Transform().Inverted()})
+		} else if portal, isPortal := node.(*Portal); isPortal {
+			portals = append(portals, portal)
+		}
+	}
+
+	if len(vis.rooms) == 0 {
+		return nil
+	}
+
+	var cameraRoom *portalRoom
+
+	camPos := camera.WorldPosition()
+
+	for _, pr := range vis.rooms {
+		if pr.room.Dimensions.Inside(pr.inverse.MultVec(camPos)) {
+			cameraRoom = pr
+			break
+		}
+	}
+
+	if cameraRoom == nil {
+		return nil
+	}
+
+	for _, portal := range portals {
+
+		points := portal.WorldPoints()
+
+		if len(points) < 3 {
+			continue
+		}
+
+		link := &portalLink{portal: portal}
+		link.rect, link.visible = camera.portalScreenRect(vis.view, points)
+
+		center := portal.Center()
+
+		for _, pr := range vis.rooms {
+
+			dim := pr.room.Dimensions
+			margin := vector.Vector{portalRoomMargin, portalRoomMargin, portalRoomMargin}
+			expanded := Dimensions{dim[0].Sub(margin), dim[1].Add(margin)}
+
+			if expanded.Inside(pr.inverse.MultVec(center)) {
+				link.rooms = append(link.rooms, pr)
+				pr.portals = append(pr.portals, link)
+			}
+
+		}
+
+	}
+
+	vis.traverse(cameraRoom, fullScreenRect)
+
+	return vis
+
+}
+
+// traverse marks the Room as visible through the given portion of the screen, and continues on to the Rooms visible through its Portals.
+func (vis *portalVisibility) traverse(pr *portalRoom, rect screenRect) {
+
+	if pr.visible {
+		pr.rect = pr.rect.union(rect)
+	} else {
+		pr.rect = rect
+		pr.visible = true
+	}
+
+	pr.traversed = true
+
+	for _, link := range pr.portals {
+
+		if !link.visible {
+			continue
+		}
+
+		clipped := rect.intersection(link.rect)
+
+		if clipped.empty() {
+			continue
+		}
+
+		for _, other := range link.rooms {
+			// Rooms already on the current path are skipped to avoid looping back through Portals we've come through.
+			if !other.traversed {
+				vis.traverse(other, clipped)
+			}
+		}
+
+	}
+
+	pr.traversed = false
+
+}
+
+// portalScreenRect returns the portion of the screen covered by the polygon made up of the given world-space points, and whether
+// any of it is in front of the Camera.
+func (camera *Camera) portalScreenRect(view Matrix4, points []vector.Vector) (screenRect, bool) {
+
+	near := camera.Near
+
+	viewPoints := make([]vector.Vector, 0, len(points))
+	for _, p := range points {
+		viewPoints = append(viewPoints, view.MultVec(p))
+	}
+
+	// Clip the polygon against the near plane (the Camera looks down -Z).
+	clipped := make([]vector.Vector, 0, len(viewPoints)+1)
+
+	for i, p := range viewPoints {
+
+		next := viewPoints[(i+1)%len(viewPoints)]
+		pIn := -p[2] >= near
+		nextIn := -next[2] >= near
+
+		if pIn {
+			clipped = append(clipped, p)
+		}
+
+		if pIn != nextIn {
+			t := (-near - p[2]) / (next[2] - p[2])
+			clipped = append(clipped, p.Add(next.Sub(p).Scale(t)))
+		}
+
+	}
+
+	if len(clipped) == 0 {
+
+		// The Portal is entirely behind the near plane; if the Camera is passing through it (so it surrounds the Camera, within the near
+		// distance of the Camera's plane), though, the view beyond it fills the screen.
+		dim := NewDimensionsFromPoints(viewPoints...)
+		if dim[0][0] <= 0 && dim[1][0] >= 0 && dim[0][1] <= 0 && dim[1][1] >= 0 && dim[0][2] <= near && dim[1][2] >= -near {
+			return fullScreenRect, true
+		}
+
+		return screenRect{}, false
+	}
+
+	projection := camera.Projection()
+
+	rect := screenRect{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+
+	for _, p := range clipped {
+		c := projection.MultVecW(p)
+		w := c[3]
+		if !camera.Perspective {
+			w = 1
+		}
+		x, y := c[0]/w, c[1]/w
+		rect.MinX = math.Min(rect.MinX, x)
+		rect.MinY = math.Min(rect.MinY, y)
+		rect.MaxX = math.Max(rect.MaxX, x)
+		rect.MaxY = math.Max(rect.MaxY, y)
+	}
+
+	return rect, true
+
+}
+
+// modelVisible returns if the Model is visible through the Rooms it's in. Models that aren't in any Room are always visible.
+func (vis *portalVisibility) modelVisible(model *Model) bool {
+
+	center := model.BoundingSphere.WorldPosition()
+	radius := model.BoundingSphere.WorldRadius()
+
+	inRoom := false
+
+	for _, pr := range vis.rooms {
+
+		// The sphere is tested against the Room in the Room's local space, so its radius is scaled by the Room's smallest scale to stay conservative.
+		scale := pr.room.WorldScale()
+		minScale := math.Min(math.Min(math.Abs(scale[0]), math.Abs(scale[1])), math.Abs(scale[2]))
+		localRadius := radius
+		if minScale > 0 {
+			localRadius /= minScale
+		}
+
+		local := pr.inverse.MultVec(center)
+		closest := pr.room.Dimensions.Limit(local.Clone())
+
+		if closest.Sub(local).Magnitude() > localRadius {
+			continue
+		}
+
+		inRoom = true
+
+		if pr.visible && !pr.rect.intersection(vis.sphereScreenRect(center, radius)).empty() {
+			return true
+		}
+
+	}
+
+	return !inRoom
+
+}
+
+// sphereScreenRect returns a conservative portion of the screen covered by the given sphere.
+func (vis *portalVisibility) sphereScreenRect(center vector.Vector, radius float64) screenRect {
+
+	viewCenter := vis.view.MultVec(center)
+
+	if -viewCenter[2]-radius <= vis.camera.Near {
+		return fullScreenRect
+	}
+
+	corners := make([]vector.Vector, 0, 8)
+
+	for _, x := range []float64{-radius, radius} {
+		for _, y := range []float64{-radius, radius} {
+			for _, z := range []float64{-radius, radius} {
+				corners = append(corners, vector.Vector{viewCenter[0] + x, viewCenter[1] + y, viewCenter[2] + z})
+			}
+		}
+	}
+
+	rect, _ := vis.camera.portalScreenRect(NewMatrix4(), corners)
+
+	return rect
+
+}
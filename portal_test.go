@@ -0,0 +1,174 @@
+package tetra3d
+
+import (
+	"math"
+	"testing"
+
+	"github.com/kvartborg/vector"
+)
+
+// newPortalScene creates a Scene with a corridor of three Rooms running down -Z (A, B, and C, connected by Portals), and a fourth Room (D)
+// behind Room A, connected to it by a Portal in A's back wall.
+func newPortalScene() *Scene {
+
+	scene := NewScene("Portals")
+
+	rooms := []struct {
+		name     string
+		min, max vector.Vector
+	}{
+		{"A", vector.Vector{-5, -5, -10}, vector.Vector{5, 5, 0}},
+		{"B", vector.Vector{-5, -5, -20}, vector.Vector{5, 5, -10}},
+		{"C", vector.Vector{-5, -5, -30}, vector.Vector{5, 5, -20}},
+		{"D", vector.Vector{-5, -5, 0}, vector.Vector{5, 5, 10}},
+	}
+
+	for _, room := range rooms {
+		scene.Root.AddChildren(NewRoom(room.name, Dimensions{room.min, room.max}))
+	}
+
+	// Each Portal is a 2x2 square in the wall between two Rooms.
+	for name, z := range map[string]float64{"AB": -10, "BC": -20, "AD": 0} {
+		scene.Root.AddChildren(NewPortal(name, vector.Vector{-1, -1, z}, vector.Vector{1, -1, z}, vector.Vector{1, 1, z}, vector.Vector{-1, 1, z}))
+	}
+
+	return scene
+
+}
+
+// visibleRooms returns the Rooms visible in the portal culling pass by name.
+func visibleRooms(vis *portalVisibility) map[string]*portalRoom {
+	rooms := map[string]*portalRoom{}
+	for _, pr := range vis.rooms {
+		if pr.visible {
+			rooms[pr.room.Name()] = pr
+		}
+	}
+	return rooms
+}
+
+func rectsEqual(a, b screenRect) bool {
+	return math.Abs(a.MinX-b.MinX) < 1e-6 && math.Abs(a.MinY-b.MinY) < 1e-6 && math.Abs(a.MaxX-b.MaxX) < 1e-6 && math.Abs(a.MaxY-b.MaxY) < 1e-6
+}
+
+func TestPortalCullingChainedRooms(t *testing.T) {
+
+	scene := newPortalScene()
+
+	camera := NewCamera(320, 180)
+	camera.SetLocalPosition(0, 0, -5)
+	scene.Root.AddChildren(camera)
+
+	vis := camera.portalCulling(scene)
+	if vis == nil {
+		t.Fatalf("the Camera is in Room A, so Rooms should be culled")
+	}
+
+	visible := visibleRooms(vis)
+
+	// D's Portal is behind the Camera, so D can't be seen.
+	if len(visible) != 3 || visible["A"] == nil || visible["B"] == nil || visible["C"] == nil {
+		t.Fatalf("expected Rooms A, B, and C to be visible, got %v", visible)
+	}
+
+	if visible["A"].rect != fullScreenRect {
+		t.Errorf("the Camera's Room should be visible across the whole screen, got %+v", visible["A"].rect)
+	}
+
+	ab, _ := camera.portalScreenRect(vis.view, scene.Root.Get("AB").(*Portal).WorldPoints())
+	bc, _ := camera.portalScreenRect(vis.view, scene.Root.Get("BC").(*Portal).WorldPoints())
+
+	if !rectsEqual(visible["B"].rect, ab) {
+		t.Errorf("Room B should be visible through Portal AB (%+v), got %+v", ab, visible["B"].rect)
+	}
+
+	// Portal BC is further away than AB, so it lies within it on screen, and C is visible through all of BC.
+	if bc.MinX < ab.MinX || bc.MinY < ab.MinY || bc.MaxX > ab.MaxX || bc.MaxY > ab.MaxY {
+		t.Fatalf("Portal BC (%+v) should be within Portal AB (%+v) on screen", bc, ab)
+	}
+
+	if !rectsEqual(visible["C"].rect, bc) {
+		t.Errorf("Room C should be visible through Portal BC (%+v), got %+v", bc, visible["C"].rect)
+	}
+
+	models := []struct {
+		name     string
+		position vector.Vector
+		visible  bool
+	}{
+		{"in the Camera's Room", vector.Vector{3, 0, -8}, true},
+		{"in C, through the Portals", vector.Vector{0, 0, -25}, true},
+		{"in C, off to the side of the Portals", vector.Vector{4, 0, -25}, false},
+		{"in D, behind the Camera", vector.Vector{0, 0, 5}, false},
+		{"outside of all Rooms", vector.Vector{0, 0, -50}, true},
+	}
+
+	for _, m := range models {
+		model := NewModel(NewCube(), "Cube")
+		model.SetLocalPositionVec(m.position)
+		model.Transform() // Updates the Model's BoundingSphere, as rendering does
+		if vis.modelVisible(model) != m.visible {
+			t.Errorf("a Model %s should be visible: %t", m.name, m.visible)
+		}
+	}
+
+	// Outside of all Rooms, nothing is culled.
+	camera.SetLocalPosition(0, 0, -50)
+	if camera.portalCulling(scene) != nil {
+		t.Errorf("a Camera outside of all Rooms shouldn't cull anything")
+	}
+
+}
+
+func TestPortalCullingPortalBehindCamera(t *testing.T) {
+
+	scene := newPortalScene()
+
+	camera := NewCamera(320, 180)
+	camera.SetLocalPosition(0, 0, -5)
+	scene.Root.AddChildren(camera)
+
+	if _, visible := camera.portalScreenRect(camera.ViewMatrix(), scene.Root.Get("AD").(*Portal).WorldPoints()); visible {
+		t.Errorf("Portal AD is behind the Camera, and shouldn't be visible")
+	}
+
+	// Turning around, D is visible, but B and C (behind the Camera now) aren't.
+	camera.SetLocalRotation(NewMatrix4Rotate(0, 1, 0, math.Pi))
+
+	visible := visibleRooms(camera.portalCulling(scene))
+
+	if len(visible) != 2 || visible["A"] == nil || visible["D"] == nil {
+		t.Errorf("expected Rooms A and D to be visible, got %v", visible)
+	}
+
+}
+
+func TestPortalCullingCameraInPortal(t *testing.T) {
+
+	scene := newPortalScene()
+
+	// The Camera stands in the middle of Portal AB, so the Portal is entirely on the near plane.
+	camera := NewCamera(320, 180)
+	camera.SetLocalPosition(0, 0, -10)
+	scene.Root.AddChildren(camera)
+
+	if rect, visible := camera.portalScreenRect(camera.ViewMatrix(), scene.Root.Get("AB").(*Portal).WorldPoints()); !visible || rect != fullScreenRect {
+		t.Errorf("a Portal the Camera is passing through should cover the whole screen, got %+v (visible: %t)", rect, visible)
+	}
+
+	visible := visibleRooms(camera.portalCulling(scene))
+
+	// Whichever of A and B the Camera is considered to be in, the other is visible across the whole screen through the Portal.
+	if visible["A"] == nil || visible["B"] == nil || visible["C"] == nil {
+		t.Fatalf("expected Rooms A, B, and C to be visible, got %v", visible)
+	}
+
+	if visible["A"].rect != fullScreenRect || visible["B"].rect != fullScreenRect {
+		t.Errorf("Rooms A and B should both be visible across the whole screen, got %+v and %+v", visible["A"].rect, visible["B"].rect)
+	}
+
+	if visible["D"] != nil {
+		t.Errorf("Room D is behind the Camera, and shouldn't be visible")
+	}
+
+}
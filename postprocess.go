@@ -0,0 +1,855 @@
+package tetra3d
+
+import (
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// IPostProcessEffect represents a post-processing effect, which is applied to a Camera's color texture after rendering by calling
+// Camera.PostProcess(). Effects are applied in the order they're found in the Camera's PostProcessEffects slice.
+type IPostProcessEffect interface {
+	Name() string          // Name returns the name of the effect.
+	IsActive() bool        // IsActive returns if the effect is active and should be applied.
+	SetActive(active bool) // SetActive sets whether the effect should be applied or not.
+
+	// Apply applies the effect, drawing the src image (the result of any previous effects) to the (cleared) dst image.
+	// Both images are the same size as the Camera's color texture.
+	Apply(camera *Camera, src, dst *ebiten.Image)
+
+	// Clone returns a copy of the effect that can be used by another Camera; the copy shouldn't share any state with the
+	// original that Apply() modifies (like intermediate images).
+	Clone() IPostProcessEffect
+}
+
+// postProcessBase is embedded in the built-in post-processing effects to fulfill the basic portions of IPostProcessEffect.
+type postProcessBase struct {
+	name    string
+	active  bool
+	buffers []*ebiten.Image
+}
+
+func newPostProcessBase(name string) postProcessBase {
+	return postProcessBase{name: name, active: true}
+}
+
+// Name returns the name of the effect.
+func (base *postProcessBase) Name() string {
+	return base.name
+}
+
+// IsActive returns if the effect is active and should be applied.
+func (base *postProcessBase) IsActive() bool {
+	return base.active
+}
+
+// SetActive sets whether the effect should be applied or not.
+func (base *postProcessBase) SetActive(active bool) {
+	base.active = active
+}
+
+// clone returns a copy of the postProcessBase without its intermediate images, which are created again as necessary.
+func (base *postProcessBase) clone() postProcessBase {
+	return postProcessBase{name: base.name, active: base.active}
+}
+
+// buffer returns an intermediate image at the given index that's the same size as the provided image, (re)creating it if necessary.
+func (base *postProcessBase) buffer(index int, sizeOf *ebiten.Image) *ebiten.Image {
+
+	for len(base.buffers) <= index {
+		base.buffers = append(base.buffers, nil)
+	}
+
+	w, h := sizeOf.Size()
+
+	if base.buffers[index] != nil {
+		if bw, bh := base.buffers[index].Size(); bw != w || bh != h {
+			base.buffers[index].Dispose()
+			base.buffers[index] = nil
+		}
+	}
+
+	if base.buffers[index] == nil {
+		base.buffers[index] = ebiten.NewImage(w, h)
+	}
+
+	base.buffers[index].Clear()
+
+	return base.buffers[index]
+
+}
+
+func mustCompileShader(src []byte) *ebiten.Shader {
+	shader, err := ebiten.NewShader(src)
+	if err != nil {
+		panic(err)
+	}
+	return shader
+}
+
+//---------------//
+
+// PostProcessShader is a post-processing effect that renders the Camera's color texture through a custom Kage fragment shader.
+// The shader can read the color texture through imageSrc0, the Camera's depth texture through imageSrc1 (if the Camera is rendering
+// depth), and the PostProcessShader's Images through imageSrc2 and imageSrc3.
+type PostProcessShader struct {
+	postProcessBase
+	Uniforms map[string]interface{} // Uniforms to pass to the shader.
+	Images   [2]*ebiten.Image       // Images to pass to the shader as imageSrc2 and imageSrc3; these must be the same size as the Camera's textures.
+	shader   *ebiten.Shader
+}
+
+// NewPostProcessShader creates a new PostProcessShader with the given name from the provided Kage shader source code.
+// It returns an error if the shader failed to compile.
+func NewPostProcessShader(name string, src []byte) (*PostProcessShader, error) {
+
+	shader, err := ebiten.NewShader(src)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PostProcessShader{
+		postProcessBase: newPostProcessBase(name),
+		Uniforms:        map[string]interface{}{},
+		shader:          shader,
+	}, nil
+
+}
+
+// Apply applies the effect, drawing the src image to the dst image.
+func (effect *PostProcessShader) Apply(camera *Camera, src, dst *ebiten.Image) {
+	w, h := src.Size()
+	dst.DrawRectShader(w, h, effect.shader, &ebiten.DrawRectShaderOptions{
+		Uniforms: effect.Uniforms,
+		Images:   [4]*ebiten.Image{src, camera.DepthTexture(), effect.Images[0], effect.Images[1]},
+	})
+}
+
+// Clone returns a copy of the PostProcessShader. The copy shares the original's shader and Images, but has its own Uniforms map.
+func (effect *PostProcessShader) Clone() IPostProcessEffect {
+	clone := *effect
+	clone.postProcessBase = effect.postProcessBase.clone()
+	clone.Uniforms = map[string]interface{}{}
+	for name, value := range effect.Uniforms {
+		clone.Uniforms[name] = value
+	}
+	return &clone
+}
+
+//---------------//
+
+// BloomEffect is a post-processing effect that makes bright areas of the screen glow.
+type BloomEffect struct {
+	postProcessBase
+	Threshold float32 // The brightness (from 0 to 1) above which colors start to bloom.
+	Strength  float32 // How strongly the glow is added to the screen.
+	Spread    float32 // The distance in pixels between blur samples; higher values give wider, but blockier, glows.
+	Passes    int     // The number of times the glow is blurred.
+
+	thresholdShader *ebiten.Shader
+	blurShader      *ebiten.Shader
+	addShader       *ebiten.Shader
+}
+
+// NewBloomEffect creates a new BloomEffect.
+func NewBloomEffect() *BloomEffect {
+
+	bloom := &BloomEffect{
+		postProcessBase: newPostProcessBase("Bloom"),
+		Threshold:       0.75,
+		Strength:        1,
+		Spread:          2,
+		Passes:          2,
+	}
+
+	bloom.thresholdShader = mustCompileShader([]byte(
+		`package main
+
+		var Threshold float
+
+		func Fragment(position vec4, texCoord vec2, color vec4) vec4 {
+			colorTex := imageSrc0At(texCoord)
+			brightness := dot(colorTex.rgb, vec3(0.2126, 0.7152, 0.0722))
+			return colorTex * smoothstep(Threshold, Threshold+0.1, brightness)
+		}
+
+		`,
+	))
+
+	bloom.blurShader = mustCompileShader(postProcessBlurShaderText)
+
+	bloom.addShader = mustCompileShader([]byte(
+		`package main
+
+		var Strength float
+
+		func Fragment(position vec4, texCoord vec2, color vec4) vec4 {
+			colorTex := imageSrc0At(texCoord)
+			colorTex.rgb += imageSrc1At(texCoord).rgb * Strength
+			return colorTex
+		}
+
+		`,
+	))
+
+	return bloom
+
+}
+
+// postProcessBlurShaderText is a separable gaussian blur, blurring along Direction (in pixels).
+var postProcessBlurShaderText = []byte(
+	`package main
+
+	var Direction vec2
+
+	// Samples are clamped to the image so that the edges of the screen don't blur with transparency.
+	func sample(pos vec2) vec4 {
+		origin, size := imageSrcRegionOnTexture()
+		halfTexel := 0.5 / imageSrcTextureSize()
+		return imageSrc0UnsafeAt(clamp(pos, origin+halfTexel, origin+size-halfTexel))
+	}
+
+	func Fragment(position vec4, texCoord vec2, color vec4) vec4 {
+		offset := Direction / imageSrcTextureSize()
+		sum := sample(texCoord) * 0.227027
+		sum += (sample(texCoord+offset) + sample(texCoord-offset)) * 0.1945946
+		sum += (sample(texCoord+offset*2) + sample(texCoord-offset*2)) * 0.1216216
+		sum += (sample(texCoord+offset*3) + sample(texCoord-offset*3)) * 0.054054
+		sum += (sample(texCoord+offset*4) + sample(texCoord-offset*4)) * 0.016216
+		return sum
+	}
+
+	`,
+)
+
+// Apply applies the effect, drawing the src image to the dst image.
+func (bloom *BloomEffect) Apply(camera *Camera, src, dst *ebiten.Image) {
+
+	w, h := src.Size()
+
+	bright := bloom.buffer(0, src)
+	bright.DrawRectShader(w, h, bloom.thresholdShader, &ebiten.DrawRectShaderOptions{
+		Uniforms: map[string]interface{}{"Threshold": bloom.Threshold},
+		Images:   [4]*ebiten.Image{src},
+	})
+
+	for i := 0; i < bloom.Passes; i++ {
+
+		blurred := bloom.buffer(1, src)
+		blurred.DrawRectShader(w, h, bloom.blurShader, &ebiten.DrawRectShaderOptions{
+			Uniforms: map[string]interface{}{"Direction": []float32{bloom.Spread, 0}},
+			Images:   [4]*ebiten.Image{bright},
+		})
+
+		bright.Clear()
+		bright.DrawRectShader(w, h, bloom.blurShader, &ebiten.DrawRectShaderOptions{
+			Uniforms: map[string]interface{}{"Direction": []float32{0, bloom.Spread}},
+			Images:   [4]*ebiten.Image{blurred},
+		})
+
+	}
+
+	dst.DrawRectShader(w, h, bloom.addShader, &ebiten.DrawRectShaderOptions{
+		Uniforms: map[string]interface{}{"Strength": bloom.Strength},
+		Images:   [4]*ebiten.Image{src, bright},
+	})
+
+}
+
+// Clone returns a copy of the BloomEffect.
+func (bloom *BloomEffect) Clone() IPostProcessEffect {
+	clone := *bloom
+	clone.postProcessBase = bloom.postProcessBase.clone()
+	return &clone
+}
+
+//---------------//
+
+// VignetteEffect is a post-processing effect that darkens (or colors) the edges of the screen.
+type VignetteEffect struct {
+	postProcessBase
+	Color    *Color  // The color the edges of the screen fade to; defaults to black.
+	Strength float32 // How strongly the edges of the screen are colored, from 0 to 1.
+	Radius   float32 // The distance from the center of the screen (with 1 being the corners) at which the vignette starts.
+	Softness float32 // The distance over which the vignette fades in.
+
+	shader *ebiten.Shader
+}
+
+// NewVignetteEffect creates a new VignetteEffect.
+func NewVignetteEffect() *VignetteEffect {
+
+	vignette := &VignetteEffect{
+		postProcessBase: newPostProcessBase("Vignette"),
+		Color:           NewColor(0, 0, 0, 1),
+		Strength:        0.75,
+		Radius:          0.5,
+		Softness:        0.5,
+	}
+
+	vignette.shader = mustCompileShader([]byte(
+		`package main
+
+		var Color vec3
+		var Strength float
+		var Radius float
+		var Softness float
+
+		func Fragment(position vec4, texCoord vec2, color vec4) vec4 {
+			origin, size := imageSrcRegionOnTexture()
+			screen := (texCoord - origin) / size
+			d := distance(screen, vec2(0.5)) * 1.4142136
+			colorTex := imageSrc0At(texCoord)
+			colorTex.rgb = mix(colorTex.rgb, Color*colorTex.a, smoothstep(Radius, Radius+Softness, d)*Strength)
+			return colorTex
+		}
+
+		`,
+	))
+
+	return vignette
+
+}
+
+// Apply applies the effect, drawing the src image to the dst image.
+func (vignette *VignetteEffect) Apply(camera *Camera, src, dst *ebiten.Image) {
+	w, h := src.Size()
+	dst.DrawRectShader(w, h, vignette.shader, &ebiten.DrawRectShaderOptions{
+		Uniforms: map[string]interface{}{
+			"Color":    []float32{vignette.Color.R, vignette.Color.G, vignette.Color.B},
+			"Strength": vignette.Strength,
+			"Radius":   vignette.Radius,
+			"Softness": vignette.Softness,
+		},
+		Images: [4]*ebiten.Image{src},
+	})
+}
+
+// Clone returns a copy of the VignetteEffect.
+func (vignette *VignetteEffect) Clone() IPostProcessEffect {
+	clone := *vignette
+	clone.postProcessBase = vignette.postProcessBase.clone()
+	clone.Color = vignette.Color.Clone()
+	return &clone
+}
+
+//---------------//
+
+// ColorGradingEffect is a post-processing effect that remaps the colors of the screen using a color lookup table (LUT).
+type ColorGradingEffect struct {
+	postProcessBase
+	// LUT is the color lookup table. It should be a horizontal strip of square slices, one for each blue value, with red increasing
+	// to the right and green increasing downwards in each slice (i.e. a 256x16 image for a 16-color LUT). The Camera's texture must
+	// be at least as large as the LUT.
+	LUT      *ebiten.Image
+	Strength float32 // How strongly the graded colors replace the original colors, from 0 to 1.
+
+	shader *ebiten.Shader
+}
+
+// NewColorGradingEffect creates a new ColorGradingEffect using the color lookup table provided.
+func NewColorGradingEffect(lut *ebiten.Image) *ColorGradingEffect {
+
+	grading := &ColorGradingEffect{
+		postProcessBase: newPostProcessBase("Color Grading"),
+		LUT:             lut,
+		Strength:        1,
+	}
+
+	grading.shader = mustCompileShader([]byte(
+		`package main
+
+		var LUTSize float
+		var Strength float
+
+		func lookup(slice float, rg vec2) vec3 {
+			origin, _ := imageSrcRegionOnTexture()
+			pixel := vec2(slice*LUTSize, 0) + rg*(LUTSize-1) + 0.5
+			return imageSrc1At(origin + pixel/imageSrcTextureSize()).rgb
+		}
+
+		func Fragment(position vec4, texCoord vec2, color vec4) vec4 {
+
+			colorTex := imageSrc0At(texCoord)
+
+			if colorTex.a == 0 {
+				return colorTex
+			}
+
+			original := clamp(colorTex.rgb/colorTex.a, 0, 1)
+
+			blue := original.b * (LUTSize - 1)
+			slice := floor(blue)
+
+			graded := mix(lookup(slice, original.rg), lookup(min(slice+1, LUTSize-1), original.rg), blue-slice)
+
+			return vec4(mix(original, graded, Strength)*colorTex.a, colorTex.a)
+
+		}
+
+		`,
+	))
+
+	return grading
+
+}
+
+// Apply applies the effect, drawing the src image to the dst image.
+func (grading *ColorGradingEffect) Apply(camera *Camera, src, dst *ebiten.Image) {
+
+	if grading.LUT == nil {
+		dst.DrawImage(src, nil)
+		return
+	}
+
+	// Shader images have to be the same size, so the LUT is copied to the corner of an image the size of the screen.
+	lut := grading.buffer(0, src)
+	lut.DrawImage(grading.LUT, nil)
+
+	w, h := src.Size()
+	dst.DrawRectShader(w, h, grading.shader, &ebiten.DrawRectShaderOptions{
+		Uniforms: map[string]interface{}{
+			"LUTSize":  float32(grading.LUT.Bounds().Dy()),
+			"Strength": grading.Strength,
+		},
+		Images: [4]*ebiten.Image{src, lut},
+	})
+
+}
+
+// Clone returns a copy of the ColorGradingEffect. The copy shares the original's LUT.
+func (grading *ColorGradingEffect) Clone() IPostProcessEffect {
+	clone := *grading
+	clone.postProcessBase = grading.postProcessBase.clone()
+	return &clone
+}
+
+//---------------//
+
+// PosterizeEffect is a post-processing effect that reduces the number of colors on screen, optionally dithering between them for a retro look.
+type PosterizeEffect struct {
+	postProcessBase
+	Levels float32 // The number of levels for each color channel.
+	Dither float32 // The strength of the ordered (Bayer) dithering between color levels, from 0 (none) to 1.
+
+	shader *ebiten.Shader
+}
+
+// NewPosterizeEffect creates a new PosterizeEffect with the given number of color levels for each channel.
+func NewPosterizeEffect(levels float32) *PosterizeEffect {
+
+	posterize := &PosterizeEffect{
+		postProcessBase: newPostProcessBase("Posterize"),
+		Levels:          levels,
+		Dither:          1,
+	}
+
+	posterize.shader = mustCompileShader([]byte(
+		`package main
+
+		var Levels float
+		var Dither float
+
+		func bayer2(a vec2) float {
+			f := floor(a)
+			return fract(dot(f, vec2(0.5, f.y*0.75)))
+		}
+
+		func bayer4(a vec2) float {
+			return bayer2(a*0.5)*0.25 + bayer2(a)
+		}
+
+		func Fragment(position vec4, texCoord vec2, color vec4) vec4 {
+
+			colorTex := imageSrc0At(texCoord)
+
+			if colorTex.a == 0 {
+				return colorTex
+			}
+
+			steps := max(Levels-1, 1)
+			threshold := (bayer4(position.xy) - 0.5) * Dither
+			rgb := floor(colorTex.rgb/colorTex.a*steps + 0.5 + threshold) / steps
+
+			return vec4(clamp(rgb, 0, 1)*colorTex.a, colorTex.a)
+
+		}
+
+		`,
+	))
+
+	return posterize
+
+}
+
+// Apply applies the effect, drawing the src image to the dst image.
+func (posterize *PosterizeEffect) Apply(camera *Camera, src, dst *ebiten.Image) {
+	w, h := src.Size()
+	dst.DrawRectShader(w, h, posterize.shader, &ebiten.DrawRectShaderOptions{
+		Uniforms: map[string]interface{}{
+			"Levels": posterize.Levels,
+			"Dither": posterize.Dither,
+		},
+		Images: [4]*ebiten.Image{src},
+	})
+}
+
+// Clone returns a copy of the PosterizeEffect.
+func (posterize *PosterizeEffect) Clone() IPostProcessEffect {
+	clone := *posterize
+	clone.postProcessBase = posterize.postProcessBase.clone()
+	return &clone
+}
+
+//---------------//
+
+// postProcessDepthShaderFuncs decodes depth from the Camera's depth texture; areas where nothing has been rendered are treated as being at the far plane.
+// decodeDistance() converts the depth to the distance from the Camera's plane, using the DepthToDistance uniform (see depthToDistanceUniform()).
+const postProcessDepthShaderFuncs = `
+	var DepthToDistance vec2
+
+	func decodeDepth(rgba vec4) float {
+		if rgba.a == 0 {
+			return 1
+		}
+		return rgba.r + (rgba.g / 255) + (rgba.b / 65025)
+	}
+
+	func decodeDistance(rgba vec4) float {
+		return decodeDepth(rgba)*DepthToDistance.x + DepthToDistance.y
+	}
+`
+
+// depthToDistanceUniform returns the value of the DepthToDistance uniform used by postProcessDepthShaderFuncs for the given Camera.
+func depthToDistanceUniform(camera *Camera) []float32 {
+	scale, offset := camera.depthToDistance()
+	return []float32{float32(scale), float32(offset)}
+}
+
+// DepthOfFieldEffect is a post-processing effect that blurs the screen the further it is from the focus distance. It requires the
+// Camera to render depth.
+type DepthOfFieldEffect struct {
+	postProcessBase
+	FocusDistance float64 // The distance from the Camera, in world units, that is in focus.
+	FocusRange    float64 // The distance from the focus distance, in world units, over which the screen becomes fully blurred.
+	BlurSize      float32 // The maximum blur radius, in pixels.
+
+	shader *ebiten.Shader
+}
+
+// NewDepthOfFieldEffect creates a new DepthOfFieldEffect that focuses at the given distance.
+func NewDepthOfFieldEffect(focusDistance float64) *DepthOfFieldEffect {
+
+	dof := &DepthOfFieldEffect{
+		postProcessBase: newPostProcessBase("Depth of Field"),
+		FocusDistance:   focusDistance,
+		FocusRange:      10,
+		BlurSize:        4,
+	}
+
+	dof.shader = mustCompileShader([]byte(
+		`package main
+
+		var FocusDistance float
+		var FocusRange float
+		var BlurSize float
+		` + postProcessDepthShaderFuncs + `
+		func Fragment(position vec4, texCoord vec2, color vec4) vec4 {
+
+			dist := decodeDistance(imageSrc1At(texCoord))
+			blur := clamp(abs(dist-FocusDistance)/FocusRange, 0, 1) * BlurSize / imageSrcTextureSize()
+
+			sum := imageSrc0At(texCoord)
+
+			for i := 0; i < 12; i++ {
+				angle := float(i) * 0.5235988
+				offset := vec2(cos(angle), sin(angle)) * blur
+				sum += imageSrc0At(texCoord+offset) + imageSrc0At(texCoord+offset*0.5)
+			}
+
+			return sum / 25
+
+		}
+
+		`,
+	))
+
+	return dof
+
+}
+
+// Apply applies the effect, drawing the src image to the dst image.
+func (dof *DepthOfFieldEffect) Apply(camera *Camera, src, dst *ebiten.Image) {
+
+	w, h := src.Size()
+	dst.DrawRectShader(w, h, dof.shader, &ebiten.DrawRectShaderOptions{
+		Uniforms: map[string]interface{}{
+			"FocusDistance":   float32(dof.FocusDistance),
+			"FocusRange":      float32(dof.FocusRange),
+			"BlurSize":        dof.BlurSize,
+			"DepthToDistance": depthToDistanceUniform(camera),
+		},
+		Images: [4]*ebiten.Image{src, camera.DepthTexture()},
+	})
+
+}
+
+// Clone returns a copy of the DepthOfFieldEffect.
+func (dof *DepthOfFieldEffect) Clone() IPostProcessEffect {
+	clone := *dof
+	clone.postProcessBase = dof.postProcessBase.clone()
+	return &clone
+}
+
+//---------------//
+
+// OutlineEffect is a post-processing effect that draws outlines where the depth of the screen changes sharply (i.e. around the
+// silhouettes of objects). It requires the Camera to render depth.
+type OutlineEffect struct {
+	postProcessBase
+	Color          *Color  // The color of the outlines.
+	Thickness      float32 // The thickness of the outlines, in pixels.
+	DepthThreshold float64 // The difference in depth, in world units, above which an outline is drawn.
+
+	shader *ebiten.Shader
+}
+
+// NewOutlineEffect creates a new OutlineEffect.
+func NewOutlineEffect() *OutlineEffect {
+
+	outline := &OutlineEffect{
+		postProcessBase: newPostProcessBase("Outline"),
+		Color:           NewColor(0, 0, 0, 1),
+		Thickness:       1,
+		DepthThreshold:  0.5,
+	}
+
+	outline.shader = mustCompileShader([]byte(
+		`package main
+
+		var Color vec4
+		var Thickness float
+		var Threshold float
+		` + postProcessDepthShaderFuncs + `
+		func Fragment(position vec4, texCoord vec2, color vec4) vec4 {
+
+			offset := Thickness / imageSrcTextureSize()
+			dist := decodeDistance(imageSrc1At(texCoord))
+
+			diff := abs(dist - decodeDistance(imageSrc1At(texCoord+vec2(offset.x, 0))))
+			diff = max(diff, abs(dist-decodeDistance(imageSrc1At(texCoord-vec2(offset.x, 0)))))
+			diff = max(diff, abs(dist-decodeDistance(imageSrc1At(texCoord+vec2(0, offset.y)))))
+			diff = max(diff, abs(dist-decodeDistance(imageSrc1At(texCoord-vec2(0, offset.y)))))
+
+			colorTex := imageSrc0At(texCoord)
+
+			if diff > Threshold {
+				return mix(colorTex, vec4(Color.rgb, 1), Color.a)
+			}
+
+			return colorTex
+
+		}
+
+		`,
+	))
+
+	return outline
+
+}
+
+// Apply applies the effect, drawing the src image to the dst image.
+func (outline *OutlineEffect) Apply(camera *Camera, src, dst *ebiten.Image) {
+
+	w, h := src.Size()
+	dst.DrawRectShader(w, h, outline.shader, &ebiten.DrawRectShaderOptions{
+		Uniforms: map[string]interface{}{
+			"Color":           []float32{outline.Color.R, outline.Color.G, outline.Color.B, outline.Color.A},
+			"Thickness":       outline.Thickness,
+			"Threshold":       float32(outline.DepthThreshold),
+			"DepthToDistance": depthToDistanceUniform(camera),
+		},
+		Images: [4]*ebiten.Image{src, camera.DepthTexture()},
+	})
+
+}
+
+// Clone returns a copy of the OutlineEffect.
+func (outline *OutlineEffect) Clone() IPostProcessEffect {
+	clone := *outline
+	clone.postProcessBase = outline.postProcessBase.clone()
+	clone.Color = outline.Color.Clone()
+	return &clone
+}
+
+//---------------//
+
+// SSAOEffect is a post-processing effect that darkens creases, corners, and contact points between objects by estimating
+// ambient occlusion from the Camera's depth texture (screen-space ambient occlusion). Unlike Model.BakeAO(), it works
+// for moving objects as well. It requires the Camera to render depth.
+type SSAOEffect struct {
+	postProcessBase
+	Radius      float64 // The radius, in world units, around each point that is checked for occluding geometry.
+	Strength    float32 // How strongly occluded areas are darkened, from 0 to 1.
+	SampleCount int     // The number of depth samples taken around each pixel to estimate occlusion (up to 32). More samples give smoother results at a higher cost.
+	Bias        float64 // The minimum difference in depth, in world units, for a sample to count as occluding; raise this to reduce self-shadowing.
+	BlurPasses  int     // The number of times the occlusion is blurred before being applied.
+
+	aoShader        *ebiten.Shader
+	blurShader      *ebiten.Shader
+	compositeShader *ebiten.Shader
+}
+
+// NewSSAOEffect creates a new SSAOEffect.
+func NewSSAOEffect() *SSAOEffect {
+
+	ssao := &SSAOEffect{
+		postProcessBase: newPostProcessBase("SSAO"),
+		Radius:          1,
+		Strength:        0.75,
+		SampleCount:     12,
+		Bias:            0.05,
+		BlurPasses:      1,
+	}
+
+	ssao.aoShader = mustCompileShader([]byte(
+		`package main
+
+		var Radius float
+		var Bias float
+		var Strength float
+		var SampleCount float
+		var PixelScale float
+		var Perspective float
+		` + postProcessDepthShaderFuncs + `
+		func Fragment(position vec4, texCoord vec2, color vec4) vec4 {
+
+			depthTex := imageSrc0At(texCoord)
+
+			if depthTex.a == 0 {
+				return vec4(1)
+			}
+
+			depth := decodeDistance(depthTex)
+
+			// Objects further away cover fewer pixels, so the sampling radius shrinks with distance.
+			radius := Radius * PixelScale
+			if Perspective > 0 {
+				radius /= max(depth, 0.001)
+			}
+
+			texelSize := 1 / imageSrcTextureSize()
+
+			// Each pixel's samples are rotated a bit differently to trade banding for noise, which is then blurred away.
+			rotation := fract(sin(dot(floor(position.xy), vec2(12.9898, 78.233))) * 43758.5453) * 6.2831853
+
+			occlusion := 0.0
+
+			for i := 0; i < 32; i++ {
+
+				if float(i) >= SampleCount {
+					break
+				}
+
+				angle := float(i)*2.3999632 + rotation
+				dist := sqrt((float(i) + 0.5) / SampleCount) * radius
+				sampleDepth := decodeDistance(imageSrc0At(texCoord+vec2(cos(angle), sin(angle))*dist*texelSize))
+
+				diff := depth - sampleDepth
+
+				if diff > Bias {
+					occlusion += 1 - smoothstep(0, 1, diff/Radius-1)
+				}
+
+			}
+
+			ao := 1 - clamp(occlusion/SampleCount*Strength, 0, 1)
+
+			return vec4(ao, ao, ao, 1)
+
+		}
+
+		`,
+	))
+
+	ssao.blurShader = mustCompileShader(postProcessBlurShaderText)
+
+	ssao.compositeShader = mustCompileShader([]byte(
+		`package main
+
+		func Fragment(position vec4, texCoord vec2, color vec4) vec4 {
+			colorTex := imageSrc0At(texCoord)
+			colorTex.rgb *= imageSrc1At(texCoord).r
+			return colorTex
+		}
+
+		`,
+	))
+
+	return ssao
+
+}
+
+// Apply applies the effect, drawing the src image to the dst image.
+func (ssao *SSAOEffect) Apply(camera *Camera, src, dst *ebiten.Image) {
+
+	w, h := src.Size()
+
+	// Clip space spans 2 units from the bottom of the screen to the top, so one world unit covers projection[1][1] * h / 2 pixels
+	// (divided by the clip-space W, which is proportional to the depth, in perspective).
+	projection := camera.Projection()
+	pixelScale := projection[1][1] * float64(h) / 2
+	perspective := float32(0)
+
+	if camera.Perspective {
+		pixelScale /= 2 * camera.Far * camera.Near / (camera.Far - camera.Near)
+		perspective = 1
+	}
+
+	sampleCount := ssao.SampleCount
+	if sampleCount < 1 {
+		sampleCount = 1
+	} else if sampleCount > 32 {
+		sampleCount = 32
+	}
+
+	ao := ssao.buffer(0, src)
+	ao.DrawRectShader(w, h, ssao.aoShader, &ebiten.DrawRectShaderOptions{
+		Uniforms: map[string]interface{}{
+			"Radius":          float32(ssao.Radius),
+			"Bias":            float32(ssao.Bias),
+			"Strength":        ssao.Strength,
+			"SampleCount":     float32(sampleCount),
+			"PixelScale":      float32(pixelScale),
+			"Perspective":     perspective,
+			"DepthToDistance": depthToDistanceUniform(camera),
+		},
+		Images: [4]*ebiten.Image{camera.DepthTexture()},
+	})
+
+	for i := 0; i < ssao.BlurPasses; i++ {
+
+		blurred := ssao.buffer(1, src)
+		blurred.DrawRectShader(w, h, ssao.blurShader, &ebiten.DrawRectShaderOptions{
+			Uniforms: map[string]interface{}{"Direction": []float32{1, 0}},
+			Images:   [4]*ebiten.Image{ao},
+		})
+
+		ao.Clear()
+		ao.DrawRectShader(w, h, ssao.blurShader, &ebiten.DrawRectShaderOptions{
+			Uniforms: map[string]interface{}{"Direction": []float32{0, 1}},
+			Images:   [4]*ebiten.Image{blurred},
+		})
+
+	}
+
+	dst.DrawRectShader(w, h, ssao.compositeShader, &ebiten.DrawRectShaderOptions{
+		Images: [4]*ebiten.Image{src, ao},
+	})
+
+}
+
+// Clone returns a copy of the SSAOEffect.
+func (ssao *SSAOEffect) Clone() IPostProcessEffect {
+	clone := *ssao
+	clone.postProcessBase = ssao.postProcessBase.clone()
+	return &clone
+}
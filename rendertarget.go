@@ -0,0 +1,217 @@
+package tetra3d
+
+import (
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// RenderTarget represents a Camera whose view of a Scene is displayed on Materials, which is useful for security camera monitors,
+// mirrors, or portals. To display a RenderTarget's view on a Material, set the Material's RenderTarget field. When a Camera renders
+// Models using such Materials, the RenderTarget's Camera renders the Scene first, and the Materials' Textures are set to the result.
+//
+// RenderTargets can see other RenderTargets (or themselves, in the case of a mirror that can see its own reflection); in this case,
+// the innermost views are rendered first, up to the RenderTarget's MaxDepth.
+type RenderTarget struct {
+	Camera   *Camera // The Camera used to render the RenderTarget's view.
+	RootNode INode   // The root Node of the Nodes the Camera renders; if nil, the root of the Scene being rendered is used.
+	Active   bool    // Whether the RenderTarget is updated when rendering; if it isn't, Materials display its last view.
+
+	// MaxDepth is the maximum number of times the RenderTarget can be seen within its own view or the views of other RenderTargets
+	// (like a mirror reflecting a mirror). Each level of depth requires rendering the RenderTarget's view again. Past the maximum depth,
+	// the view is blank. Defaults to 1, meaning that views of the RenderTarget within other RenderTargets' views are blank.
+	MaxDepth int
+
+	levels []*ebiten.Image
+}
+
+// NewRenderTarget creates a new RenderTarget that displays the view of the provided Camera.
+func NewRenderTarget(camera *Camera) *RenderTarget {
+	return &RenderTarget{
+		Camera:   camera,
+		Active:   true,
+		MaxDepth: 1,
+	}
+}
+
+// Texture returns the texture holding the RenderTarget's view; this is the texture that Materials using the RenderTarget display.
+func (rt *RenderTarget) Texture() *ebiten.Image {
+	return rt.level(0)
+}
+
+// level returns the texture holding the RenderTarget's view at the given depth, creating it (or recreating it if the Camera's size
+// changed) if necessary.
+func (rt *RenderTarget) level(depth int) *ebiten.Image {
+
+	for len(rt.levels) <= depth {
+		rt.levels = append(rt.levels, nil)
+	}
+
+	w, h := rt.Camera.ColorTexture().Size()
+
+	if rt.levels[depth] != nil {
+		if lw, lh := rt.levels[depth].Size(); lw != w || lh != h {
+			rt.levels[depth].Dispose()
+			rt.levels[depth] = nil
+		}
+	}
+
+	if rt.levels[depth] == nil {
+		rt.levels[depth] = ebiten.NewImage(w, h)
+	}
+
+	return rt.levels[depth]
+
+}
+
+// renderTargetPass holds the state for rendering the RenderTargets visible to a Camera before the Camera renders.
+type renderTargetPass struct {
+	scene    *Scene
+	rendered map[*RenderTarget]map[int]bool
+}
+
+// renderTargetPassActive is true while rendering RenderTargets, so that the Cameras rendering them don't start passes of their own.
+var renderTargetPassActive = false
+
+// renderTargets renders the views of any RenderTargets used by the Materials of the given Models, and sets those Materials' Textures
+// to the results.
+func renderTargets(scene *Scene, models []*Model) {
+
+	if renderTargetPassActive {
+		return
+	}
+
+	materials := renderTargetMaterials(models)
+
+	if len(materials) == 0 {
+		return
+	}
+
+	renderTargetPassActive = true
+
+	pass := &renderTargetPass{
+		scene:    scene,
+		rendered: map[*RenderTarget]map[int]bool{},
+	}
+
+	for rt := range materials {
+		pass.render(rt, 0)
+	}
+
+	for rt, mats := range materials {
+		if rt.Camera == nil {
+			continue
+		}
+		for _, mat := range mats {
+			mat.Texture = rt.Texture()
+		}
+	}
+
+	renderTargetPassActive = false
+
+}
+
+// renderTargetMaterials returns the Materials of the given Models that use RenderTargets, organized by RenderTarget.
+func renderTargetMaterials(models []*Model) map[*RenderTarget][]*Material {
+
+	materials := map[*RenderTarget][]*Material{}
+
+	addModel := func(model *Model) {
+		if model.Mesh == nil {
+			return
+		}
+		for _, mp := range model.Mesh.MeshParts {
+			if mp.Material != nil && mp.Material.RenderTarget != nil {
+				materials[mp.Material.RenderTarget] = append(materials[mp.Material.RenderTarget], mp.Material)
+			}
+		}
+	}
+
+	for _, model := range models {
+
+		if !model.visible {
+			continue
+		}
+
+		addModel(model)
+
+		for _, batched := range model.DynamicBatchModels {
+			for _, child := range batched {
+				if child.visible {
+					addModel(child)
+				}
+			}
+		}
+
+	}
+
+	return materials
+
+}
+
+// render renders the RenderTarget's view at the given depth, first rendering any RenderTargets visible in the view one level deeper.
+func (pass *renderTargetPass) render(rt *RenderTarget, depth int) {
+
+	if !rt.Active || depth >= rt.MaxDepth || rt.Camera == nil {
+		return
+	}
+
+	if pass.rendered[rt] == nil {
+		pass.rendered[rt] = map[int]bool{}
+	}
+
+	if pass.rendered[rt][depth] {
+		return
+	}
+
+	pass.rendered[rt][depth] = true
+
+	root := rt.RootNode
+	if root == nil {
+		root = pass.scene.Root
+	}
+
+	models := []*Model{}
+
+	if model, isModel := root.(*Model); isModel {
+		models = append(models, model)
+	}
+
+	for _, node := range root.ChildrenRecursive() {
+		if model, ok := node.(*Model); ok && model.DynamicBatchOwner == nil {
+			models = append(models, model)
+		}
+	}
+
+	visible := renderTargetMaterials(models)
+
+	for other := range visible {
+		pass.render(other, depth+1)
+	}
+
+	// Views that are too deep to render are displayed as blank.
+	for other, mats := range visible {
+		if other.Camera == nil {
+			continue
+		}
+		var texture *ebiten.Image
+		if !other.Active {
+			texture = other.Texture()
+		} else if depth+1 < other.MaxDepth {
+			texture = other.level(depth + 1)
+		} else {
+			texture = other.level(other.MaxDepth)
+			texture.Clear()
+		}
+		for _, mat := range mats {
+			mat.Texture = texture
+		}
+	}
+
+	rt.Camera.Clear()
+	rt.Camera.Render(pass.scene, models...)
+	rt.Camera.PostProcess()
+
+	result := rt.level(depth)
+	result.Clear()
+	result.DrawImage(rt.Camera.ColorTexture(), nil)
+
+}
@@ -12,6 +12,13 @@ var defaultImg = ebiten.NewImage(1, 1)
 
 var colorVertexList = make([]ebiten.Vertex, ebiten.MaxIndicesNum)
 var depthVertexList = make([]ebiten.Vertex, ebiten.MaxIndicesNum)
+var normalVertexList = make([]ebiten.Vertex, ebiten.MaxIndicesNum)
+var viewDepthVertexList = make([]ebiten.Vertex, ebiten.MaxIndicesNum)
+var vertexLightVertexList = make([]ebiten.Vertex, ebiten.MaxIndicesNum)
+var tangentVertexList = make([]ebiten.Vertex, ebiten.MaxIndicesNum)
+var bitangentVertexList = make([]ebiten.Vertex, ebiten.MaxIndicesNum)
+var uvVertexList = make([]ebiten.Vertex, ebiten.MaxIndicesNum)
+var texturedVertexList = make([]ebiten.Vertex, ebiten.MaxIndicesNum)
 var indexList = make([]uint16, ebiten.MaxIndicesNum)
 var vertexListIndex = 0
 
@@ -18,6 +18,8 @@ bl_info = {
 objectTypes = [
     ("MESH", "Mesh", "A standard, visible mesh object", 0, 0),
     ("GRID", "Grid", "A grid object; not visualized or 'physically present'. The vertices in Blender become grid points in Tetra3D; the edges become their connections", 0, 1),
+    ("ROOM", "Room", "A room object for portal culling; not visualized or 'physically present'. The mesh's bounding box becomes the room's volume; when the camera is in a room, only objects in rooms visible through portals are rendered", 0, 2),
+    ("PORTAL", "Portal", "A portal object for portal culling; not visualized or 'physically present'. The mesh's first face becomes an opening between the rooms whose volumes contain its center, like a doorway or window", 0, 3),
 ]
 
 boundsTypes = [
@@ -28,6 +30,11 @@ boundsTypes = [
     ("TRIANGLES", "Triangle Mesh", "A triangle mesh bounds type. Only works on mesh-type objects (i.e. an Empty won't generate a BoundingTriangles). Accurate, but slow. Currently buggy when resolving intersections between AABB or other Triangle Nodes", 0, 4),
 ]
 
+lodModes = [
+    ("DISTANCE", "Distance", "Levels of detail are chosen by the distance from the camera to the object", 0, 0),
+    ("SCREEN_SIZE", "Screen Size", "Levels of detail are chosen by the object's size on screen, with 1 being the full height of the screen", 0, 1),
+]
+
 gltfExportTypes = [
     ("GLB", ".glb", "Exports a single file, with all data packed in binary form. Most efficient and portable, but more difficult to edit later", 0, 0),
     ("GLTF_SEPARATE", ".gltf + .bin + textures", "Exports multiple files, with separate JSON, binary and texture data. Easiest to edit later - Note that Tetra3D doesn't support this properly currently", 0, 1),
@@ -47,6 +54,11 @@ materialBillboardModes = [
     ("FULL", "Full", "Full billboarding - the (unskinned) object rotates fully to face the camera.", 0, 2),
 ]
 
+materialLightingModes = [
+    ("VERTEX", "Vertex", "Per-vertex lighting - lighting is calculated for each vertex and blended across triangles. Fastest, but low-poly meshes light coarsely.", 0, 0),
+    ("PIXEL", "Pixel", "Per-pixel lighting - point, spot, and sun lights are calculated for each pixel on the GPU, so lighting is smooth regardless of mesh density.", 0, 1),
+]
+
 worldFogCompositeModes = [
     ("OFF", "Off", "No fog. Object colors aren't changed with distance from the camera", 0, 0),
     ("ADDITIVE", "Additive", "Additive fog - this fog mode brightens objects in the distance, with full effect being adding the color given to the object's color at maximum distance (according to the camera's far range)", 0, 1),
@@ -55,6 +67,19 @@ worldFogCompositeModes = [
     ("TRANSPARENT", "Transparent", "Transparent fog - this fog mode fades the object out over distance, such that at maximum distance / fog range, the object is wholly transparent.", 0, 4),
 ]
 
+worldFogCurves = [
+    ("LINEAR", "Linear", "Linear fog - the fog increases smoothly from the start to the end of the fog range", 0, 0),
+    ("EXPONENTIAL", "Exponential", "Exponential fog - the fog increases exponentially with distance from the camera, according to the fog density. Requires the camera to render depth", 0, 1),
+    ("EXPONENTIAL_SQUARED", "Exponential Squared", "Exponential squared fog - like exponential fog, but clearer close to the camera before thickening further away. Requires the camera to render depth", 0, 2),
+]
+
+worldSkyModes = [
+    ("OFF", "Off", "No sky. Anywhere objects don't render is left transparent", 0, 0),
+    ("GRADIENT", "Gradient", "Gradient sky - the sky is a vertical gradient from the bottom color to the horizon color to the top color, with an optional sun disc", 0, 1),
+    ("EQUIRECTANGULAR", "Equirectangular", "Equirectangular sky - the sky is an equirectangular (latitude / longitude) panorama image, like Blender's environment textures", 0, 2),
+    ("CUBEMAP", "Cubemap", "Cubemap sky - the sky is a cubemap image, composed of six square faces laid out horizontally in +X, -X, +Y, -Y, +Z, -Z order (in Y-up coordinates)", 0, 3),
+]
+
 gamePropTypes = [
     ("bool", "Bool", "Boolean data type", 0, 0),
     ("int", "Int", "Int data type", 0, 1),
@@ -269,6 +294,19 @@ class OBJECT_PT_tetra3d(bpy.types.Panel):
             row.prop(context.object, "t3dTrianglesCustomBroadphaseEnabled__")
             if context.object.t3dTrianglesCustomBroadphaseEnabled__:
                 row.prop(context.object, "t3dTrianglesCustomBroadphaseGridSize__")
+
+        if context.object.type == "MESH" and "_LOD" in context.object.name:
+            box = self.layout.box()
+            row = box.row()
+            row.label(text="Level of Detail (for objects named with an _LOD<number> suffix): ")
+            row = box.row()
+            row.prop(context.object, "t3dLODThreshold__")
+            row = box.row()
+            row.label(text="Used on the most detailed level: ")
+            row = box.row()
+            row.prop(context.object, "t3dLODMode__")
+            row.prop(context.object, "t3dLODHysteresis__")
+
         row = self.layout.row()
         row.separator()
         row = self.layout.row()
@@ -337,6 +375,7 @@ class MATERIAL_PT_tetra3d(bpy.types.Panel):
         # row.operator("image.open")
         row = self.layout.row()
         row.prop(context.material, "t3dMaterialShadeless__")
+        row.prop(context.material, "t3dMaterialFogless__")
         row.prop(context.material, "use_backface_culling")
         row = self.layout.row()
         row.prop(context.material, "blend_method")
@@ -344,6 +383,8 @@ class MATERIAL_PT_tetra3d(bpy.types.Panel):
         row.prop(context.material, "t3dCompositeMode__")
         row = self.layout.row()
         row.prop(context.material, "t3dBillboardMode__")
+        row = self.layout.row()
+        row.prop(context.material, "t3dLightingMode__")
 
 class WORLD_PT_tetra3d(bpy.types.Panel):
     bl_idname = "WORLD_PT_tetra3d"
@@ -368,8 +409,32 @@ class WORLD_PT_tetra3d(bpy.types.Panel):
             if context.world.t3dFogMode__ != "TRANSPARENT":
                 box.prop(context.world, "t3dFogColor__")
             
-            box.prop(context.world, "t3dFogRangeStart__", slider=True)
-            box.prop(context.world, "t3dFogRangeEnd__", slider=True)
+            box.prop(context.world, "t3dFogCurve__")
+
+            if context.world.t3dFogCurve__ == "LINEAR":
+                box.prop(context.world, "t3dFogRangeStart__", slider=True)
+                box.prop(context.world, "t3dFogRangeEnd__", slider=True)
+            else:
+                box.prop(context.world, "t3dFogDensity__")
+
+            box.prop(context.world, "t3dFogHeightFalloff__")
+            if context.world.t3dFogHeightFalloff__ > 0:
+                box.prop(context.world, "t3dFogHeight__")
+
+        box = self.layout.box()
+        row = box.row()
+        row.prop(context.world, "t3dSkyMode__")
+
+        if context.world.t3dSkyMode__ == "GRADIENT":
+            box.prop(context.world, "t3dSkyTopColor__")
+            box.prop(context.world, "t3dSkyHorizonColor__")
+            box.prop(context.world, "t3dSkyBottomColor__")
+            box.prop(context.world, "t3dSunSize__")
+            if context.world.t3dSunSize__ > 0:
+                box.prop(context.world, "t3dSunColor__")
+                box.prop(context.world, "t3dSunDirection__")
+        elif context.world.t3dSkyMode__ != "OFF":
+            box.prop(context.world, "t3dSkyTexture__")
         
 # The idea behind "globalget and set" is that we're setting properties on the first scene (which must exist), and getting any property just returns the first one from that scene
 def globalGet(propName):
@@ -482,6 +547,32 @@ def export():
             worldData["fog range start"] = world.t3dFogRangeStart__
         if "t3dFogRangeEnd__" in world:
             worldData["fog range end"] = world.t3dFogRangeEnd__
+        if "t3dFogCurve__" in world:
+            worldData["fog curve"] = world.t3dFogCurve__
+        if "t3dFogDensity__" in world:
+            worldData["fog density"] = world.t3dFogDensity__
+        if "t3dFogHeight__" in world:
+            worldData["fog height"] = world.t3dFogHeight__
+        if "t3dFogHeightFalloff__" in world:
+            worldData["fog height falloff"] = world.t3dFogHeightFalloff__
+        if "t3dSkyMode__" in world:
+            worldData["sky mode"] = world.t3dSkyMode__
+        if "t3dSkyTopColor__" in world:
+            worldData["sky top color"] = world.t3dSkyTopColor__
+        if "t3dSkyHorizonColor__" in world:
+            worldData["sky horizon color"] = world.t3dSkyHorizonColor__
+        if "t3dSkyBottomColor__" in world:
+            worldData["sky bottom color"] = world.t3dSkyBottomColor__
+        if "t3dSunColor__" in world:
+            worldData["sun color"] = world.t3dSunColor__
+        if "t3dSunDirection__" in world:
+            worldData["sun direction"] = world.t3dSunDirection__
+        if "t3dSunSize__" in world:
+            worldData["sun size"] = world.t3dSunSize__
+        if world.t3dSkyTexture__ is not None and world.t3dSkyTexture__.filepath != "":
+            # The sky texture's path is relative to the exported file, like the URIs of non-packed textures
+            skyPath = os.path.relpath(bpy.path.abspath(world.t3dSkyTexture__.filepath), os.path.dirname(newPath))
+            worldData["sky texture path"] = skyPath.replace("\\", "/")
 
         worlds[world.name] = worldData
 
@@ -525,6 +616,16 @@ def export():
                                 
                             obj["t3dGridConnections__"] = gridConnections
                             obj["t3dGridEntries__"] = gridEntries
+                        elif obj.t3dObjectType__ == 'ROOM':
+                            obj.data["t3dRoom__"] = True
+                            corners = [corner[:] for corner in obj.bound_box]
+                            obj["t3dRoomBounds__"] = [min(c[0] for c in corners), min(c[1] for c in corners), min(c[2] for c in corners), max(c[0] for c in corners), max(c[1] for c in corners), max(c[2] for c in corners)]
+                        elif obj.t3dObjectType__ == 'PORTAL':
+                            obj.data["t3dPortal__"] = True
+                            if len(obj.data.polygons) > 0:
+                                obj["t3dPortalPoints__"] = [obj.data.vertices[v].co[:] for v in obj.data.polygons[0].vertices]
+                            else:
+                                obj["t3dPortalPoints__"] = [v.co[:] for v in obj.data.vertices]
 
                     # Record relevant information for curves
                     if obj.type == "CURVE":
@@ -605,6 +706,12 @@ def export():
                             del(obj["t3dGridConnections__"])
                             del(obj["t3dGridEntries__"])
                             obj.data = ogGrids[obj] # Restore the mesh reference afterward
+                        elif obj.t3dObjectType__ == 'ROOM':
+                            del(obj.data["t3dRoom__"])
+                            del(obj["t3dRoomBounds__"])
+                        elif obj.t3dObjectType__ == 'PORTAL':
+                            del(obj.data["t3dPortal__"])
+                            del(obj["t3dPortalPoints__"])
 
     for action in bpy.data.actions:
         if "t3dMarkers__" in action:
@@ -649,7 +756,10 @@ objectProps = {
     "t3dSphereCustomEnabled__" : bpy.props.BoolProperty(name="Custom Sphere Size", description="If enabled, you can manually set the BoundingSphere node's radius. If disabled, the Sphere's size will be automatically determined by this object's mesh (if it is a mesh; otherwise, no BoundingSphere node will be generated)", default=False),
     "t3dSphereCustomRadius__" : bpy.props.FloatProperty(name="Radius", description="Radius of the BoundingSphere node that will be created", min=0.0, default=1),
     "t3dGameProperties__" : bpy.props.CollectionProperty(type=t3dGamePropertyItem__),
-    "t3dObjectType__" : bpy.props.EnumProperty(items=objectTypes, name="Object Type", description="The type of object this is")
+    "t3dObjectType__" : bpy.props.EnumProperty(items=objectTypes, name="Object Type", description="The type of object this is"),
+    "t3dLODMode__" : bpy.props.EnumProperty(items=lodModes, name="LOD Mode", description="How levels of detail are chosen for this object's LOD group"),
+    "t3dLODThreshold__" : bpy.props.FloatProperty(name="LOD Threshold", description="The distance at or beyond which this level of detail is used (or the screen size at or below which it is used, for screen size LOD groups). 0 defaults to 10 units of distance per level, or halving the screen size per level", min=0.0, default=0),
+    "t3dLODHysteresis__" : bpy.props.FloatProperty(name="LOD Hysteresis", description="The margin an object has to pass a level's threshold by to switch levels; this keeps objects near a threshold from switching back and forth constantly", min=0.0, default=0),
 }
 
 def getExportOnSave(self):
@@ -776,8 +886,10 @@ def register():
 
     bpy.types.Material.t3dMaterialColor__ = bpy.props.FloatVectorProperty(name="Material Color", description="Material modulation color", default=[1,1,1,1], subtype="COLOR", size=4, step=1, min=0, max=1)
     bpy.types.Material.t3dMaterialShadeless__ = bpy.props.BoolProperty(name="Shadeless", description="Whether lighting should affect this material", default=False)
+    bpy.types.Material.t3dMaterialFogless__ = bpy.props.BoolProperty(name="Fogless", description="Whether fog should affect this material", default=False)
     bpy.types.Material.t3dCompositeMode__ = bpy.props.EnumProperty(items=materialCompositeModes, name="Composite Mode", description="Composite mode (i.e. additive, multiplicative, etc) for this material", default="DEFAULT")
     bpy.types.Material.t3dBillboardMode__ = bpy.props.EnumProperty(items=materialBillboardModes, name="Billboarding Mode", description="Billboard mode (i.e. if the object with this material should rotate to face the camera) for this material", default="NONE")
+    bpy.types.Material.t3dLightingMode__ = bpy.props.EnumProperty(items=materialLightingModes, name="Lighting Mode", description="Lighting mode (i.e. if lighting is calculated per-vertex or per-pixel) for this material", default="VERTEX")
     
     bpy.types.World.t3dClearColor__ = bpy.props.FloatVectorProperty(name="Clear Color", description="Screen clear color; note that this won't actually be the background color automatically, but rather is simply set on the Scene.ClearColor property for you to use as you wish", default=[0.007, 0.008, 0.01, 1], subtype="COLOR", size=4, step=1, min=0, max=1)
     bpy.types.World.t3dFogColor__ = bpy.props.FloatVectorProperty(name="Fog Color", description="Fog color", default=[0, 0, 0, 1], subtype="COLOR", size=4, step=1, min=0, max=1)
@@ -785,6 +897,19 @@ def register():
 
     bpy.types.World.t3dFogRangeStart__ = bpy.props.FloatProperty(name="Fog Range Start", description="With 0 being the near plane and 1 being the far plane of the camera, how far in should the fog start to appear", min=0.0, max=1.0, default=0, get=fogRangeStartGet, set=fogRangeStartSet)
     bpy.types.World.t3dFogRangeEnd__ = bpy.props.FloatProperty(name="Fog Range End", description="With 0 being the near plane and 1 being the far plane of the camera, how far out should the fog be at maximum opacity", min=0.0, max=1.0, default=1, get=fogRangeEndGet, set=fogRangeEndSet)
+    bpy.types.World.t3dFogCurve__ = bpy.props.EnumProperty(items=worldFogCurves, name="Fog Curve", description="How the fog increases with distance from the camera", default="LINEAR")
+    bpy.types.World.t3dFogDensity__ = bpy.props.FloatProperty(name="Fog Density", description="How thick exponential fog is per unit of distance from the camera", min=0.0, default=0.05, step=1, precision=3)
+    bpy.types.World.t3dFogHeightFalloff__ = bpy.props.FloatProperty(name="Fog Height Falloff", description="If above 0, the fog thins out above the fog height, making it denser near the ground; higher values thin it out faster. Requires the camera to render depth", min=0.0, default=0, step=1, precision=3)
+    bpy.types.World.t3dFogHeight__ = bpy.props.FloatProperty(name="Fog Height", description="The height below which the fog is at full density, if the fog height falloff is above 0", default=0)
+
+    bpy.types.World.t3dSkyMode__ = bpy.props.EnumProperty(items=worldSkyModes, name="Sky Mode", description="How the sky is drawn behind everything else", default="OFF")
+    bpy.types.World.t3dSkyTopColor__ = bpy.props.FloatVectorProperty(name="Sky Top Color", description="The color of the sky straight up", default=[0.033, 0.133, 0.604, 1], subtype="COLOR", size=4, step=1, min=0, max=1)
+    bpy.types.World.t3dSkyHorizonColor__ = bpy.props.FloatVectorProperty(name="Sky Horizon Color", description="The color of the sky at the horizon", default=[0.448, 0.604, 0.787, 1], subtype="COLOR", size=4, step=1, min=0, max=1)
+    bpy.types.World.t3dSkyBottomColor__ = bpy.props.FloatVectorProperty(name="Sky Bottom Color", description="The color of the sky straight down", default=[0.051, 0.051, 0.073, 1], subtype="COLOR", size=4, step=1, min=0, max=1)
+    bpy.types.World.t3dSunColor__ = bpy.props.FloatVectorProperty(name="Sun Color", description="The color of the sun disc", default=[1, 0.89, 0.604, 1], subtype="COLOR", size=4, step=1, min=0, max=1)
+    bpy.types.World.t3dSunDirection__ = bpy.props.FloatVectorProperty(name="Sun Direction", description="The direction towards the sun disc", default=[0.5, 0.5, 0.7], subtype="DIRECTION", size=3)
+    bpy.types.World.t3dSunSize__ = bpy.props.FloatProperty(name="Sun Size", description="The angular diameter of the sun disc in degrees; if 0, no sun is drawn", min=0.0, max=180.0, default=0)
+    bpy.types.World.t3dSkyTexture__ = bpy.props.PointerProperty(type=bpy.types.Image, name="Sky Texture", description="The image to use for the sky; it isn't packed into the GLTF file, but rather its path (relative to the exported file) is set on the World.SkyTexturePath property for you to load")
 
     if not exportOnSave in bpy.app.handlers.save_post:
         bpy.app.handlers.save_post.append(exportOnSave)
@@ -817,14 +942,28 @@ def unregister():
 
     del bpy.types.Material.t3dMaterialColor__
     del bpy.types.Material.t3dMaterialShadeless__
+    del bpy.types.Material.t3dMaterialFogless__
     del bpy.types.Material.t3dCompositeMode__
     del bpy.types.Material.t3dBillboardMode__
+    del bpy.types.Material.t3dLightingMode__
 
     del bpy.types.World.t3dClearColor__
     del bpy.types.World.t3dFogColor__
     del bpy.types.World.t3dFogMode__
     del bpy.types.World.t3dFogRangeStart__
     del bpy.types.World.t3dFogRangeEnd__
+    del bpy.types.World.t3dFogCurve__
+    del bpy.types.World.t3dFogDensity__
+    del bpy.types.World.t3dFogHeightFalloff__
+    del bpy.types.World.t3dFogHeight__
+    del bpy.types.World.t3dSkyMode__
+    del bpy.types.World.t3dSkyTopColor__
+    del bpy.types.World.t3dSkyHorizonColor__
+    del bpy.types.World.t3dSkyBottomColor__
+    del bpy.types.World.t3dSunColor__
+    del bpy.types.World.t3dSunDirection__
+    del bpy.types.World.t3dSunSize__
+    del bpy.types.World.t3dSkyTexture__
 
     if exportOnSave in bpy.app.handlers.save_post:
         bpy.app.handlers.save_post.remove(exportOnSave)
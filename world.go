@@ -1,5 +1,10 @@
 package tetra3d
 
+import (
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/kvartborg/vector"
+)
+
 const (
 	FogOff         = iota // No fog
 	FogAdd                // Additive blended fog
@@ -10,6 +15,23 @@ const (
 
 type FogMode int
 
+const (
+	FogCurveLinear             = iota // Fog increases smoothly over the World's FogRange
+	FogCurveExponential               // Fog increases exponentially with distance from the camera, according to the World's FogDensity
+	FogCurveExponentialSquared        // Like FogCurveExponential, but the fog stays clearer close to the camera before thickening further away
+)
+
+type FogCurve int
+
+const (
+	SkyOff             = iota // No sky; anything not covered by rendered Models is left transparent
+	SkyGradient               // A procedural vertical gradient between the World's sky colors, with an optional sun disc
+	SkyEquirectangular        // An equirectangular (latitude / longitude) panorama, set in World.SkyTexture
+	SkyCubemap                // A cubemap, set in World.SkyTexture; see World.SkyTexture for the layout
+)
+
+type SkyMode int
+
 // World represents a collection of settings that one uses to control lighting and ambience. This includes the screen clear color, fog color,
 // mode, and range, whether lighting is globally enabled or not, and finally the ambient lighting level (using the World's AmbientLight).
 type World struct {
@@ -19,12 +41,39 @@ type World struct {
 	// use it.
 	FogColor *Color  // The Color of any fog present in the Scene.
 	FogMode  FogMode // The FogMode, indicating how the fog color is blended if it's on (not FogOff).
-	// FogRange is the depth range at which the fog is active. FogRange consists of two numbers,
+	// FogRange is the depth range at which the fog is active, if the FogCurve is FogCurveLinear. FogRange consists of two numbers,
 	// ranging from 0 to 1. The first indicates the start of the fog, and the second the end, in
 	// terms of total depth of the near / far clipping plane. The default is [0, 1].
-	FogRange     []float32
+	FogRange []float32
+	// FogCurve indicates how the fog increases with distance from the camera. FogCurveExponential and FogCurveExponentialSquared,
+	// as well as height-based fog, are only available when the Camera renders depth.
+	FogCurve FogCurve
+	// FogDensity is how thick the fog is per unit of distance from the camera, if the FogCurve is FogCurveExponential
+	// or FogCurveExponentialSquared. Defaults to 0.05.
+	FogDensity float32
+	// FogHeightFalloff controls height-based fog; if above 0, fog thins out above FogHeight, with higher values thinning
+	// it out faster (making it denser near the ground). Defaults to 0 (off).
+	FogHeightFalloff float32
+	FogHeight        float32 // The world-space height below which fog is at full density, if FogHeightFalloff is above 0.
+
 	LightingOn   bool          // If lighting is enabled when rendering the scene.
 	AmbientLight *AmbientLight // Ambient lighting for this world
+
+	// SkyMode indicates how the sky is drawn; if it's on (not SkyOff), Cameras draw the sky behind any Models rendered using this World,
+	// oriented according to the Camera's rotation. For orthographic Cameras, the whole sky is drawn in the direction the Camera faces.
+	SkyMode SkyMode
+	// SkyTexture is the image used for the sky if the SkyMode is SkyEquirectangular or SkyCubemap. Cubemaps are composed of six square
+	// faces laid out horizontally in +X, -X, +Y, -Y, +Z, -Z order, with each face oriented as in OpenGL cubemaps.
+	SkyTexture *ebiten.Image
+	// SkyTexturePath is the path to the sky texture, if the World was loaded from a GLTF file exported from Blender; as with
+	// Material.TexturePath, it's up to you to load the image from this path and set it on the World.
+	SkyTexturePath  string
+	SkyTopColor     *Color        // The color of the sky straight up when the SkyMode is SkyGradient.
+	SkyHorizonColor *Color        // The color of the sky at the horizon when the SkyMode is SkyGradient.
+	SkyBottomColor  *Color        // The color of the sky straight down when the SkyMode is SkyGradient.
+	SunDirection    vector.Vector // The direction towards the sun disc drawn in the sky when the SkyMode is SkyGradient.
+	SunColor        *Color        // The color of the sun disc.
+	SunSize         float64       // The angular diameter of the sun disc, in degrees; if 0, no sun is drawn. Defaults to 0.
 }
 
 // NewWorld creates a new World with the specified name and default values for fog, lighting, etc).
@@ -34,9 +83,16 @@ func NewWorld(name string) *World {
 		Name:         name,
 		FogColor:     NewColor(0, 0, 0, 0),
 		FogRange:     []float32{0, 1},
+		FogDensity:   0.05,
 		LightingOn:   true,
 		ClearColor:   NewColor(0.08, 0.09, 0.1, 1),
 		AmbientLight: NewAmbientLight("ambient light", 1, 1, 1, 0),
+
+		SkyTopColor:     NewColor(0.2, 0.4, 0.8, 1),
+		SkyHorizonColor: NewColor(0.7, 0.8, 0.9, 1),
+		SkyBottomColor:  NewColor(0.25, 0.25, 0.3, 1),
+		SunDirection:    vector.Vector{0.5, 0.7, -0.5}.Unit(),
+		SunColor:        NewColor(1, 0.95, 0.8, 1),
 	}
 
 }
@@ -51,9 +107,23 @@ func (world *World) Clone() *World {
 	newWorld.FogMode = world.FogMode
 	newWorld.FogRange[0] = world.FogRange[0]
 	newWorld.FogRange[1] = world.FogRange[1]
+	newWorld.FogCurve = world.FogCurve
+	newWorld.FogDensity = world.FogDensity
+	newWorld.FogHeightFalloff = world.FogHeightFalloff
+	newWorld.FogHeight = world.FogHeight
 	newWorld.LightingOn = world.LightingOn
 	newWorld.AmbientLight = world.AmbientLight.Clone().(*AmbientLight)
 
+	newWorld.SkyMode = world.SkyMode
+	newWorld.SkyTexture = world.SkyTexture
+	newWorld.SkyTexturePath = world.SkyTexturePath
+	newWorld.SkyTopColor = world.SkyTopColor.Clone()
+	newWorld.SkyHorizonColor = world.SkyHorizonColor.Clone()
+	newWorld.SkyBottomColor = world.SkyBottomColor.Clone()
+	newWorld.SunDirection = world.SunDirection.Clone()
+	newWorld.SunColor = world.SunColor.Clone()
+	newWorld.SunSize = world.SunSize
+
 	return newWorld
 
 }